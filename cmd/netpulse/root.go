@@ -50,6 +50,7 @@ func init() {
 	rootCmd.AddCommand(reportCmd)
 	rootCmd.AddCommand(webCmd)
 	rootCmd.AddCommand(uiCmd)
+	rootCmd.AddCommand(throughputServerCmd)
 	rootCmd.AddCommand(versionCmd)
 	
 	// Add shell completion
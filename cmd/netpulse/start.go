@@ -72,7 +72,7 @@ func runForeground() error {
 				return
 			}
 			
-			srv := web.NewServer(db, cfg, startWebPort)
+			srv := web.NewServer(db, cfg, d.GetGeo(), startWebPort)
 			fmt.Printf("Web dashboard: http://localhost:%d\n", startWebPort)
 			if err := srv.Start(); err != nil {
 				util.Error("Web server error: %v", err)
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
@@ -74,11 +76,31 @@ func runStatus(cmd *cobra.Command, args []string) error {
 				if job.Running {
 					statusStr = "running"
 				}
-				fmt.Printf("  %s: %s (last: %s, errors: %d)\n",
+				fmt.Printf("  %s: %s (last: %s, errors: %d, every %s",
 					labelStyle.Render(job.Name),
 					valueStyle.Render(statusStr),
 					job.LastRun.Format("15:04:05"),
-					job.ErrorCount)
+					job.ErrorCount,
+					job.EffectiveInterval)
+				if job.IntervalReason != "" {
+					fmt.Printf(": %s", job.IntervalReason)
+				}
+				fmt.Println(")")
+				
+				next := "now"
+				if wait := time.Until(job.NextRun); wait > 0 {
+					next = fmt.Sprintf("%s (in %s)", job.NextRun.Format("2006-01-02 15:04:05"), wait.Round(time.Second))
+				}
+				fmt.Printf("    %s %s\n", labelStyle.Render("next:"), next)
+				if job.NextRunReason != "" {
+					fmt.Printf("    %s %s\n", labelStyle.Render("why:"), job.NextRunReason)
+				}
+				if len(job.After) > 0 {
+					fmt.Printf("    %s %s\n", labelStyle.Render("after:"), strings.Join(job.After, ", "))
+				}
+				if len(job.Blackouts) > 0 {
+					fmt.Printf("    %s %s\n", labelStyle.Render("blackouts:"), strings.Join(job.Blackouts, ", "))
+				}
 			}
 		}
 	}
@@ -125,6 +147,11 @@ func runStatus(cmd *cobra.Command, args []string) error {
 				labelStyle.Render("Last check:"),
 				valueStyle.Render(latest.Timestamp.Format("2006-01-02 15:04:05")))
 		}
+		if latest, err := ipStorage.GetLatestByFamily("ipv6"); err == nil && latest != nil {
+			fmt.Printf("  %s %s\n",
+				labelStyle.Render("IPv6:"),
+				valueStyle.Render(latest.IP))
+		}
 	}
 	
 	return nil
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/user/netpulse/internal/probes"
+)
+
+var (
+	throughputPort int
+	throughputBind string
+)
+
+var throughputServerCmd = &cobra.Command{
+	Use:   "throughput-server",
+	Short: "Run a throughput test server",
+	Long: `Run a server that answers netpulse throughput tests.
+
+Start it on the far end of a link, then list its address under
+throughput_servers in the config of the netpulse daemon measuring the link.
+It listens on the same port for TCP (upload/download) and UDP (loss/jitter).
+
+Examples:
+  netpulse throughput-server
+  netpulse throughput-server --port 5301 --bind 10.20.0.5`,
+	RunE: runThroughputServer,
+}
+
+func init() {
+	throughputServerCmd.Flags().IntVarP(&throughputPort, "port", "p", 0, "Listen port (default throughput_port from config)")
+	throughputServerCmd.Flags().StringVar(&throughputBind, "bind", "", "Listen address (default all interfaces)")
+}
+
+func runThroughputServer(cmd *cobra.Command, args []string) error {
+	port := throughputPort
+	if port == 0 {
+		port = cfg.ThroughputPort
+	}
+	addr := net.JoinHostPort(throughputBind, strconv.Itoa(port))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Throughput server listening on %s (tcp/udp)\n", addr)
+	fmt.Println("Press Ctrl+C to stop")
+
+	return probes.NewThroughputServer(addr).ListenAndServe(ctx)
+}
@@ -42,6 +42,6 @@ func runWeb(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Starting web server on http://localhost:%d\n", webPort)
 	fmt.Println("Press Ctrl+C to stop")
 	
-	srv := web.NewServer(db, cfg, webPort)
+	srv := web.NewServer(db, cfg, nil, webPort)
 	return srv.Start()
 }
@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/netpulse/internal/geoip"
+	"github.com/user/netpulse/internal/model"
+)
+
+// asPathAnomalyData is stored as the data of AS path change anomalies.
+type asPathAnomalyData struct {
+	Target    string    `json:"target"`
+	OldPath   []string  `json:"old_path"`
+	NewPath   []string  `json:"new_path"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// checkASPathChange raises an anomaly when the traffic to a target moved to
+// other networks since the last trace taken the same way that had an AS
+// path, and reports whether it did.
+func (d *Daemon) checkASPathChange(prev, trace *model.TraceResult) bool {
+	if prev == nil || prev.Mode != trace.Mode || !geoip.ASPathChanged(prev.ASPath, trace.ASPath) {
+		return false
+	}
+	d.raiseAnomaly(model.AnomalyASPathChange, "warning",
+		fmt.Sprintf("Route to %s moved from %s to %s", trace.Target,
+			strings.Join(prev.ASPath, " → "), strings.Join(trace.ASPath, " → ")),
+		asPathAnomalyData{
+			Target:    trace.Target,
+			OldPath:   prev.ASPath,
+			NewPath:   trace.ASPath,
+			Timestamp: trace.Timestamp,
+		}, time.Time{})
+	return true
+}
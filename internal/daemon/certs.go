@@ -0,0 +1,155 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+	"github.com/user/netpulse/internal/probes"
+	"github.com/user/netpulse/internal/storage"
+	"github.com/user/netpulse/internal/util"
+)
+
+// certAnomalyData is stored as the data of certificate anomalies.
+type certAnomalyData struct {
+	Endpoint       string    `json:"endpoint"`
+	Fingerprint    string    `json:"fingerprint"`
+	OldFingerprint string    `json:"old_fingerprint,omitempty"`
+	NotAfter       time.Time `json:"not_after"`
+}
+
+// runCertCheck collects the certificates of every TLS port found by the
+// port scanner and raises anomalies for expiring, expired, self-signed and
+// replaced certificates.
+func (d *Daemon) runCertCheck(ctx context.Context) error {
+	scanStorage := storage.NewScanStorage(d.db)
+	certStorage := storage.NewCertStorage(d.db)
+
+	hosts, err := scanStorage.GetAliveHosts()
+	if err != nil {
+		return err
+	}
+
+	probe := probes.NewCertProbe(d.config.ScanTimeout)
+	checked := 0
+	for _, host := range hosts {
+		ports, err := scanStorage.GetHostPorts(host.ID)
+		if err != nil {
+			util.Warn("Failed to get ports for %s: %v", host.IP, err)
+			continue
+		}
+
+		for _, port := range ports {
+			if !port.TLS || port.Protocol != "tcp" {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+
+			cert, err := probe.Fetch(ctx, host.IP, port.Port)
+			if err != nil {
+				util.Debug("Certificate check on %s:%d failed: %v", host.IP, port.Port, err)
+				continue
+			}
+			cert.HostID = host.ID
+
+			prev, err := certStorage.Get(cert.IP, cert.Port)
+			if err != nil {
+				util.Warn("Failed to load certificate for %s:%d: %v", host.IP, port.Port, err)
+			}
+			if err := certStorage.Save(cert); err != nil {
+				util.Warn("Failed to save certificate for %s:%d: %v", host.IP, port.Port, err)
+				continue
+			}
+			if prev != nil && prev.Fingerprint != cert.Fingerprint {
+				reportChange(ctx, "certificate on %s:%d replaced", host.IP, port.Port)
+			}
+			d.checkCertAnomalies(prev, cert)
+			checked++
+		}
+	}
+
+	util.Info("Certificate check complete: %d certificates", checked)
+
+	return nil
+}
+
+// checkCertAnomalies records anomalies for a freshly fetched certificate.
+// Each condition is raised once for as long as the endpoint serves the
+// certificate, so it is raised again when a certificate comes back.
+func (d *Daemon) checkCertAnomalies(prev, cert *model.Certificate) {
+	endpoint := net.JoinHostPort(cert.IP, strconv.Itoa(cert.Port))
+	since := cert.LastSeen
+	if prev != nil && prev.Fingerprint == cert.Fingerprint {
+		since = prev.FirstSeen
+	}
+	data := certAnomalyData{
+		Endpoint:    endpoint,
+		Fingerprint: cert.Fingerprint,
+		NotAfter:    cert.NotAfter,
+	}
+
+	if prev != nil && prev.Fingerprint != cert.Fingerprint {
+		changed := data
+		changed.OldFingerprint = prev.Fingerprint
+		d.raiseAnomaly(model.AnomalyCertChanged, "warning",
+			fmt.Sprintf("Certificate on %s changed (%s)", endpoint, cert.Subject), changed, since)
+	}
+
+	daysLeft := int(time.Until(cert.NotAfter).Hours() / 24)
+	switch {
+	case time.Now().After(cert.NotAfter):
+		d.raiseAnomaly(model.AnomalyCertExpired, "critical",
+			fmt.Sprintf("Certificate on %s expired on %s", endpoint, cert.NotAfter.Format("2006-01-02")), data, since)
+	case daysLeft <= d.config.CertExpiryDays:
+		d.raiseAnomaly(model.AnomalyCertExpiring, "warning",
+			fmt.Sprintf("Certificate on %s expires in %d days", endpoint, daysLeft), data, since)
+	}
+
+	if cert.SelfSigned {
+		d.raiseAnomaly(model.AnomalyCertSelfSigned, "info",
+			fmt.Sprintf("Certificate on %s is self-signed (%s)", endpoint, cert.Subject), data, since)
+	}
+}
+
+// raiseAnomaly stores an anomaly unless one with the same type and data was
+// recorded since a given time (see AnomalyStorage.Exists), and reports
+// whether it stored one.
+func (d *Daemon) raiseAnomaly(anomalyType, severity, description string, data interface{}, since time.Time) bool {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		util.Warn("Failed to encode anomaly data: %v", err)
+		return false
+	}
+
+	anomalyStorage := storage.NewAnomalyStorage(d.db)
+	exists, err := anomalyStorage.Exists(anomalyType, string(raw), since)
+	if err != nil {
+		util.Warn("Failed to check anomalies: %v", err)
+		return false
+	}
+	if exists {
+		return false
+	}
+
+	anomaly := &model.Anomaly{
+		Type:        anomalyType,
+		Description: description,
+		Severity:    severity,
+		Timestamp:   time.Now(),
+		Data:        string(raw),
+	}
+	if err := anomalyStorage.Save(anomaly); err != nil {
+		util.Warn("Failed to save anomaly: %v", err)
+		return false
+	}
+	util.Warn("Anomaly: %s", description)
+	return true
+}
@@ -12,6 +12,9 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/user/netpulse/internal/geoip"
+	"github.com/user/netpulse/internal/model"
+	"github.com/user/netpulse/internal/oui"
 	"github.com/user/netpulse/internal/storage"
 	"github.com/user/netpulse/internal/util"
 )
@@ -21,6 +24,11 @@ type Daemon struct {
 	config     *util.Config
 	scheduler  *Scheduler
 	db         *storage.DB
+	vendors    *oui.DB
+	geo        *geoip.Resolver
+	hostnames  chan *model.TraceResult // Traces waiting for hop hostnames
+	linkTest   sync.Mutex // Serializes tests that saturate the link
+	statusMu   sync.Mutex // Serializes status file writes
 	pidFile    string
 	ctx        context.Context
 	cancel     context.CancelFunc
@@ -37,16 +45,28 @@ func New(cfg *util.Config) (*Daemon, error) {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 	
+	geo, err := geoip.Open(cfg.DataDir, storage.NewGeoIPStorage(db))
+	if err != nil {
+		util.Warn("Failed to load geolocation databases: %v", err)
+	}
+	geo.SetOnline(cfg.GeoIPOnline)
+	
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	d := &Daemon{
 		config:    cfg,
 		db:        db,
+		vendors:   oui.Load(cfg.DataDir),
+		geo:       geo,
 		pidFile:   filepath.Join(cfg.DataDir, "netpulse.pid"),
 		ctx:       ctx,
 		cancel:    cancel,
 	}
 	
+	if cfg.TraceRDNS {
+		d.hostnames = make(chan *model.TraceResult, hostnameQueueSize)
+	}
+	
 	d.scheduler = NewScheduler(ctx, d)
 	
 	return d, nil
@@ -80,6 +100,24 @@ func (d *Daemon) Start() error {
 		d.scheduler.Run()
 	}()
 	
+	// Continuous loss/jitter monitoring runs outside the scheduler
+	if d.config.LossMonitor {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.runLossMonitor(d.ctx)
+		}()
+	}
+	
+	// Hop hostnames are resolved after each trace is saved
+	if d.hostnames != nil {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.runHostnameResolver(d.ctx)
+		}()
+	}
+	
 	// Handle signals
 	d.wg.Add(1)
 	go func() {
@@ -177,6 +215,19 @@ func (d *Daemon) GetStatus() *DaemonStatus {
 	}
 }
 
+// writeStatus refreshes the status file read by the status command.
+func (d *Daemon) writeStatus() {
+	currentIP := ""
+	if latest, err := storage.NewIPStorage(d.db).GetLatestByFamily("ipv4"); err == nil && latest != nil {
+		currentIP = latest.IP
+	}
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+	if err := WriteStatusFile(d.config.DataDir, d.GetStatus(), currentIP); err != nil {
+		util.Warn("Failed to write status file: %v", err)
+	}
+}
+
 // DaemonStatus holds the current daemon status.
 type DaemonStatus struct {
 	Running   bool
@@ -196,6 +247,11 @@ func (d *Daemon) GetConfig() *util.Config {
 	return d.config
 }
 
+// GetGeo returns the geolocation resolver.
+func (d *Daemon) GetGeo() *geoip.Resolver {
+	return d.geo
+}
+
 // GetContext returns the daemon context.
 func (d *Daemon) GetContext() context.Context {
 	return d.ctx
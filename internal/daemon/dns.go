@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+	"github.com/user/netpulse/internal/monitor"
+	"github.com/user/netpulse/internal/storage"
+	"github.com/user/netpulse/internal/util"
+)
+
+// dnsIntegrityAnomalyData is stored as the data of DNS integrity anomalies.
+type dnsIntegrityAnomalyData struct {
+	QueryName string              `json:"query_name,omitempty"`
+	Answers   map[string][]string `json:"answers"` // "resolver/transport" → answers
+}
+
+// runDNSIntegrity checks the built-in and user-added resolvers for
+// interception and rewritten answers.
+func (d *Daemon) runDNSIntegrity(ctx context.Context) error {
+	targets := append([]model.DNSTarget(nil), monitor.DefaultTargets...)
+	custom, err := d.db.GetDNSTargets()
+	if err != nil {
+		util.Warn("Failed to load DNS targets: %v", err)
+	}
+	targets = append(targets, custom...)
+
+	report := monitor.CheckIntegrity(targets, d.config.DNSIntegrityNames)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	integrityStorage := storage.NewDNSIntegrityStorage(d.db)
+	prev, err := integrityStorage.GetLastRun()
+	if err != nil {
+		util.Warn("Failed to load the last DNS integrity run: %v", err)
+	}
+	flagged := make(map[string]bool)
+	for _, check := range prev {
+		if !check.OK {
+			flagged[integrityCheckKey(check)] = true
+		}
+	}
+
+	for i := range report.Checks {
+		if err := integrityStorage.Save(&report.Checks[i]); err != nil {
+			util.Warn("Failed to save DNS integrity check: %v", err)
+		}
+	}
+
+	// A finding whose answers the previous run already blamed is still
+	// the same one; one that went away and came back is raised again
+	started := time.Now()
+	raised := 0
+	for _, f := range report.Findings {
+		ongoing := len(f.Suspects) > 0
+		for _, i := range f.Suspects {
+			if !flagged[integrityCheckKey(report.Checks[i])] {
+				ongoing = false
+			}
+		}
+		if ongoing {
+			continue
+		}
+		if d.raiseAnomaly(f.Type, f.Severity, f.Description, dnsIntegrityAnomalyData{
+			QueryName: f.QueryName,
+			Answers:   f.Answers,
+		}, started) {
+			raised++
+		}
+	}
+
+	util.Debug("DNS integrity: %d answers checked, %d findings", len(report.Checks), len(report.Findings))
+	if raised > 0 {
+		reportChange(ctx, "%d new integrity findings", raised)
+	}
+	return nil
+}
+
+// integrityCheckKey identifies an answer across integrity runs.
+func integrityCheckKey(c model.DNSIntegrityCheck) string {
+	return strings.Join([]string{c.Check, c.Server, c.Protocol, c.QueryName, c.Rcode,
+		strings.Join(c.Answers, ",")}, "|")
+}
@@ -0,0 +1,56 @@
+package daemon
+
+import (
+	"context"
+
+	"github.com/user/netpulse/internal/model"
+	"github.com/user/netpulse/internal/rdns"
+	"github.com/user/netpulse/internal/storage"
+	"github.com/user/netpulse/internal/util"
+)
+
+// hostnameQueueSize bounds the traces waiting for their hop hostnames.
+const hostnameQueueSize = 64
+
+// queueHostnames hands a saved trace to the hostname resolver. Traces are
+// dropped when the queue is full; their hops keep their addresses only.
+func (d *Daemon) queueHostnames(trace *model.TraceResult) {
+	if d.hostnames == nil {
+		return
+	}
+	select {
+	case d.hostnames <- trace:
+	default:
+		util.Debug("Hostname queue full, skipping trace %d", trace.ID)
+	}
+}
+
+// runHostnameResolver resolves the hop addresses of queued traces, at the
+// configured rate, and stores the names with the hops.
+func (d *Daemon) runHostnameResolver(ctx context.Context) {
+	resolver := rdns.New(storage.NewPTRStorage(d.db), d.config.TraceRDNSRate)
+	traceStorage := storage.NewTraceStorage(d.db)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case trace := <-d.hostnames:
+			var ips []string
+			for _, hop := range trace.Hops {
+				if !hop.Lost && hop.Hostname == "" {
+					ips = append(ips, hop.IP)
+				}
+			}
+			names := resolver.LookupAll(ctx, ips)
+			if len(names) == 0 {
+				continue
+			}
+			if err := traceStorage.SetHostnames(trace.ID, names); err != nil {
+				util.Warn("Failed to save hop hostnames for %s: %v", trace.Target, err)
+				continue
+			}
+			util.Debug("Resolved %d of %d hop hostnames for %s", len(names), len(ips), trace.Target)
+		}
+	}
+}
@@ -2,8 +2,13 @@ package daemon
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
 	"time"
 
+	"github.com/user/netpulse/internal/geoip"
 	"github.com/user/netpulse/internal/model"
 	"github.com/user/netpulse/internal/probes"
 	"github.com/user/netpulse/internal/storage"
@@ -17,61 +22,177 @@ func (d *Daemon) registerJobs() {
 		Name:     "ip_check",
 		Interval: d.config.IPCheckInterval,
 		Run:      d.runIPCheck,
+		Adaptive: true,
 	})
 	
-	// Traceroute Job
+	// Traceroute Job, also triggered by public IP changes
 	d.scheduler.AddJob(&Job{
 		Name:     "traceroute",
 		Interval: d.config.TraceInterval,
 		Run:      d.runTraceroute,
+		Adaptive: true,
+		After:    []string{"ip_check"},
 	})
 	
+	// Path MTU Job
+	if d.config.PMTUInterval > 0 {
+		d.scheduler.AddJob(&Job{
+			Name:     "pmtu",
+			Interval: d.config.PMTUInterval,
+			Run:      d.runPMTUDiscovery,
+			Adaptive: true,
+		})
+	}
+	
+	// DNS Integrity Job
+	if d.config.DNSIntegrityInterval > 0 {
+		d.scheduler.AddJob(&Job{
+			Name:     "dns_integrity",
+			Interval: d.config.DNSIntegrityInterval,
+			Run:      d.runDNSIntegrity,
+			Adaptive: true,
+		})
+	}
+	
 	// Ping Sweep Job
 	d.scheduler.AddJob(&Job{
 		Name:     "ping_sweep",
 		Interval: d.config.PingSweepInterval,
 		Run:      d.runPingSweep,
+		Adaptive: true,
 	})
 	
-	// Port Scan Job
+	// Port Scan Job, also triggered by the sweep for new hosts
 	d.scheduler.AddJob(&Job{
 		Name:     "port_scan",
 		Interval: d.config.PortScanInterval,
 		Run:      d.runPortScan,
+		Adaptive: true,
+		After:    []string{"ping_sweep"},
 	})
+	
+	// Certificate Check Job
+	d.scheduler.AddJob(&Job{
+		Name:     "cert_check",
+		Interval: d.config.CertCheckInterval,
+		Run:      d.runCertCheck,
+		Adaptive: true,
+	})
+	
+	// HTTP Check Job
+	if len(d.config.HTTPChecks) > 0 {
+		d.scheduler.AddJob(&Job{
+			Name:     "http_check",
+			Interval: d.config.HTTPCheckInterval,
+			Run:      d.runHTTPChecks,
+		})
+	}
+	
+	// Throughput Test Job
+	if len(d.config.ThroughputServers) > 0 {
+		d.scheduler.AddJob(&Job{
+			Name:     "throughput",
+			Interval: d.config.ThroughputInterval,
+			Run:      d.runThroughputTests,
+		})
+		
+		if d.config.BufferbloatInterval > 0 {
+			d.scheduler.AddJob(&Job{
+				Name:     "bufferbloat",
+				Interval: d.config.BufferbloatInterval,
+				Run:      d.runBufferbloatTest,
+			})
+		}
+	}
 }
 
 func (d *Daemon) runIPCheck(ctx context.Context) error {
 	probe := probes.NewIPProbe()
+	probe.SetQuorum(d.config.IPQuorum)
+	var providers []probes.IPProvider
+	for _, p := range d.config.IPProviders {
+		providers = append(providers, probes.IPProvider{
+			Name:   p.Name,
+			Method: p.Method,
+			URL:    p.URL,
+			Server: p.Server,
+			Query:  p.Query,
+			Record: p.Record,
+		})
+	}
+	probe.SetProviders(providers)
+	
+	// Track the IPv4 and IPv6 addresses separately; a host without IPv6
+	// connectivity simply has no IPv6 history.
+	ipv4Err := d.checkPublicIP(ctx, probe, probes.FamilyIPv4)
+	if ipv4Err != nil {
+		util.Warn("IPv4 check failed: %v", ipv4Err)
+	}
+	ipv6Err := d.checkPublicIP(ctx, probe, probes.FamilyIPv6)
+	if ipv6Err != nil {
+		util.Debug("IPv6 check failed: %v", ipv6Err)
+	}
 	
-	// Get public IP
-	ip, err := probe.GetPublicIP(ctx)
+	if ipv4Err != nil && ipv6Err != nil {
+		return ipv4Err
+	}
+	return nil
+}
+
+// checkPublicIP detects and records the public address of one family,
+// along with every provider's answer for the reliability stats.
+func (d *Daemon) checkPublicIP(ctx context.Context, probe *probes.IPProbe, family string) error {
+	ipStorage := storage.NewIPStorage(d.db)
+	detection, err := probe.Detect(ctx, family)
+	for i := range detection.Answers {
+		if err := ipStorage.SaveProviderCheck(&detection.Answers[i]); err != nil {
+			util.Warn("Failed to save IP provider check: %v", err)
+		}
+	}
+	if disagreeing := detection.Disagreeing(); len(disagreeing) > 0 {
+		var answers []string
+		for _, a := range disagreeing {
+			answers = append(answers, fmt.Sprintf("%s=%s", a.Provider, a.IP))
+		}
+		util.Warn("Public %s providers disagree with %s (%d votes): %s",
+			family, detection.IP, detection.Votes, strings.Join(answers, ", "))
+	}
 	if err != nil {
 		return err
 	}
+	ip := detection.IP
 	
-	util.Info("Detected public IP: %s", ip)
+	util.Info("Detected public %s: %s", family, ip)
 	
 	// Get ASN info
-	asnInfo, err := probes.GetASNInfo(ctx, ip)
-	if err != nil {
+	geo, err := d.geo.Lookup(ctx, ip)
+	if errors.Is(err, geoip.ErrNotFound) {
+		util.Debug("No ASN info for %s: add a database to the data dir or enable geoip_online", ip)
+	} else if err != nil {
 		util.Warn("Failed to get ASN info: %v", err)
 	}
 	
 	// Create record
 	record := &model.IPRecord{
 		IP:        ip,
+		Family:    family,
 		Timestamp: time.Now(),
 	}
-	if asnInfo != nil {
-		record.ASN = asnInfo.ASN
-		record.ISP = asnInfo.ISP
-		record.Country = asnInfo.Country
-		record.City = asnInfo.City
+	if geo != nil {
+		// "AS15169 Google LLC", as recorded before local lookups
+		record.ASN = strings.TrimSpace(geo.ASN + " " + geo.Org)
+		record.ISP = geo.ISP
+		if record.ISP == "" {
+			record.ISP = geo.Org
+		}
+		record.Country = geo.Country
+		if record.Country == "" {
+			record.Country = geo.CountryCode
+		}
+		record.City = geo.City
 	}
 	
 	// Check if IP changed
-	ipStorage := storage.NewIPStorage(d.db)
 	changed, err := ipStorage.HasChanged(ip)
 	if err != nil {
 		return err
@@ -83,7 +204,10 @@ func (d *Daemon) runIPCheck(ctx context.Context) error {
 	}
 	
 	if changed {
-		util.Info("IP changed to: %s (%s)", ip, record.ISP)
+		util.Info("Public %s changed to: %s (%s)", family, ip, record.ISP)
+		reportChange(ctx, "new public %s %s", family, ip)
+		// Routes usually change with the address
+		d.scheduler.Trigger("traceroute", fmt.Sprintf("public %s changed", family))
 	}
 	
 	return nil
@@ -91,6 +215,10 @@ func (d *Daemon) runIPCheck(ctx context.Context) error {
 
 func (d *Daemon) runTraceroute(ctx context.Context) error {
 	probe := probes.NewTracerouteProbe()
+	probe.SetMethod(d.config.TraceMethod)
+	probe.SetProtocol(d.config.TraceProtocol)
+	probe.SetProbesPerHop(d.config.TraceProbes)
+	probe.SetMode(d.config.TraceMode)
 	traceStorage := storage.NewTraceStorage(d.db)
 	
 	for _, target := range d.config.TraceTargets {
@@ -108,12 +236,73 @@ func (d *Daemon) runTraceroute(ctx context.Context) error {
 			continue
 		}
 		
+		// Compare with the last trace that had an AS path, so a trace that
+		// reached no public hop in between does not hide a change
+		prevAS, err := traceStorage.GetLatestASPath(target, result.Mode)
+		if err != nil {
+			util.Warn("Failed to load previous AS path for %s: %v", target, err)
+		}
+		prev, err := traceStorage.GetLatest(target)
+		if err != nil {
+			util.Warn("Failed to load previous trace for %s: %v", target, err)
+		}
+		d.geo.AnnotateTrace(ctx, result)
+		
 		if err := traceStorage.Save(result); err != nil {
 			util.Warn("Failed to save trace for %s: %v", target, err)
 			continue
 		}
 		
 		util.Info("Traceroute to %s: %d hops", target, len(result.Hops))
+		if d.checkASPathChange(prevAS, result) {
+			reportChange(ctx, "route to %s changed", target)
+		} else if (prevAS == nil || len(result.ASPath) == 0) && prev != nil && prev.Mode == result.Mode &&
+			probes.RouteChanged(prev.Hops, result.Hops) {
+			// Without AS paths, e.g. when no ASN database is loaded, the
+			// hops are all there is to go by
+			reportChange(ctx, "hops to %s changed", target)
+		}
+		d.queueHostnames(result)
+	}
+	
+	return nil
+}
+
+func (d *Daemon) runHTTPChecks(ctx context.Context) error {
+	checkStorage := storage.NewHTTPCheckStorage(d.db)
+	
+	for _, check := range d.config.HTTPChecks {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		
+		name := check.Name
+		if name == "" {
+			name = check.URL
+		}
+		
+		result := probes.RunHTTPCheck(ctx, probes.HTTPCheck{
+			Name:         name,
+			URL:          check.URL,
+			Method:       check.Method,
+			Headers:      check.Headers,
+			ExpectStatus: check.ExpectStatus,
+			ExpectBody:   check.ExpectBody,
+			Timeout:      check.Timeout,
+		})
+		
+		if err := checkStorage.Save(result); err != nil {
+			util.Warn("Failed to save HTTP check %s: %v", name, err)
+			continue
+		}
+		
+		if result.Success {
+			util.Debug("HTTP check %s: %d in %.0f ms", name, result.StatusCode, result.TotalMs)
+		} else {
+			util.Warn("HTTP check %s failed: %s", name, result.Error)
+		}
 	}
 	
 	return nil
@@ -126,6 +315,9 @@ func (d *Daemon) runPingSweep(ctx context.Context) error {
 	}
 	
 	probe := probes.NewPingProbe(d.config.SweepConcurrency, d.config.SweepTimeout)
+	probe.SetMethod(d.config.SweepMethod)
+	probe.SetActiveARP(d.config.SweepActiveARP)
+	probe.SetNameResolvers(d.config.SweepNameResolvers)
 	scanStorage := storage.NewScanStorage(d.db)
 	
 	util.Debug("Starting ping sweep of %s", d.config.SweepSubnet)
@@ -136,8 +328,18 @@ func (d *Daemon) runPingSweep(ctx context.Context) error {
 	}
 	
 	aliveCount := 0
+	var newHosts []string
 	for i := range hosts {
 		host := &hosts[i]
+		if host.MAC != "" {
+			host.Vendor = d.vendors.Lookup(host.MAC)
+		}
+		if host.Alive {
+			// New, or back after being down
+			if known, err := scanStorage.GetHost(host.IP); err == nil && (known == nil || !known.Alive) {
+				newHosts = append(newHosts, host.IP)
+			}
+		}
 		if err := scanStorage.SaveHost(host); err != nil {
 			util.Warn("Failed to save host %s: %v", host.IP, err)
 		}
@@ -147,10 +349,59 @@ func (d *Daemon) runPingSweep(ctx context.Context) error {
 	}
 	
 	util.Info("Ping sweep complete: %d/%d hosts alive", aliveCount, len(hosts))
+	if len(newHosts) > 0 {
+		reportChange(ctx, "%d new hosts", len(newHosts))
+		// Runs once discovery below is done, since it waits for the sweep
+		d.scheduler.Trigger("port_scan", fmt.Sprintf("%d new hosts from ping sweep", len(newHosts)), newHosts...)
+	}
+	
+	d.runLANDiscovery(ctx, scanStorage)
 	
 	return nil
 }
 
+// runLANDiscovery enriches swept hosts with names and services that devices
+// announce over mDNS and SSDP. Devices outside the sweep subnet are ignored.
+func (d *Daemon) runLANDiscovery(ctx context.Context, scanStorage *storage.ScanStorage) {
+	_, subnet, err := net.ParseCIDR(d.config.SweepSubnet)
+	if err != nil {
+		return
+	}
+	
+	type discoverFunc func(context.Context, time.Duration) ([]probes.LANDevice, error)
+	var sources []discoverFunc
+	if d.config.SweepMDNS {
+		sources = append(sources, probes.DiscoverMDNS)
+	}
+	if d.config.SweepSSDP {
+		sources = append(sources, probes.DiscoverSSDP)
+	}
+	
+	for _, discover := range sources {
+		devices, err := discover(ctx, d.config.SweepDiscoveryWindow)
+		if err != nil {
+			util.Warn("LAN discovery failed: %v", err)
+			continue
+		}
+		
+		enriched := 0
+		for _, dev := range devices {
+			ip := net.ParseIP(dev.IP)
+			if ip == nil || !subnet.Contains(ip) {
+				continue
+			}
+			if err := scanStorage.EnrichHost(dev.IP, dev.Hostname, dev.Services, dev.Info, dev.Source); err != nil {
+				util.Warn("Failed to save %s discovery for %s: %v", dev.Source, dev.IP, err)
+				continue
+			}
+			enriched++
+		}
+		if enriched > 0 {
+			util.Debug("LAN discovery: %d devices enriched", enriched)
+		}
+	}
+}
+
 func (d *Daemon) runPortScan(ctx context.Context) error {
 	scanStorage := storage.NewScanStorage(d.db)
 	
@@ -160,6 +411,20 @@ func (d *Daemon) runPortScan(ctx context.Context) error {
 		return err
 	}
 	
+	// A run triggered by the sweep only scans the hosts it found
+	if targets := jobTargets(ctx); len(targets) > 0 {
+		var selected []model.ScanHost
+		for _, host := range hosts {
+			for _, ip := range targets {
+				if host.IP == ip {
+					selected = append(selected, host)
+					break
+				}
+			}
+		}
+		hosts = selected
+	}
+	
 	if len(hosts) == 0 {
 		util.Debug("No alive hosts to scan")
 		return nil
@@ -170,10 +435,11 @@ func (d *Daemon) runPortScan(ctx context.Context) error {
 		d.config.ScanTimeout,
 		d.config.ScanPorts,
 	)
+	scanner.SetUDPPorts(d.config.ScanUDPPorts)
 	
 	util.Debug("Starting port scan on %d hosts", len(hosts))
 	
-	totalPorts := 0
+	totalPorts, newPorts := 0, 0
 	for _, host := range hosts {
 		select {
 		case <-ctx.Done():
@@ -187,18 +453,40 @@ func (d *Daemon) runPortScan(ctx context.Context) error {
 			continue
 		}
 		
+		known := make(map[string]bool)
+		if prev, err := scanStorage.GetHostPorts(host.ID); err == nil {
+			for _, port := range prev {
+				known[fmt.Sprintf("%d/%s", port.Port, port.Protocol)] = true
+			}
+		}
+		
+		open := 0
 		for i := range ports {
 			port := &ports[i]
 			port.HostID = host.ID
+			// A closed UDP port drops what an earlier scan recorded for it
+			if port.State == probes.PortClosed {
+				if err := scanStorage.DeletePort(host.ID, port.Port, port.Protocol); err != nil {
+					util.Warn("Failed to remove closed port %d on %s: %v", port.Port, host.IP, err)
+				}
+				continue
+			}
+			open++
+			if !known[fmt.Sprintf("%d/%s", port.Port, port.Protocol)] {
+				newPorts++
+			}
 			if err := scanStorage.SavePort(port); err != nil {
 				util.Warn("Failed to save port %d on %s: %v", port.Port, host.IP, err)
 			}
 		}
 		
-		totalPorts += len(ports)
+		totalPorts += open
 	}
 	
 	util.Info("Port scan complete: %d open ports found", totalPorts)
+	if newPorts > 0 {
+		reportChange(ctx, "%d new open ports", newPorts)
+	}
 	
 	return nil
 }
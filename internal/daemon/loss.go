@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/user/netpulse/internal/probes"
+	"github.com/user/netpulse/internal/storage"
+	"github.com/user/netpulse/internal/util"
+)
+
+// runLossMonitor pings every trace target continuously and stores
+// per-minute loss, latency and jitter statistics until the daemon stops.
+func (d *Daemon) runLossMonitor(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, target := range d.config.TraceTargets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			d.monitorTarget(ctx, target)
+		}(target)
+	}
+	wg.Wait()
+}
+
+// monitorTarget streams pings to one target and flushes a summary at every
+// minute boundary.
+func (d *Daemon) monitorTarget(ctx context.Context, target string) {
+	stream := probes.NewLatencyStream(target, d.config.LossProbeMethod,
+		d.config.LossProbePort, d.config.LossProbeInterval)
+	lossStorage := storage.NewLossStorage(d.db)
+
+	var window []probes.LatencySample
+	minute := time.Now().Truncate(time.Minute)
+
+	flush := func() {
+		if len(window) == 0 {
+			return
+		}
+		stats := probes.SummarizeSamples(window)
+		stats.Target = target
+		stats.Method = stream.Method()
+		stats.Minute = minute
+		if err := lossStorage.Save(&stats); err != nil {
+			util.Warn("Failed to save loss stats for %s: %v", target, err)
+		}
+		window = window[:0]
+	}
+
+	for ctx.Err() == nil {
+		err := stream.Run(ctx, func(sample probes.LatencySample) {
+			if m := sample.Time.Truncate(time.Minute); m.After(minute) {
+				flush()
+				minute = m
+			}
+			window = append(window, sample)
+		})
+		if err == nil {
+			break
+		}
+
+		// Usually a resolution failure; try again later
+		util.Warn("Loss monitor for %s: %v", target, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Minute):
+		}
+	}
+	flush()
+}
@@ -0,0 +1,82 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+	"github.com/user/netpulse/internal/probes"
+	"github.com/user/netpulse/internal/storage"
+	"github.com/user/netpulse/internal/util"
+)
+
+// pmtuAnomalyData is stored as the data of path MTU anomalies.
+type pmtuAnomalyData struct {
+	Target       string    `json:"target"`
+	PMTU         int       `json:"pmtu"`
+	PreviousPMTU int       `json:"previous_pmtu,omitempty"`
+	ReportedBy   string    `json:"reported_by,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// runPMTUDiscovery measures the path MTU to every trace target and raises
+// anomalies when it drops or large packets start to vanish.
+func (d *Daemon) runPMTUDiscovery(ctx context.Context) error {
+	pmtuStorage := storage.NewPMTUStorage(d.db)
+	probe := probes.NewPMTUProbe()
+
+	for _, target := range d.config.TraceTargets {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		prev, err := pmtuStorage.GetLatest(target)
+		if err != nil {
+			util.Warn("Failed to load path MTU for %s: %v", target, err)
+		}
+
+		result := probe.Discover(ctx, target)
+		if err := pmtuStorage.Save(result); err != nil {
+			util.Warn("Failed to save path MTU for %s: %v", target, err)
+			continue
+		}
+		if !result.Success {
+			util.Warn("Path MTU discovery to %s failed: %s", target, result.Error)
+			continue
+		}
+
+		util.Debug("Path MTU to %s: %d (interface %d)", target, result.PMTU, result.LocalMTU)
+		if prev != nil && (prev.PMTU != result.PMTU || prev.BlackHole != result.BlackHole) {
+			reportChange(ctx, "path MTU to %s changed", target)
+		}
+		d.checkPMTUAnomalies(prev, result)
+	}
+
+	return nil
+}
+
+// checkPMTUAnomalies compares a result with the previous one for the target.
+func (d *Daemon) checkPMTUAnomalies(prev, result *model.PMTUResult) {
+	data := pmtuAnomalyData{
+		Target:     result.Target,
+		PMTU:       result.PMTU,
+		ReportedBy: result.ReportedBy,
+		Timestamp:  result.Timestamp,
+	}
+
+	if prev != nil && result.PMTU < prev.PMTU {
+		data.PreviousPMTU = prev.PMTU
+		description := fmt.Sprintf("Path MTU to %s dropped from %d to %d", result.Target, prev.PMTU, result.PMTU)
+		if result.ReportedBy != "" {
+			description += fmt.Sprintf(" (reported by %s)", result.ReportedBy)
+		}
+		d.raiseAnomaly(model.AnomalyPMTUDrop, "warning", description, data, time.Time{})
+	}
+
+	if result.BlackHole && (prev == nil || !prev.BlackHole) {
+		d.raiseAnomaly(model.AnomalyPMTUBlackHole, "critical",
+			fmt.Sprintf("Packets larger than %d bytes to %s are dropped without an ICMP error (PMTU black hole)",
+				result.PMTU, result.Target), data, time.Time{})
+	}
+}
@@ -2,24 +2,56 @@ package daemon
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/user/netpulse/internal/schedule"
 	"github.com/user/netpulse/internal/util"
 )
 
+// Maximum stretch of the interval of a stable adaptive job, as a multiple
+// of its base interval, when the config does not set one.
+const defaultStableIntervalMax = 8.0
+
+// startDelay is how long after boot jobs that run on start first run.
+const startDelay = 5 * time.Second
+
 // Job represents a scheduled job.
 type Job struct {
 	Name     string
 	Interval time.Duration
 	Run      func(ctx context.Context) error
 	
+	// Adaptive jobs report changes with reportChange; while they find
+	// none, their interval grows by the stable interval multiplier.
+	Adaptive bool
+	
+	// Jobs this one waits for: it does not start while any of them runs,
+	// so a run they trigger sees their results.
+	After []string
+	
+	// Timing from the job's entry in the config, set by AddJob
+	cron       *schedule.Cron    // Replaces Interval for picking run times
+	blackouts  []schedule.Window // No run starts inside these
+	jitter     time.Duration     // Random delay of up to this much per run
+	runOnStart bool
+	
 	// State
 	lastRun    time.Time
 	nextRun    time.Time
 	lastError  error
 	errorCount int
 	running    bool
+	interval   time.Duration // Effective interval; 0 until the first run
+	reason     string        // Why the effective interval was chosen
+	stableRuns int           // Runs without change since the last one
+	nextReason string        // How nextRun was picked
+	trigger    *jobTrigger   // Run requested by another job, if any
+	resume     time.Time     // Regular next run, kept during a triggered run
+	resumeWhy  string
 	mu         sync.RWMutex
 }
 
@@ -32,6 +64,82 @@ type JobStatus struct {
 	LastError  string        `json:"last_error,omitempty"`
 	ErrorCount int           `json:"error_count"`
 	Running    bool          `json:"running"`
+	
+	// Interval the job is currently run at, and why
+	EffectiveInterval time.Duration `json:"effective_interval"`
+	IntervalReason    string        `json:"interval_reason,omitempty"`
+	
+	// Configured timing, and how the next run was picked
+	Cron          string        `json:"cron,omitempty"`
+	Blackouts     []string      `json:"blackouts,omitempty"`
+	Jitter        time.Duration `json:"jitter,omitempty"`
+	RunOnStart    bool          `json:"run_on_start"`
+	NextRunReason string        `json:"next_run_reason,omitempty"`
+	After         []string      `json:"after,omitempty"`
+}
+
+// jobTrigger is a run requested by another job. Without targets it is a
+// full run; with targets, the job only covers those.
+type jobTrigger struct {
+	reason  string
+	targets []string
+}
+
+// merge adds another request to a pending one.
+func (t *jobTrigger) merge(reason string, targets []string) {
+	if !strings.Contains(t.reason, reason) {
+		t.reason += "; " + reason
+	}
+	if len(t.targets) == 0 || len(targets) == 0 {
+		t.targets = nil
+		return
+	}
+	for _, target := range targets {
+		if !containsString(t.targets, target) {
+			t.targets = append(t.targets, target)
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// jobTargetsKey is the context key of the targets of a triggered run.
+type jobTargetsKey struct{}
+
+// jobTargets returns the targets a triggered run is limited to, or nil for
+// a full run.
+func jobTargets(ctx context.Context) []string {
+	targets, _ := ctx.Value(jobTargetsKey{}).([]string)
+	return targets
+}
+
+// jobChangesKey is the context key of the changes reported by a run.
+type jobChangesKey struct{}
+
+// jobChanges collects what a job run found changed.
+type jobChanges struct {
+	mu      sync.Mutex
+	reasons []string
+}
+
+// reportChange tells the scheduler that the running job found a change,
+// such as a new public IP, so it is run at its base interval again. It
+// does nothing outside a scheduled run.
+func reportChange(ctx context.Context, format string, args ...interface{}) {
+	changes, ok := ctx.Value(jobChangesKey{}).(*jobChanges)
+	if !ok {
+		return
+	}
+	changes.mu.Lock()
+	changes.reasons = append(changes.reasons, fmt.Sprintf(format, args...))
+	changes.mu.Unlock()
 }
 
 // Scheduler manages scheduled jobs.
@@ -51,14 +159,87 @@ func NewScheduler(ctx context.Context, daemon *Daemon) *Scheduler {
 	}
 }
 
-// AddJob adds a job to the scheduler.
+// AddJob adds a job to the scheduler, with the timing of its entry in the
+// config, and schedules its first run.
 func (s *Scheduler) AddJob(job *Job) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	job.nextRun = time.Now().Add(time.Second * 5) // Initial delay
+	
+	// The config was validated on load
+	cfg, ok := s.daemon.config.Jobs[job.Name]
+	job.runOnStart = !ok || cfg.RunOnStart == nil || *cfg.RunOnStart
+	if ok {
+		if cfg.Cron != "" {
+			job.cron, _ = schedule.ParseCron(cfg.Cron)
+		}
+		for _, spec := range cfg.Blackouts {
+			if w, err := schedule.ParseWindow(spec); err == nil {
+				job.blackouts = append(job.blackouts, w)
+			}
+		}
+		job.jitter = cfg.Jitter
+	}
+	
+	now := time.Now()
+	if job.runOnStart {
+		next, why := job.addJitter(now.Add(startDelay), "run on start")
+		job.place(next, why, false)
+	} else {
+		job.scheduleNext(now, job.Interval, "")
+	}
 	s.jobs = append(s.jobs, job)
 }
 
+// scheduleNext picks the next run after from: the next cron time, or after
+// interval with why as the reason. The caller holds job.mu.
+func (job *Job) scheduleNext(from time.Time, interval time.Duration, why string) {
+	if job.cron != nil {
+		next, why := job.addJitter(job.cron.Next(from), "cron "+job.cron.String())
+		job.place(next, why, true)
+		return
+	}
+	if why == "" {
+		why = "every " + interval.String()
+	}
+	next, why := job.addJitter(from.Add(interval), why)
+	job.place(next, why, false)
+}
+
+// addJitter delays a regular run by a random part of the job's jitter.
+func (job *Job) addJitter(next time.Time, why string) (time.Time, string) {
+	if job.jitter <= 0 {
+		return next, why
+	}
+	delay := time.Duration(rand.Int63n(int64(job.jitter)))
+	return next.Add(delay), why + fmt.Sprintf(" + %s jitter", delay.Round(time.Second))
+}
+
+// place sets the next run, moved to the end of any blackout it falls in
+// and, for cron times, on to the next cron time. The caller holds job.mu.
+func (job *Job) place(next time.Time, why string, onCron bool) {
+	// Windows can overlap or follow each other, so check again after
+	// each move; the bound keeps a blackout covering every day from
+	// stopping the job altogether.
+	for i := 0; i < 10; i++ {
+		moved := false
+		for _, w := range job.blackouts {
+			end, ok := w.End(next)
+			if !ok {
+				continue
+			}
+			next, moved = end, true
+			if onCron {
+				next = job.cron.Next(end.Add(-time.Nanosecond))
+			}
+			why += ", postponed by blackout " + w.String()
+		}
+		if !moved {
+			break
+		}
+	}
+	job.nextRun, job.nextReason = next, why
+}
+
 // Run starts the scheduler.
 func (s *Scheduler) Run() {
 	ticker := time.NewTicker(time.Second)
@@ -82,12 +263,26 @@ func (s *Scheduler) checkJobs(now time.Time) {
 	jobs := s.jobs
 	s.mu.RUnlock()
 	
+	running := make(map[string]bool)
+	for _, job := range jobs {
+		job.mu.RLock()
+		running[job.Name] = job.running
+		job.mu.RUnlock()
+	}
+	
 	for _, job := range jobs {
 		job.mu.RLock()
 		shouldRun := !job.running && now.After(job.nextRun)
 		job.mu.RUnlock()
 		
+		for _, dep := range job.After {
+			if running[dep] {
+				shouldRun = false
+			}
+		}
+		
 		if shouldRun {
+			running[job.Name] = true
 			go s.runJob(job)
 		}
 	}
@@ -101,12 +296,29 @@ func (s *Scheduler) runJob(job *Job) {
 	}
 	job.running = true
 	job.lastRun = time.Now()
+	trigger := job.trigger
+	job.trigger = nil
+	if trigger != nil && len(trigger.targets) > 0 && !job.lastRun.Before(job.resume) {
+		// The regular run is due as well, as on boot or after waiting for
+		// the jobs this one runs after: one full run covers both
+		trigger.targets = nil
+		trigger.reason += "; regular run due"
+	}
 	job.mu.Unlock()
 	
-	util.Debug("Running job: %s", job.Name)
+	var targets []string
+	if trigger != nil {
+		targets = trigger.targets
+		util.Debug("Running job: %s (%s)", job.Name, trigger.reason)
+	} else {
+		util.Debug("Running job: %s", job.Name)
+	}
 	
 	// Create job context with timeout
-	ctx, cancel := context.WithTimeout(s.ctx, job.Interval)
+	changes := &jobChanges{}
+	ctx := context.WithValue(s.ctx, jobChangesKey{}, changes)
+	ctx = context.WithValue(ctx, jobTargetsKey{}, targets)
+	ctx, cancel := context.WithTimeout(ctx, job.Interval)
 	defer cancel()
 	
 	err := job.Run(ctx)
@@ -117,15 +329,105 @@ func (s *Scheduler) runJob(job *Job) {
 		job.lastError = err
 		job.errorCount++
 		util.Warn("Job %s failed: %v", job.Name, err)
-		// Shorter retry on error
-		job.nextRun = time.Now().Add(job.Interval / 2)
 	} else {
 		job.lastError = nil
 		util.Debug("Job %s completed successfully", job.Name)
-		// Adaptive interval: longer if stable
-		job.nextRun = time.Now().Add(job.Interval)
+	}
+	switch {
+	case len(targets) > 0:
+		// A run limited to some targets leaves the regular schedule as is
+		job.nextRun, job.nextReason = job.resume, job.resumeWhy
+	case err != nil:
+		// Shorter retry on error
+		job.interval, job.stableRuns = job.Interval, 0
+		job.reason = "retrying after error"
+		job.scheduleNext(time.Now(), job.Interval/2, "retry after error")
+	default:
+		s.adaptInterval(job, changes.reasons)
+		job.scheduleNext(time.Now(), job.interval, "")
+	}
+	if job.trigger != nil {
+		// Triggered again while running
+		job.holdForTrigger()
 	}
 	job.mu.Unlock()
+	
+	s.daemon.writeStatus()
+}
+
+// adaptInterval sets the effective interval after a successful run:
+// adaptive jobs back off while stable and return to the base interval as
+// soon as they report a change. The caller holds job.mu.
+func (s *Scheduler) adaptInterval(job *Job, changes []string) {
+	multiplier := s.daemon.config.StableIntervalMultiplier
+	max := s.daemon.config.StableIntervalMax
+	if max <= 0 {
+		max = defaultStableIntervalMax
+	}
+	
+	switch {
+	case job.cron != nil:
+		job.interval, job.reason = job.Interval, "cron"
+	case !job.Adaptive || multiplier <= 1:
+		job.interval, job.reason = job.Interval, "fixed"
+	case len(changes) > 0:
+		job.interval, job.stableRuns = job.Interval, 0
+		job.reason = "changed: " + strings.Join(changes, "; ")
+		util.Debug("Job %s back to %s: %s", job.Name, job.interval, job.reason)
+	case job.interval == 0:
+		// First run: nothing to compare against yet
+		job.interval, job.reason = job.Interval, "first run"
+	default:
+		job.stableRuns++
+		interval := time.Duration(float64(job.interval) * multiplier)
+		if limit := time.Duration(float64(job.Interval) * max); interval > limit {
+			interval = limit
+		}
+		job.interval = interval
+		job.reason = "stable since the last run"
+		if job.stableRuns > 1 {
+			job.reason = fmt.Sprintf("stable for %d runs", job.stableRuns)
+		}
+	}
+}
+
+// Trigger runs a job as soon as it and the jobs it runs after are idle,
+// outside its blackouts but regardless of its cron schedule. With targets,
+// the run covers only those and the regular schedule is kept, unless the
+// regular run is due by then and it becomes a full run. Requests made
+// before the run starts are merged. It returns false if there is no such
+// job.
+func (s *Scheduler) Trigger(name, reason string, targets ...string) bool {
+	job := s.GetJob(name)
+	if job == nil {
+		return false
+	}
+	
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	switch {
+	case job.trigger != nil:
+		job.trigger.merge(reason, targets)
+		if !job.running {
+			job.place(time.Now(), "triggered: "+job.trigger.reason, false)
+		}
+		return true
+	case job.running:
+		// Run again when this run ends
+		job.trigger = &jobTrigger{reason: reason, targets: targets}
+	default:
+		job.trigger = &jobTrigger{reason: reason, targets: targets}
+		job.holdForTrigger()
+	}
+	util.Debug("Job %s triggered: %s", job.Name, reason)
+	return true
+}
+
+// holdForTrigger keeps the regular next run aside and schedules the
+// triggered one now. The caller holds job.mu.
+func (job *Job) holdForTrigger() {
+	job.resume, job.resumeWhy = job.nextRun, job.nextReason
+	job.place(time.Now(), "triggered: "+job.trigger.reason, false)
 }
 
 // GetJobStatuses returns the status of all jobs.
@@ -143,6 +445,23 @@ func (s *Scheduler) GetJobStatuses() []JobStatus {
 			NextRun:    job.nextRun,
 			ErrorCount: job.errorCount,
 			Running:    job.running,
+			
+			EffectiveInterval: job.interval,
+			IntervalReason:    job.reason,
+			
+			Jitter:        job.jitter,
+			RunOnStart:    job.runOnStart,
+			NextRunReason: job.nextReason,
+			After:         job.After,
+		}
+		if job.cron != nil {
+			status.Cron = job.cron.String()
+		}
+		for _, w := range job.blackouts {
+			status.Blackouts = append(status.Blackouts, w.String())
+		}
+		if status.EffectiveInterval == 0 {
+			status.EffectiveInterval = job.Interval
 		}
 		if job.lastError != nil {
 			status.LastError = job.lastError.Error()
@@ -175,7 +494,7 @@ func (s *Scheduler) TriggerJob(name string) bool {
 	}
 	
 	job.mu.Lock()
-	job.nextRun = time.Now()
+	job.nextRun, job.nextReason = time.Now(), "triggered"
 	job.mu.Unlock()
 	
 	return true
@@ -0,0 +1,91 @@
+package daemon
+
+import (
+	"context"
+
+	"github.com/user/netpulse/internal/probes"
+	"github.com/user/netpulse/internal/storage"
+	"github.com/user/netpulse/internal/util"
+)
+
+// throughputTests are run against every server, one after another so they
+// do not compete for the link.
+var throughputTests = []struct{ protocol, direction string }{
+	{probes.ThroughputTCP, probes.DirectionDownload},
+	{probes.ThroughputTCP, probes.DirectionUpload},
+	{probes.ThroughputUDP, probes.DirectionUpload},
+}
+
+func (d *Daemon) runThroughputTests(ctx context.Context) error {
+	d.linkTest.Lock()
+	defer d.linkTest.Unlock()
+
+	throughputStorage := storage.NewThroughputStorage(d.db)
+
+	for _, server := range d.config.ThroughputServers {
+		for _, t := range throughputTests {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			result := probes.RunThroughputTest(ctx, probes.ThroughputTest{
+				Server:    server,
+				Port:      d.config.ThroughputPort,
+				Protocol:  t.protocol,
+				Direction: t.direction,
+				Duration:  d.config.ThroughputDuration,
+				UDPRate:   int64(d.config.ThroughputUDPMbps) * 1_000_000,
+			})
+
+			if err := throughputStorage.Save(result); err != nil {
+				util.Warn("Failed to save throughput test to %s: %v", server, err)
+				continue
+			}
+
+			if !result.Success {
+				util.Warn("Throughput %s %s to %s failed: %s", t.protocol, t.direction, server, result.Error)
+				// The server is unreachable; skip its remaining tests
+				break
+			}
+			util.Debug("Throughput %s %s to %s: %.1f Mbps", t.protocol, t.direction, server, result.Mbps)
+		}
+	}
+
+	return nil
+}
+
+// runBufferbloatTest measures latency to a trace target on the idle link and
+// while the first throughput server saturates it.
+func (d *Daemon) runBufferbloatTest(ctx context.Context) error {
+	target := d.config.BufferbloatTarget
+	if target == "" && len(d.config.TraceTargets) > 0 {
+		target = d.config.TraceTargets[0]
+	}
+	if target == "" || len(d.config.ThroughputServers) == 0 {
+		return nil
+	}
+
+	d.linkTest.Lock()
+	defer d.linkTest.Unlock()
+
+	result := probes.RunBufferbloatTest(ctx, probes.BufferbloatTest{
+		Target:     target,
+		Server:     d.config.ThroughputServers[0],
+		ServerPort: d.config.ThroughputPort,
+		Duration:   d.config.ThroughputDuration,
+		Method:     d.config.LossProbeMethod,
+		Port:       d.config.LossProbePort,
+	})
+
+	if err := storage.NewBufferbloatStorage(d.db).Save(result); err != nil {
+		return err
+	}
+	if !result.Success {
+		util.Warn("Bufferbloat test to %s failed: %s", target, result.Error)
+		return nil
+	}
+
+	util.Info("Bufferbloat grade %s: %s idle %.1f ms, download %.1f ms, upload %.1f ms",
+		result.Grade, target, result.IdleMs, result.DownloadMs, result.UploadMs)
+	return nil
+}
@@ -0,0 +1,381 @@
+// Package dnsmsg encodes and decodes DNS wire-format messages (RFC 1035).
+// It covers what netpulse's probes need: queries, name compression on
+// decode, and the common record types.
+package dnsmsg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Record types.
+const (
+	TypeA     uint16 = 1
+	TypeNS    uint16 = 2
+	TypeCNAME uint16 = 5
+	TypeSOA   uint16 = 6
+	TypePTR   uint16 = 12
+	TypeMX    uint16 = 15
+	TypeTXT   uint16 = 16
+	TypeAAAA  uint16 = 28
+	TypeSRV   uint16 = 33
+	TypeANY   uint16 = 255
+)
+
+// Classes.
+const (
+	ClassINET  uint16 = 1
+	ClassCHAOS uint16 = 3
+
+	// ClassUnicastResponse is the mDNS "QU" bit asking for a unicast reply.
+	ClassUnicastResponse uint16 = 0x8000
+)
+
+// Response codes.
+const (
+	RcodeSuccess  = 0
+	RcodeFormErr  = 1
+	RcodeServFail = 2
+	RcodeNXDomain = 3
+	RcodeNotImp   = 4
+	RcodeRefused  = 5
+)
+
+// Header flag bits.
+const (
+	flagQR = 1 << 15
+	flagAA = 1 << 10
+	flagTC = 1 << 9
+	flagRD = 1 << 8
+	flagRA = 1 << 7
+)
+
+var (
+	errTruncated = errors.New("dns message truncated")
+	errPointer   = errors.New("invalid compression pointer")
+)
+
+// Question is an entry of the question section.
+type Question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// RR is a resource record. Data holds the raw RDATA; the typed fields are
+// filled for the record types listed above.
+type RR struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	Data  []byte
+
+	IP     net.IP   // A, AAAA
+	Target string   // PTR, CNAME, NS, MX exchange, SRV target
+	TXT    []string // TXT
+	Port   uint16   // SRV
+}
+
+// Message is a DNS message.
+type Message struct {
+	ID                 uint16
+	Response           bool
+	Opcode             int
+	Authoritative      bool
+	Truncated          bool
+	RecursionDesired   bool
+	RecursionAvailable bool
+	Rcode              int
+
+	Questions  []Question
+	Answers    []RR
+	Authority  []RR
+	Additional []RR
+}
+
+// NewQuery returns a recursive query for a single question.
+func NewQuery(id uint16, name string, qtype, qclass uint16) *Message {
+	return &Message{
+		ID:               id,
+		RecursionDesired: true,
+		Questions:        []Question{{Name: name, Type: qtype, Class: qclass}},
+	}
+}
+
+// Pack encodes the message. Only the header and question section are
+// written; netpulse never sends records.
+func (m *Message) Pack() ([]byte, error) {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:2], m.ID)
+	binary.BigEndian.PutUint16(b[2:4], m.flags())
+	binary.BigEndian.PutUint16(b[4:6], uint16(len(m.Questions)))
+
+	for _, q := range m.Questions {
+		var err error
+		if b, err = appendName(b, q.Name); err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint16(b, q.Type)
+		b = binary.BigEndian.AppendUint16(b, q.Class)
+	}
+	return b, nil
+}
+
+func (m *Message) flags() uint16 {
+	f := uint16(m.Opcode&0xf)<<11 | uint16(m.Rcode&0xf)
+	if m.Response {
+		f |= flagQR
+	}
+	if m.Authoritative {
+		f |= flagAA
+	}
+	if m.Truncated {
+		f |= flagTC
+	}
+	if m.RecursionDesired {
+		f |= flagRD
+	}
+	if m.RecursionAvailable {
+		f |= flagRA
+	}
+	return f
+}
+
+// appendName appends a domain name in uncompressed wire format.
+func appendName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, fmt.Errorf("invalid label in %q", name)
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+// Unpack decodes a DNS message.
+func Unpack(b []byte) (*Message, error) {
+	if len(b) < 12 {
+		return nil, errTruncated
+	}
+	flags := binary.BigEndian.Uint16(b[2:4])
+	m := &Message{
+		ID:                 binary.BigEndian.Uint16(b[0:2]),
+		Response:           flags&flagQR != 0,
+		Opcode:             int(flags>>11) & 0xf,
+		Authoritative:      flags&flagAA != 0,
+		Truncated:          flags&flagTC != 0,
+		RecursionDesired:   flags&flagRD != 0,
+		RecursionAvailable: flags&flagRA != 0,
+		Rcode:              int(flags & 0xf),
+	}
+	counts := [4]int{}
+	for i := range counts {
+		counts[i] = int(binary.BigEndian.Uint16(b[4+2*i:]))
+	}
+
+	off := 12
+	for i := 0; i < counts[0]; i++ {
+		name, n, err := readName(b, off)
+		if err != nil {
+			return nil, err
+		}
+		off = n
+		if off+4 > len(b) {
+			return nil, errTruncated
+		}
+		m.Questions = append(m.Questions, Question{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(b[off:]),
+			Class: binary.BigEndian.Uint16(b[off+2:]),
+		})
+		off += 4
+	}
+
+	sections := []*[]RR{&m.Answers, &m.Authority, &m.Additional}
+	for i, section := range sections {
+		for j := 0; j < counts[i+1]; j++ {
+			rr, n, err := readRR(b, off)
+			if err != nil {
+				return nil, err
+			}
+			off = n
+			*section = append(*section, rr)
+		}
+	}
+
+	return m, nil
+}
+
+// readRR decodes the resource record at off.
+func readRR(b []byte, off int) (RR, int, error) {
+	var rr RR
+	name, off, err := readName(b, off)
+	if err != nil {
+		return rr, 0, err
+	}
+	if off+10 > len(b) {
+		return rr, 0, errTruncated
+	}
+	rr.Name = name
+	rr.Type = binary.BigEndian.Uint16(b[off:])
+	rr.Class = binary.BigEndian.Uint16(b[off+2:])
+	rr.TTL = binary.BigEndian.Uint32(b[off+4:])
+	length := int(binary.BigEndian.Uint16(b[off+8:]))
+	off += 10
+	if off+length > len(b) {
+		return rr, 0, errTruncated
+	}
+	rr.Data = b[off : off+length]
+
+	switch rr.Type {
+	case TypeA:
+		if length == 4 {
+			rr.IP = net.IP(append([]byte(nil), rr.Data...))
+		}
+	case TypeAAAA:
+		if length == 16 {
+			rr.IP = net.IP(append([]byte(nil), rr.Data...))
+		}
+	case TypePTR, TypeCNAME, TypeNS:
+		rr.Target, _, err = readName(b, off)
+	case TypeMX:
+		if length > 2 {
+			rr.Target, _, err = readName(b, off+2)
+		}
+	case TypeSRV:
+		if length > 6 {
+			rr.Port = binary.BigEndian.Uint16(rr.Data[4:6])
+			rr.Target, _, err = readName(b, off+6)
+		}
+	case TypeTXT:
+		for data := rr.Data; len(data) > 0; {
+			n := int(data[0])
+			if 1+n > len(data) {
+				return rr, 0, errTruncated
+			}
+			rr.TXT = append(rr.TXT, string(data[1:1+n]))
+			data = data[1+n:]
+		}
+	}
+	if err != nil {
+		return rr, 0, err
+	}
+
+	return rr, off + length, nil
+}
+
+// readName decodes a possibly compressed name at off and returns it with
+// the offset following it.
+func readName(b []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for hops := 0; ; hops++ {
+		if off >= len(b) {
+			return "", 0, errTruncated
+		}
+		c := int(b[off])
+		switch {
+		case c == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case c&0xc0 == 0xc0:
+			if off+1 >= len(b) || hops > 64 {
+				return "", 0, errPointer
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = (c&0x3f)<<8 | int(b[off+1])
+		default:
+			if off+1+c > len(b) {
+				return "", 0, errTruncated
+			}
+			labels = append(labels, string(b[off+1:off+1+c]))
+			off += 1 + c
+		}
+	}
+}
+
+// TypeString returns the mnemonic of a record type.
+func TypeString(t uint16) string {
+	switch t {
+	case TypeA:
+		return "A"
+	case TypeNS:
+		return "NS"
+	case TypeCNAME:
+		return "CNAME"
+	case TypeSOA:
+		return "SOA"
+	case TypePTR:
+		return "PTR"
+	case TypeMX:
+		return "MX"
+	case TypeTXT:
+		return "TXT"
+	case TypeAAAA:
+		return "AAAA"
+	case TypeSRV:
+		return "SRV"
+	case TypeANY:
+		return "ANY"
+	}
+	return fmt.Sprintf("TYPE%d", t)
+}
+
+// ParseType returns the record type for a mnemonic such as "AAAA".
+func ParseType(s string) (uint16, bool) {
+	for _, t := range []uint16{TypeA, TypeNS, TypeCNAME, TypeSOA, TypePTR, TypeMX, TypeTXT, TypeAAAA, TypeSRV, TypeANY} {
+		if strings.EqualFold(TypeString(t), s) {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// RcodeString returns the mnemonic of a response code.
+func RcodeString(rcode int) string {
+	switch rcode {
+	case RcodeSuccess:
+		return "NOERROR"
+	case RcodeFormErr:
+		return "FORMERR"
+	case RcodeServFail:
+		return "SERVFAIL"
+	case RcodeNXDomain:
+		return "NXDOMAIN"
+	case RcodeNotImp:
+		return "NOTIMP"
+	case RcodeRefused:
+		return "REFUSED"
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// ReverseName returns the in-addr.arpa or ip6.arpa name for a PTR lookup.
+func ReverseName(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", v4[3], v4[2], v4[1], v4[0])
+	}
+	const hex = "0123456789abcdef"
+	var sb strings.Builder
+	ip = ip.To16()
+	for i := len(ip) - 1; i >= 0; i-- {
+		sb.WriteByte(hex[ip[i]&0xf])
+		sb.WriteByte('.')
+		sb.WriteByte(hex[ip[i]>>4])
+		sb.WriteByte('.')
+	}
+	sb.WriteString("ip6.arpa.")
+	return sb.String()
+}
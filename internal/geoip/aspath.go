@@ -0,0 +1,101 @@
+package geoip
+
+import (
+	"context"
+	"net"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// AnnotateTrace sets the origin AS of every answered hop and the AS path
+// of the trace.
+func (r *Resolver) AnnotateTrace(ctx context.Context, trace *model.TraceResult) {
+	asns := make(map[string]string)
+	for i := range trace.Hops {
+		hop := &trace.Hops[i]
+		if hop.Lost || hop.IP == "" {
+			continue
+		}
+		asn, ok := asns[hop.IP]
+		if !ok {
+			if info, err := r.Lookup(ctx, hop.IP); err == nil {
+				asn = info.ASN
+			}
+			asns[hop.IP] = asn
+		}
+		hop.ASN = asn
+	}
+	trace.ASPath = ASPath(trace.Hops)
+}
+
+// UnknownAS stands in an AS path for hops whose network is not known: lost
+// hops and public addresses the resolver has no ASN for.
+const UnknownAS = "*"
+
+// ASPath returns the ASNs the hops pass through, in order, with repeats
+// removed and UnknownAS for unknown stretches. Private addresses belong to
+// no AS and are skipped. A trace that reached no known AS has no path.
+func ASPath(hops []model.TraceHop) []string {
+	var path []string
+	known := false
+	for _, hop := range hops {
+		asn := hop.ASN
+		if asn == "" {
+			if ip := net.ParseIP(hop.IP); ip != nil && (ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast()) {
+				continue
+			}
+			asn = UnknownAS
+		} else {
+			known = true
+			// Unknown hops inside one AS, such as routers that do not
+			// answer, do not split it
+			if n := len(path); n >= 2 && path[n-1] == UnknownAS && path[n-2] == asn {
+				path = path[:n-1]
+			}
+		}
+		if len(path) > 0 && path[len(path)-1] == asn {
+			continue
+		}
+		path = append(path, asn)
+	}
+	if !known {
+		return nil
+	}
+	return path
+}
+
+// ASPathChanged reports whether the traffic moved to other networks: an AS
+// was added, dropped or replaced. UnknownAS may stand for any number of
+// ASNs, so a path that only lacks ASNs where the other has unknown hops is
+// not a change.
+func ASPathChanged(old, new []string) bool {
+	if len(old) == 0 || len(new) == 0 {
+		return false
+	}
+	return !pathsMatch(old, new)
+}
+
+// pathsMatch reports whether some route fits both paths, UnknownAS
+// matching zero or more ASNs.
+func pathsMatch(a, b []string) bool {
+	// match[i][j]: a[i:] and b[j:] match
+	match := make([][]bool, len(a)+1)
+	for i := range match {
+		match[i] = make([]bool, len(b)+1)
+	}
+	match[len(a)][len(b)] = true
+	for i := len(a); i >= 0; i-- {
+		for j := len(b); j >= 0; j-- {
+			switch {
+			case i == len(a) && j == len(b):
+			case i < len(a) && a[i] == UnknownAS:
+				match[i][j] = match[i+1][j] || (j < len(b) && match[i][j+1])
+			case j < len(b) && b[j] == UnknownAS:
+				match[i][j] = match[i][j+1] || (i < len(a) && match[i+1][j])
+			case i < len(a) && j < len(b):
+				match[i][j] = a[i] == b[j] && match[i+1][j+1]
+			}
+		}
+	}
+	return match[0][0]
+}
@@ -0,0 +1,268 @@
+// Package geoip looks up the network owner and location of IP addresses
+// in local MaxMind DB and ip2asn files, with ip-api.com as an opt-in
+// fallback whose answers are kept in a persistent cache.
+package geoip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// Sources of lookup results.
+const (
+	SourceMMDB   = "mmdb"
+	SourceIP2ASN = "ip2asn"
+	SourceOnline = "online"
+)
+
+// CacheTTL is how long online answers are reused.
+const CacheTTL = 30 * 24 * time.Hour
+
+// ErrNotFound is returned for private addresses and addresses no source
+// knows about.
+var ErrNotFound = errors.New("no geolocation data for address")
+
+// Cache persists online lookups.
+type Cache interface {
+	// Get returns the entry for ip saved after since, or nil.
+	Get(ip string, since time.Time) (*model.GeoInfo, error)
+	Save(info *model.GeoInfo) error
+}
+
+// Resolver looks up addresses in the local databases first, then in the
+// cache and, when enabled, online.
+type Resolver struct {
+	*databases
+	cache  Cache
+	online *onlineClient // nil when disabled
+}
+
+// databases are the files loaded from one directory.
+type databases struct {
+	mmdbs []*mmdbFile
+	asns  *asnTable
+	files []string
+	err   error
+}
+
+// The daemon and the web server share the databases of a directory, which
+// can be hundreds of megabytes.
+var (
+	loadMu sync.Mutex
+	loaded = make(map[string]*databases)
+)
+
+// Open loads every *.mmdb and ip2asn*.tsv[.gz] file in dir. The resolver is
+// usable even when some files fail to load; the error lists them.
+func Open(dir string, cache Cache) (*Resolver, error) {
+	loadMu.Lock()
+	dbs, ok := loaded[dir]
+	if !ok {
+		dbs = load(dir)
+		loaded[dir] = dbs
+	}
+	loadMu.Unlock()
+
+	return &Resolver{databases: dbs, cache: cache}, dbs.err
+}
+
+func load(dir string) *databases {
+	dbs := &databases{}
+	var errs []error
+
+	mmdbs, _ := filepath.Glob(filepath.Join(dir, "*.mmdb"))
+	for _, path := range mmdbs {
+		db, err := openMMDB(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		dbs.mmdbs = append(dbs.mmdbs, db)
+		dbs.files = append(dbs.files, filepath.Base(path))
+	}
+
+	tables, _ := filepath.Glob(filepath.Join(dir, "ip2asn*.tsv"))
+	gzipped, _ := filepath.Glob(filepath.Join(dir, "ip2asn*.tsv.gz"))
+	tables = append(tables, gzipped...)
+	if len(tables) > 0 {
+		t, err := loadASNTable(tables...)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			dbs.asns = t
+			for _, path := range tables {
+				dbs.files = append(dbs.files, filepath.Base(path))
+			}
+		}
+	}
+
+	dbs.err = errors.Join(errs...)
+	return dbs
+}
+
+// SetOnline enables or disables the ip-api.com fallback.
+func (r *Resolver) SetOnline(enabled bool) {
+	if enabled {
+		r.online = newOnlineClient()
+	} else {
+		r.online = nil
+	}
+}
+
+// Files returns the names of the loaded database files.
+func (r *Resolver) Files() []string {
+	return r.files
+}
+
+// Lookup returns what the local databases know about ip. Owner or location
+// missing from them is filled in from the cache or, when enabled, online.
+// The online service is rate limited; lookups over the limit return the
+// local data only.
+func (r *Resolver) Lookup(ctx context.Context, ip string) (*model.GeoInfo, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP address %q", ip)
+	}
+	addr = addr.Unmap()
+	if !isPublic(addr) {
+		return nil, ErrNotFound
+	}
+
+	info := &model.GeoInfo{IP: addr.String(), UpdatedAt: time.Now()}
+	var sources []string
+	for _, db := range r.mmdbs {
+		record, err := db.lookup(addr)
+		if err == nil && record != nil && fromMMDB(info, record) {
+			sources = appendSource(sources, SourceMMDB)
+		}
+	}
+	if r.asns != nil && info.ASN == "" {
+		if rng := r.asns.lookup(addr); rng != nil {
+			info.ASN, info.Org = rng.asn, rng.org
+			if info.CountryCode == "" {
+				info.CountryCode = rng.countryCode
+			}
+			sources = appendSource(sources, SourceIP2ASN)
+		}
+	}
+
+	if !complete(info) {
+		if extra := r.remote(ctx, info.IP); extra != nil {
+			merge(info, extra)
+			sources = appendSource(sources, SourceOnline)
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, ErrNotFound
+	}
+	info.Source = strings.Join(sources, ",")
+	return info, nil
+}
+
+// remote returns the cached online answer for ip, or asks for a new one
+// when the fallback is enabled.
+func (r *Resolver) remote(ctx context.Context, ip string) *model.GeoInfo {
+	if r.cache != nil {
+		if cached, err := r.cache.Get(ip, time.Now().Add(-CacheTTL)); err == nil && cached != nil {
+			return cached
+		}
+	}
+	if r.online == nil {
+		return nil
+	}
+	info, err := r.online.lookup(ctx, ip)
+	if err != nil {
+		return nil
+	}
+	if r.cache != nil {
+		r.cache.Save(info)
+	}
+	return info
+}
+
+// fromMMDB copies the fields of a GeoIP2/GeoLite2 City, Country, ASN or ISP
+// record and reports whether there were any.
+func fromMMDB(info *model.GeoInfo, record map[string]interface{}) bool {
+	found := false
+	set := func(dst *string, v interface{}) {
+		if s, ok := v.(string); ok && s != "" && *dst == "" {
+			*dst = s
+			found = true
+		}
+	}
+
+	if n := toUint(record["autonomous_system_number"]); n != 0 && info.ASN == "" {
+		info.ASN = fmt.Sprintf("AS%d", n)
+		found = true
+	}
+	set(&info.Org, record["autonomous_system_organization"])
+	set(&info.Org, record["organization"])
+	set(&info.ISP, record["isp"])
+
+	country := lookupPath(record, "country")
+	if country == nil {
+		country = lookupPath(record, "registered_country")
+	}
+	if m, ok := country.(map[string]interface{}); ok {
+		set(&info.CountryCode, m["iso_code"])
+		set(&info.Country, lookupPath(m, "names", "en"))
+	}
+	set(&info.City, lookupPath(record, "city", "names", "en"))
+
+	lat, okLat := lookupPath(record, "location", "latitude").(float64)
+	lon, okLon := lookupPath(record, "location", "longitude").(float64)
+	if okLat && okLon && info.Lat == 0 && info.Lon == 0 {
+		info.Lat, info.Lon = lat, lon
+		found = true
+	}
+	return found
+}
+
+// complete reports whether info has both an owner and a location.
+func complete(info *model.GeoInfo) bool {
+	return info.ASN != "" && (info.Lat != 0 || info.Lon != 0)
+}
+
+// merge fills the fields of info that are still empty from extra.
+func merge(info, extra *model.GeoInfo) {
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&info.ASN, extra.ASN)
+	fill(&info.Org, extra.Org)
+	fill(&info.ISP, extra.ISP)
+	fill(&info.Country, extra.Country)
+	fill(&info.CountryCode, extra.CountryCode)
+	fill(&info.City, extra.City)
+	if info.Lat == 0 && info.Lon == 0 {
+		info.Lat, info.Lon = extra.Lat, extra.Lon
+	}
+}
+
+func appendSource(sources []string, source string) []string {
+	for _, s := range sources {
+		if s == source {
+			return sources
+		}
+	}
+	return append(sources, source)
+}
+
+// cgnat is the shared address space of carrier-grade NAT (RFC 6598).
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublic reports whether addr can be found in public registries.
+func isPublic(addr netip.Addr) bool {
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !cgnat.Contains(addr)
+}
@@ -0,0 +1,101 @@
+package geoip
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// asnRange is one line of an ip2asn table.
+type asnRange struct {
+	start, end  netip.Addr
+	asn         string
+	countryCode string
+	org         string
+}
+
+// asnTable maps address ranges to autonomous systems, as published by
+// iptoasn.com in ip2asn-v4.tsv, ip2asn-v6.tsv and ip2asn-combined.tsv.
+type asnTable struct {
+	ranges []asnRange // Sorted by start, not overlapping
+}
+
+// loadASNTable reads ip2asn TSV files, plain or gzipped, into one table.
+func loadASNTable(paths ...string) (*asnTable, error) {
+	t := &asnTable{}
+	for _, path := range paths {
+		if err := t.load(path); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	sort.Slice(t.ranges, func(i, j int) bool {
+		return t.ranges[i].start.Less(t.ranges[j].start)
+	})
+	return t, nil
+}
+
+func (t *asnTable) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	// Lines are "range_start range_end AS_number country_code AS_description"
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 5)
+		if len(fields) < 5 || fields[2] == "0" {
+			continue // AS 0 marks unrouted space
+		}
+		start, err1 := netip.ParseAddr(fields[0])
+		end, err2 := netip.ParseAddr(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		country := fields[3]
+		if country == "None" || country == "Unknown" {
+			country = ""
+		}
+		t.ranges = append(t.ranges, asnRange{
+			start:       start.Unmap(),
+			end:         end.Unmap(),
+			asn:         "AS" + fields[2],
+			countryCode: country,
+			org:         strings.TrimSpace(fields[4]),
+		})
+	}
+	return scanner.Err()
+}
+
+// lookup returns the range containing ip, or nil if it is not routed.
+func (t *asnTable) lookup(ip netip.Addr) *asnRange {
+	ip = ip.Unmap()
+	// First range starting after ip; the one before may contain it
+	i := sort.Search(len(t.ranges), func(i int) bool {
+		return ip.Less(t.ranges[i].start)
+	})
+	if i == 0 {
+		return nil
+	}
+	r := &t.ranges[i-1]
+	if r.end.BitLen() != ip.BitLen() || r.end.Less(ip) {
+		return nil
+	}
+	return r
+}
@@ -0,0 +1,312 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+)
+
+// metadataMarker starts the metadata section at the end of an MMDB file.
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// errCorrupt is returned for MMDB data that points outside the file.
+var errCorrupt = errors.New("corrupt MMDB data")
+
+// mmdbFile is a MaxMind DB file (GeoLite2, GeoIP2, DB-IP and compatible
+// databases) read into memory.
+type mmdbFile struct {
+	dbType     string // database_type from the metadata, e.g. "GeoLite2-City"
+	buf        []byte
+	data       []byte // Data section
+	nodeCount  uint32
+	recordSize int
+	ipVersion  int
+	ipv4Start  uint32 // Node of ::/96, where IPv4 addresses start
+}
+
+// openMMDB reads a MaxMind DB file.
+func openMMDB(path string) (*mmdbFile, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseMMDB(buf)
+}
+
+func parseMMDB(buf []byte) (*mmdbFile, error) {
+	start := bytes.LastIndex(buf, metadataMarker)
+	if start < 0 {
+		return nil, fmt.Errorf("no MaxMind DB metadata")
+	}
+	d := decoder{buf: buf[start+len(metadataMarker):]}
+	v, _, err := d.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	meta, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("metadata is not a map")
+	}
+
+	db := &mmdbFile{
+		buf:        buf,
+		nodeCount:  uint32(toUint(meta["node_count"])),
+		recordSize: int(toUint(meta["record_size"])),
+		ipVersion:  int(toUint(meta["ip_version"])),
+	}
+	db.dbType, _ = meta["database_type"].(string)
+
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", db.recordSize)
+	}
+	// The data section follows the search tree and 16 zero bytes
+	treeSize := int(db.nodeCount) * db.recordSize / 4
+	if treeSize+16 > start {
+		return nil, errCorrupt
+	}
+	db.data = buf[treeSize+16 : start]
+
+	if db.ipVersion == 6 {
+		node := uint32(0)
+		for i := 0; i < 96 && node < db.nodeCount; i++ {
+			node = db.record(node, 0)
+		}
+		db.ipv4Start = node
+	}
+	return db, nil
+}
+
+// lookup returns the record of the network containing ip, or nil if the
+// database has none.
+func (db *mmdbFile) lookup(ip netip.Addr) (map[string]interface{}, error) {
+	ip = ip.Unmap()
+	node := uint32(0)
+	if ip.Is4() {
+		node = db.ipv4Start
+	} else if db.ipVersion == 4 {
+		return nil, nil
+	}
+
+	addr := ip.AsSlice()
+	for i := 0; i < len(addr)*8 && node < db.nodeCount; i++ {
+		bit := addr[i/8] >> (7 - i%8) & 1
+		node = db.record(node, int(bit))
+	}
+	if node <= db.nodeCount {
+		return nil, nil
+	}
+
+	offset := int(node-db.nodeCount) - 16
+	d := decoder{buf: db.data}
+	v, _, err := d.decode(offset)
+	if err != nil {
+		return nil, err
+	}
+	record, _ := v.(map[string]interface{})
+	return record, nil
+}
+
+// record returns the left (0) or right (1) record of a search tree node.
+func (db *mmdbFile) record(node uint32, side int) uint32 {
+	size := db.recordSize / 4
+	b := db.buf[int(node)*size:]
+	switch db.recordSize {
+	case 24:
+		b = b[side*3:]
+		return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+	case 28:
+		if side == 0 {
+			return uint32(b[3]&0xf0)<<20 | uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+		}
+		return uint32(b[3]&0x0f)<<24 | uint32(b[4])<<16 | uint32(b[5])<<8 | uint32(b[6])
+	}
+	return binary.BigEndian.Uint32(b[side*4:])
+}
+
+// MMDB data types.
+const (
+	mmdbExtended = 0
+	mmdbPointer  = 1
+	mmdbString   = 2
+	mmdbDouble   = 3
+	mmdbBytes    = 4
+	mmdbUint16   = 5
+	mmdbUint32   = 6
+	mmdbMap      = 7
+	mmdbInt32    = 8
+	mmdbUint64   = 9
+	mmdbUint128  = 10
+	mmdbArray    = 11
+	mmdbBool     = 14
+	mmdbFloat    = 15
+)
+
+// decoder reads values from an MMDB data or metadata section. Pointers
+// are offsets from the start of buf.
+type decoder struct {
+	buf []byte
+}
+
+// decode returns the value at offset and the offset that follows it.
+func (d decoder) decode(offset int) (interface{}, int, error) {
+	if offset < 0 || offset >= len(d.buf) {
+		return nil, 0, errCorrupt
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ := int(ctrl >> 5)
+
+	if typ == mmdbPointer {
+		ptr, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		// Pointers may not point to pointers, which also rules out loops
+		if ptr < len(d.buf) && d.buf[ptr]>>5 == mmdbPointer {
+			return nil, 0, errCorrupt
+		}
+		v, _, err := d.decode(ptr)
+		return v, next, err
+	}
+
+	if typ == mmdbExtended {
+		if offset >= len(d.buf) {
+			return nil, 0, errCorrupt
+		}
+		typ = 7 + int(d.buf[offset])
+		offset++
+	}
+
+	size := int(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > len(d.buf) {
+			return nil, 0, errCorrupt
+		}
+		extra := 0
+		for _, b := range d.buf[offset : offset+n] {
+			extra = extra<<8 | int(b)
+		}
+		offset += n
+		size = []int{29, 285, 65821}[n-1] + extra
+	}
+
+	switch typ {
+	case mmdbMap:
+		m := make(map[string]interface{}, size)
+		for i := 0; i < size; i++ {
+			k, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errCorrupt
+			}
+			v, next, err := d.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			v, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > len(d.buf) {
+		return nil, 0, errCorrupt
+	}
+	b := d.buf[offset : offset+size]
+	offset += size
+
+	switch typ {
+	case mmdbString:
+		return string(b), offset, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, errCorrupt
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, errCorrupt
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, offset, nil
+	case mmdbInt32:
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int64(int32(n)), offset, nil
+	case mmdbBytes, mmdbUint128:
+		return b, offset, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported MMDB type %d", typ)
+}
+
+// pointer decodes the target of a pointer whose control byte was ctrl.
+func (d decoder) pointer(ctrl byte, offset int) (int, int, error) {
+	n := int(ctrl>>3&0x3) + 1
+	if offset+n > len(d.buf) {
+		return 0, 0, errCorrupt
+	}
+	b := d.buf[offset : offset+n]
+
+	var ptr int
+	if n < 4 {
+		ptr = int(ctrl & 0x7)
+	}
+	for _, c := range b {
+		ptr = ptr<<8 | int(c)
+	}
+	ptr += []int{0, 2048, 526336, 0}[n-1]
+	return ptr, offset + n, nil
+}
+
+// lookupPath returns the value under a path of map keys in a record.
+func lookupPath(record map[string]interface{}, path ...string) interface{} {
+	var v interface{} = record
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func toUint(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int64:
+		return uint64(n)
+	}
+	return 0
+}
@@ -0,0 +1,138 @@
+package geoip
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// ip-api.com allows 45 requests per minute from one address without a key.
+const (
+	onlineURL      = "http://ip-api.com/json/%s?fields=status,message,country,countryCode,city,lat,lon,isp,org,as"
+	onlineRequests = 45
+	onlineWindow   = time.Minute
+)
+
+// errRateLimited is returned instead of waiting for the online service.
+var errRateLimited = errors.New("online lookup rate limit reached")
+
+// onlineClient asks ip-api.com, spending at most onlineRequests per
+// window and never waiting for the next one.
+type onlineClient struct {
+	client *http.Client
+
+	mu     sync.Mutex
+	tokens int
+	refill time.Time // When tokens is reset to onlineRequests
+}
+
+func newOnlineClient() *onlineClient {
+	return &onlineClient{
+		client: &http.Client{Timeout: 10 * time.Second},
+		tokens: onlineRequests,
+		refill: time.Now().Add(onlineWindow),
+	}
+}
+
+// take spends one request, or reports that none are left in this window.
+func (c *onlineClient) take() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := time.Now(); !now.Before(c.refill) {
+		c.tokens, c.refill = onlineRequests, now.Add(onlineWindow)
+	}
+	if c.tokens == 0 {
+		return false
+	}
+	c.tokens--
+	return true
+}
+
+// pause spends the remaining requests until the service's window resets.
+func (c *onlineClient) pause(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens, c.refill = 0, time.Now().Add(ttl)
+}
+
+func (c *onlineClient) lookup(ctx context.Context, ip string) (*model.GeoInfo, error) {
+	if !c.take() {
+		return nil, errRateLimited
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf(onlineURL, ip), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch geolocation: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// X-Rl is the number of requests left, X-Ttl the seconds until the
+	// window resets
+	ttl, _ := strconv.Atoi(resp.Header.Get("X-Ttl"))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.Header.Get("X-Rl") == "0" {
+		c.pause(time.Duration(ttl+1) * time.Second)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("geolocation API returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Status      string  `json:"status"`
+		Message     string  `json:"message"`
+		Country     string  `json:"country"`
+		CountryCode string  `json:"countryCode"`
+		City        string  `json:"city"`
+		Lat         float64 `json:"lat"`
+		Lon         float64 `json:"lon"`
+		ISP         string  `json:"isp"`
+		Org         string  `json:"org"`
+		AS          string  `json:"as"` // "AS15169 Google LLC"
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode geolocation response: %w", err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("geolocation API: %s", result.Message)
+	}
+
+	info := &model.GeoInfo{
+		IP:          ip,
+		ISP:         result.ISP,
+		Org:         result.Org,
+		Country:     result.Country,
+		CountryCode: result.CountryCode,
+		City:        result.City,
+		Lat:         result.Lat,
+		Lon:         result.Lon,
+		Source:      SourceOnline,
+		UpdatedAt:   time.Now(),
+	}
+	if asn, name, ok := cutAS(result.AS); ok {
+		info.ASN = asn
+		if info.Org == "" {
+			info.Org = name
+		}
+	}
+	return info, nil
+}
+
+// cutAS splits "AS15169 Google LLC" into the AS number and its name.
+func cutAS(s string) (string, string, bool) {
+	if len(s) < 3 || s[:2] != "AS" {
+		return "", "", false
+	}
+	asn, name, _ := strings.Cut(s, " ")
+	return asn, name, true
+}
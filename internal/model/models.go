@@ -7,6 +7,7 @@ import "time"
 type IPRecord struct {
 	ID        int64     `json:"id"`
 	IP        string    `json:"ip"`
+	Family    string    `json:"family"` // ipv4 or ipv6
 	ASN       string    `json:"asn"`
 	ISP       string    `json:"isp"`
 	Country   string    `json:"country"`
@@ -14,23 +15,93 @@ type IPRecord struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// IPProviderCheck is one provider's answer to a public IP detection.
+type IPProviderCheck struct {
+	ID        int64     `json:"id"`
+	Provider  string    `json:"provider"`
+	Method    string    `json:"method"` // http, stun or dns
+	Family    string    `json:"family"` // ipv4 or ipv6
+	IP        string    `json:"ip,omitempty"`
+	Success   bool      `json:"success"`
+	Agreed    bool      `json:"agreed"` // Answer matched the consensus address
+	Error     string    `json:"error,omitempty"`
+	LatencyMs float64   `json:"latency_ms"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// IPProviderStats summarizes the reliability of one provider for one
+// address family.
+type IPProviderStats struct {
+	Provider      string  `json:"provider"`
+	Method        string  `json:"method"`
+	Family        string  `json:"family"`
+	Checks        int     `json:"checks"`
+	Failures      int     `json:"failures"`
+	Disagreements int     `json:"disagreements"` // Answered with another address than the consensus
+	SuccessPct    float64 `json:"success_pct"`
+	AgreementPct  float64 `json:"agreement_pct"` // Of successful answers
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+}
+
+// GeoInfo is the network owner and location of an IP address.
+type GeoInfo struct {
+	IP          string    `json:"ip"`
+	ASN         string    `json:"asn,omitempty"` // "AS15169"
+	Org         string    `json:"org,omitempty"` // Owner of the AS
+	ISP         string    `json:"isp,omitempty"`
+	Country     string    `json:"country,omitempty"`
+	CountryCode string    `json:"country_code,omitempty"`
+	City        string    `json:"city,omitempty"`
+	Lat         float64   `json:"lat,omitempty"`
+	Lon         float64   `json:"lon,omitempty"`
+	Source      string    `json:"source"` // mmdb, ip2asn or online, comma-separated
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
 // TraceResult represents a complete traceroute result.
 type TraceResult struct {
 	ID        int64      `json:"id"`
 	Target    string     `json:"target"`
 	Timestamp time.Time  `json:"timestamp"`
+	Mode      string     `json:"mode"`              // classic, paris or mda
+	ASPath    []string   `json:"as_path,omitempty"` // Origin ASNs of the hops, repeats removed
 	Hops      []TraceHop `json:"hops"`
 }
 
 // TraceHop represents a single hop in a traceroute.
 type TraceHop struct {
-	ID        int64   `json:"id"`
-	TraceID   int64   `json:"trace_id"`
-	HopNum    int     `json:"hop_num"`
-	IP        string  `json:"ip"`
-	Hostname  string  `json:"hostname"`
-	LatencyMs float64 `json:"latency_ms"`
-	Lost      bool    `json:"lost"`
+	ID        int64     `json:"id"`
+	TraceID   int64     `json:"trace_id"`
+	HopNum    int       `json:"hop_num"`
+	IP        string    `json:"ip"`
+	Hostname  string    `json:"hostname"`
+	LatencyMs float64   `json:"latency_ms"`     // Average of RTTs
+	RTTs      []float64 `json:"rtts,omitempty"` // One entry per answered probe
+	ICMPType  int       `json:"icmp_type"`      // ICMPv6 type for IPv6 hops
+	ICMPCode  int       `json:"icmp_code"`
+	HopSet    []string  `json:"hop_set,omitempty"` // Every responder seen at this TTL
+	ASN       string    `json:"asn,omitempty"`     // Origin AS of the address, "AS15169"
+	Lost      bool      `json:"lost"`
+}
+
+// PTRRecord is a cached reverse DNS answer. An empty hostname means the
+// address has no name.
+type PTRRecord struct {
+	IP        string    `json:"ip"`
+	Hostname  string    `json:"hostname"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RouterHint is what a router's reverse DNS name tells about it, e.g.
+// "ae1.cr1.fra1.example.net": interface ae1 of core router cr1 in Frankfurt.
+type RouterHint struct {
+	Name      string `json:"name"`                // Hostname without its domain
+	Interface string `json:"interface,omitempty"` // "ae1"
+	Device    string `json:"device,omitempty"`    // "cr1"
+	Role      string `json:"role,omitempty"`      // core, border, edge, aggregation...
+	Location  string `json:"location,omitempty"`  // Code found in the name, "fra"
+	City      string `json:"city,omitempty"`
+	Country   string `json:"country,omitempty"` // ISO code
 }
 
 // ScanHost represents a discovered host from ping sweep.
@@ -42,6 +113,15 @@ type ScanHost struct {
 	LatencyMs float64    `json:"latency_ms"`
 	LastSeen  time.Time  `json:"last_seen"`
 	Ports     []ScanPort `json:"ports,omitempty"`
+	// DetectedBy is the discovery method that found the host (icmp, tcp, ...)
+	DetectedBy string `json:"detected_by,omitempty"`
+	MAC        string `json:"mac,omitempty"`
+	Vendor     string `json:"vendor,omitempty"` // From the MAC's OUI
+	// Sources lists every discovery method that has seen the host
+	Sources    []string `json:"sources,omitempty"`
+	Services   []string `json:"services,omitempty"`    // Announced over mDNS/SSDP
+	DeviceInfo string   `json:"device_info,omitempty"` // Friendly name and model
+	Workgroup  string   `json:"workgroup,omitempty"`   // NetBIOS workgroup or domain
 	// User Metadata
 	DisplayName string   `json:"display_name,omitempty"`
 	Tags        []string `json:"tags,omitempty"`
@@ -57,6 +137,10 @@ type ScanPort struct {
 	Service  string    `json:"service"`
 	State    string    `json:"state"`
 	Banner   string    `json:"banner"`
+	Product  string    `json:"product,omitempty"` // From active service detection
+	Version  string    `json:"version,omitempty"`
+	Info     string    `json:"info,omitempty"` // Page title, certificate subject, ...
+	TLS      bool      `json:"tls"`
 	LastSeen time.Time `json:"last_seen"`
 }
 
@@ -90,6 +174,122 @@ type Anomaly struct {
 	Data        string    `json:"data"`
 }
 
+// Anomaly types recorded by the daemon.
+const (
+	AnomalyCertExpiring       = "cert_expiring"
+	AnomalyCertExpired        = "cert_expired"
+	AnomalyCertSelfSigned     = "cert_self_signed"
+	AnomalyCertChanged        = "cert_changed"
+	AnomalyPMTUDrop           = "pmtu_drop"
+	AnomalyPMTUBlackHole      = "pmtu_blackhole"
+	AnomalyDNSDivergence      = "dns_divergence"
+	AnomalyDNSInterception    = "dns_interception"
+	AnomalyDNSNXDomainRewrite = "dns_nxdomain_rewrite"
+	AnomalyASPathChange       = "as_path_change"
+)
+
+// Certificate is the TLS certificate presented by a scanned host port.
+type Certificate struct {
+	ID          int64     `json:"id"`
+	HostID      int64     `json:"host_id"`
+	IP          string    `json:"ip"`
+	Port        int       `json:"port"`
+	Subject     string    `json:"subject"`
+	SANs        []string  `json:"sans"`
+	Issuer      string    `json:"issuer"`
+	KeyType     string    `json:"key_type"`    // e.g. RSA-2048, ECDSA-P-256
+	Fingerprint string    `json:"fingerprint"` // SHA-256 of the leaf certificate
+	ChainValid  bool      `json:"chain_valid"`
+	ChainError  string    `json:"chain_error,omitempty"`
+	SelfSigned  bool      `json:"self_signed"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	FirstSeen   time.Time `json:"first_seen"` // When this fingerprint was first seen
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// HTTPCheckResult is the outcome of one synthetic HTTP check.
+type HTTPCheckResult struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	URL        string    `json:"url"`
+	Method     string    `json:"method"`
+	StatusCode int       `json:"status_code"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	DNSMs      float64   `json:"dns_ms"`
+	ConnectMs  float64   `json:"connect_ms"`
+	TLSMs      float64   `json:"tls_ms"`
+	TTFBMs     float64   `json:"ttfb_ms"` // From request start to first response byte
+	TotalMs    float64   `json:"total_ms"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// LossStats aggregates one minute of continuous pings to a target.
+type LossStats struct {
+	ID       int64     `json:"id"`
+	Target   string    `json:"target"`
+	Method   string    `json:"method"` // icmp or tcp
+	Minute   time.Time `json:"minute"`
+	Sent     int       `json:"sent"`
+	Received int       `json:"received"`
+	LossPct  float64   `json:"loss_pct"`
+	MinMs    float64   `json:"min_ms"`
+	AvgMs    float64   `json:"avg_ms"`
+	MaxMs    float64   `json:"max_ms"`
+	JitterMs float64   `json:"jitter_ms"` // Standard deviation of the RTTs
+	P95Ms    float64   `json:"p95_ms"`
+}
+
+// ThroughputResult is one timed transfer against a throughput server.
+type ThroughputResult struct {
+	ID          int64     `json:"id"`
+	Server      string    `json:"server"`
+	Protocol    string    `json:"protocol"`  // tcp or udp
+	Direction   string    `json:"direction"` // upload or download
+	Mbps        float64   `json:"mbps"`
+	Bytes       int64     `json:"bytes"`
+	DurationMs  float64   `json:"duration_ms"`
+	Retransmits int       `json:"retransmits"` // TCP segments resent by the sender (Linux only)
+	LossPct     float64   `json:"loss_pct"`    // UDP only
+	JitterMs    float64   `json:"jitter_ms"`   // UDP only (RFC 3550)
+	Success     bool      `json:"success"`
+	Error       string    `json:"error,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// BufferbloatResult compares the latency to a target on an idle link with
+// the latency while the link is saturated in each direction.
+type BufferbloatResult struct {
+	ID           int64     `json:"id"`
+	Target       string    `json:"target"`
+	Server       string    `json:"server"` // Throughput server used to load the link
+	IdleMs       float64   `json:"idle_ms"`
+	DownloadMs   float64   `json:"download_ms"` // Average RTT while downloading
+	UploadMs     float64   `json:"upload_ms"`   // Average RTT while uploading
+	DownloadMbps float64   `json:"download_mbps"`
+	UploadMbps   float64   `json:"upload_mbps"`
+	IncreaseMs   float64   `json:"increase_ms"` // Worst loaded RTT minus idle RTT
+	Grade        string    `json:"grade"`       // A+ to F
+	Success      bool      `json:"success"`
+	Error        string    `json:"error,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// PMTUResult is the path MTU discovered to a trace target.
+type PMTUResult struct {
+	ID          int64     `json:"id"`
+	Target      string    `json:"target"`
+	PMTU        int       `json:"pmtu"`         // Largest IP packet that reached the target
+	LocalMTU    int       `json:"local_mtu"`    // MTU of the outgoing interface
+	ReportedBy  string    `json:"reported_by"`  // Router that sent "fragmentation needed"
+	ReportedMTU int       `json:"reported_mtu"` // Next-hop MTU in that message
+	BlackHole   bool      `json:"black_hole"`   // Larger packets vanished without an ICMP error
+	Success     bool      `json:"success"`
+	Error       string    `json:"error,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
 // ReportOptions defines options for report generation.
 type ReportOptions struct {
 	Since      time.Time `json:"since"`
@@ -103,18 +303,54 @@ type ReportOptions struct {
 
 // DNSMetric represents a DNS latency measurement.
 type DNSMetric struct {
-	ID         int64     `json:"id"`
-	Server     string    `json:"server"`
-	Protocol   string    `json:"protocol"` // "udp" or "doh"
-	ResolvedIP string    `json:"resolved_ip"` // The captured IP address
-	LatencyMs  int       `json:"latency_ms"`
-	Timestamp  time.Time `json:"timestamp"`
+	ID          int64     `json:"id"`
+	Server      string    `json:"server"`
+	Protocol    string    `json:"protocol"` // "udp", "tcp" (truncated UDP answer), "doh" or "dot"
+	QueryName   string    `json:"query_name"`
+	QueryType   string    `json:"query_type"`  // e.g. A, AAAA, MX
+	ResolvedIP  string    `json:"resolved_ip"` // The captured IP address
+	LatencyMs   int       `json:"latency_ms"`
+	Rcode       string    `json:"rcode"` // e.g. NOERROR, NXDOMAIN
+	AnswerCount int       `json:"answer_count"`
+	Success     bool      `json:"success"`
+	ErrorClass  string    `json:"error_class,omitempty"` // e.g. timeout, servfail, tls_error
+	Error       string    `json:"error,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// DNSAvailability summarizes the measurements of one resolver over one
+// transport.
+type DNSAvailability struct {
+	Server          string         `json:"server"`
+	Protocol        string         `json:"protocol"`
+	Checks          int            `json:"checks"`
+	Failures        int            `json:"failures"`
+	AvailabilityPct float64        `json:"availability_pct"`
+	AvgLatencyMs    float64        `json:"avg_latency_ms"`   // Successful queries only
+	Errors          map[string]int `json:"errors,omitempty"` // Failures per error class
+}
+
+// DNSIntegrityCheck is one answer collected by the DNS integrity job.
+type DNSIntegrityCheck struct {
+	ID        int64     `json:"id"`
+	Check     string    `json:"check"` // answers, identity or nxdomain
+	Server    string    `json:"server"`
+	Protocol  string    `json:"protocol"`
+	QueryName string    `json:"query_name"`
+	Rcode     string    `json:"rcode"`
+	Answers   []string  `json:"answers"`
+	OK        bool      `json:"ok"` // false when the answer revealed tampering
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // DNSTarget represents a monitored DNS server.
 type DNSTarget struct {
-	ID     int64  `json:"id"`
-	Name   string `json:"name"`
-	IP     string `json:"ip"`
-	DoHURL string `json:"doh_url"`
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	IP         string   `json:"ip"`
+	DoHURL     string   `json:"doh_url"`
+	DoHFormat  string   `json:"doh_format"` // "wire" (RFC 8484) or "json"; default by URL
+	DoTServer  string   `json:"dot_server"` // host[:port] for DNS-over-TLS
+	QueryNames []string `json:"query_names"`
+	QueryTypes []string `json:"query_types"` // A, AAAA, MX, TXT, CNAME
 }
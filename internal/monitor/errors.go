@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/user/netpulse/internal/dnsmsg"
+)
+
+// Error classes of failed measurements. Answers with a failure rcode are
+// classed by its lower-case mnemonic, such as ErrorServFail.
+const (
+	ErrorTimeout   = "timeout"
+	ErrorServFail  = "servfail"
+	ErrorNXDomain  = "nxdomain"
+	ErrorRefused   = "refused"
+	ErrorTLS       = "tls_error"
+	ErrorHTTP      = "http_status"
+	ErrorMalformed = "malformed" // Unparseable or mismatched response
+	ErrorNetwork   = "network"   // Connection refused, unreachable, ...
+)
+
+// errMalformed wraps errors decoding a response.
+var errMalformed = errors.New("malformed response")
+
+// HTTPStatusError is returned when a DoH server answers with a non-200 status.
+type HTTPStatusError struct {
+	Code int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("bad status: %d", e.Code)
+}
+
+// RcodeClass returns the error class of a response code, or "" for NOERROR.
+func RcodeClass(rcode int) string {
+	if rcode == dnsmsg.RcodeSuccess {
+		return ""
+	}
+	return strings.ToLower(dnsmsg.RcodeString(rcode))
+}
+
+// Classify returns the error class of a failed exchange.
+func Classify(err error) string {
+	var statusErr *HTTPStatusError
+	var netErr net.Error
+	var verifyErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	switch {
+	case errors.As(err, &statusErr):
+		return ErrorHTTP
+	case errors.As(err, &verifyErr), errors.As(err, &recordErr), errors.As(err, &alertErr),
+		errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return ErrorTLS
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.Is(err, errMalformed):
+		return ErrorMalformed
+	}
+	return ErrorNetwork
+}
@@ -0,0 +1,383 @@
+package monitor
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/netpulse/internal/dnsmsg"
+	"github.com/user/netpulse/internal/model"
+)
+
+// Integrity checks.
+const (
+	CheckAnswers  = "answers"  // Same name asked of every resolver
+	CheckIdentity = "identity" // CHAOS TXT identity of the resolver
+	CheckNXDomain = "nxdomain" // Name that cannot exist
+)
+
+// identityNames are the CHAOS TXT names resolvers answer with their own
+// server name (RFC 4892).
+var identityNames = []string{"id.server", "hostname.bind"}
+
+// IntegrityFinding is a sign of interception or rewriting, with the
+// conflicting answers keyed by "resolver/transport".
+type IntegrityFinding struct {
+	Type        string // model.AnomalyDNS*
+	Severity    string
+	Description string
+	QueryName   string
+	Answers     map[string][]string
+	Suspects    []int // Indexes into IntegrityReport.Checks of the blamed answers
+}
+
+// IntegrityReport is the outcome of one integrity run.
+type IntegrityReport struct {
+	Checks   []model.DNSIntegrityCheck
+	Findings []IntegrityFinding
+
+	started time.Time // Timestamp of every check of the run
+}
+
+// observation is the answer of one resolver over one transport.
+type observation struct {
+	target   string
+	protocol string
+	rcode    int
+	records  []string
+	check    int // Index into IntegrityReport.Checks
+}
+
+func (o observation) key() string {
+	return o.target + "/" + o.protocol
+}
+
+// evidence returns the records, or the rcode of an empty answer.
+func (o observation) evidence() []string {
+	if len(o.records) == 0 {
+		return []string{dnsmsg.RcodeString(o.rcode)}
+	}
+	return o.records
+}
+
+func (o observation) plaintext() bool {
+	return o.protocol == ProtocolUDP || o.protocol == ProtocolTCP
+}
+
+// CheckIntegrity asks every target, over each of its transports, for the
+// given names, for its identity and for a name that cannot exist. Answers
+// that point to interception of plaintext DNS or to rewritten answers are
+// reported as findings. Queries that fail are left to the latency monitor.
+func CheckIntegrity(targets []model.DNSTarget, names []string) *IntegrityReport {
+	r := &IntegrityReport{started: time.Now()}
+	for _, name := range names {
+		r.checkAnswers(targets, name)
+	}
+	r.checkIdentity(targets)
+	r.checkNXDomain(targets)
+	return r
+}
+
+// checkAnswers compares the addresses returned for name. A resolver whose
+// plaintext answers share nothing with its encrypted ones is being tampered
+// with on the way; one that disagrees with every other resolver is
+// filtering or rewriting the name.
+func (r *IntegrityReport) checkAnswers(targets []model.DNSTarget, name string) {
+	obs := r.record(CheckAnswers, name, askAll(targets, Query{Name: name, Type: dnsmsg.TypeA}))
+	byTarget := groupByTarget(obs)
+
+	for _, t := range targets {
+		own := byTarget[t.Name]
+		var plain, encrypted []observation
+		for _, o := range own {
+			if o.plaintext() {
+				plain = append(plain, o)
+			} else {
+				encrypted = append(encrypted, o)
+			}
+		}
+		if len(plain) > 0 && len(encrypted) > 0 && !anyAgree(plain, encrypted) {
+			r.flag(model.AnomalyDNSDivergence, "critical",
+				fmt.Sprintf("%s answers %s differently over plaintext DNS than over %s", t.Name, name, protocols(encrypted)),
+				name, plain, own)
+		}
+	}
+
+	// Outliers need a majority to stand out from
+	if len(byTarget) < 3 {
+		return
+	}
+	for _, t := range targets {
+		own := byTarget[t.Name]
+		if len(own) == 0 {
+			continue
+		}
+		var others []observation
+		for _, o := range obs {
+			if o.target != t.Name {
+				others = append(others, o)
+			}
+		}
+		if !anyAgree(own, others) {
+			r.flag(model.AnomalyDNSDivergence, "warning",
+				fmt.Sprintf("%s answers %s unlike every other resolver", t.Name, name),
+				name, own, obs)
+		}
+	}
+}
+
+// checkIdentity asks each resolver for its name over every transport. An
+// interceptor answers plaintext queries itself, so its name differs from
+// the one the resolver gives over an encrypted transport.
+func (r *IntegrityReport) checkIdentity(targets []model.DNSTarget) {
+	flagged := make(map[string]bool)
+	for _, name := range identityNames {
+		q := Query{Name: name, Type: dnsmsg.TypeTXT, Class: dnsmsg.ClassCHAOS}
+		byTarget := groupByTarget(r.record(CheckIdentity, name, askAll(targets, q)))
+
+		for _, t := range targets {
+			own := byTarget[t.Name]
+			if flagged[t.Name] {
+				continue
+			}
+			var plain, encrypted []observation
+			for _, o := range own {
+				if o.rcode != dnsmsg.RcodeSuccess || len(o.records) == 0 {
+					continue
+				}
+				if o.plaintext() {
+					plain = append(plain, o)
+				} else {
+					encrypted = append(encrypted, o)
+				}
+			}
+			if len(plain) == 0 || len(encrypted) == 0 {
+				continue
+			}
+			id := identity(plain[0])
+			match := false
+			for _, o := range encrypted {
+				if sameIdentity(id, identity(o)) {
+					match = true
+				}
+			}
+			if !match {
+				flagged[t.Name] = true
+				r.flag(model.AnomalyDNSInterception, "critical",
+					fmt.Sprintf("Plaintext DNS to %s is answered by %q, but the resolver identifies as %q over %s",
+						t.Name, id, identity(encrypted[0]), strings.ToUpper(encrypted[0].protocol)),
+					name, plain, own)
+			}
+		}
+	}
+}
+
+// checkNXDomain asks for a random name under .invalid, which never exists
+// (RFC 6761). Any address in the answer was made up along the way.
+func (r *IntegrityReport) checkNXDomain(targets []model.DNSTarget) {
+	name := fmt.Sprintf("netpulse-%08x%08x.invalid", rand.Uint32(), rand.Uint32())
+	obs := r.record(CheckNXDomain, name, askAll(targets, Query{Name: name, Type: dnsmsg.TypeA}))
+
+	for _, t := range targets {
+		var rewritten []observation
+		for _, o := range obs {
+			if o.target == t.Name && o.rcode == dnsmsg.RcodeSuccess && len(o.records) > 0 {
+				rewritten = append(rewritten, o)
+			}
+		}
+		if len(rewritten) > 0 {
+			// The name is random, so it is left out of the finding to
+			// recognize the same rewrite on the next run
+			r.flag(model.AnomalyDNSNXDomainRewrite, "critical",
+				fmt.Sprintf("%s answers the nonexistent name %s with %s over %s",
+					t.Name, name, strings.Join(rewritten[0].records, ", "), protocols(rewritten)),
+				"", rewritten, rewritten)
+		}
+	}
+}
+
+// record adds the observations as checks and remembers their index.
+func (r *IntegrityReport) record(check, name string, obs []observation) []observation {
+	for i := range obs {
+		obs[i].check = len(r.Checks)
+		r.Checks = append(r.Checks, model.DNSIntegrityCheck{
+			Check:     check,
+			Server:    obs[i].target,
+			Protocol:  obs[i].protocol,
+			QueryName: name,
+			Rcode:     dnsmsg.RcodeString(obs[i].rcode),
+			Answers:   obs[i].records,
+			OK:        true,
+			Timestamp: r.started,
+		})
+	}
+	return obs
+}
+
+// flag adds a finding that blames the suspect answers, with the answers of
+// evidence attached.
+func (r *IntegrityReport) flag(anomalyType, severity, description, name string, suspect, evidence []observation) {
+	var suspects []int
+	for _, o := range suspect {
+		r.Checks[o.check].OK = false
+		suspects = append(suspects, o.check)
+	}
+	answers := make(map[string][]string)
+	for _, o := range evidence {
+		answers[o.key()] = o.evidence()
+	}
+	r.Findings = append(r.Findings, IntegrityFinding{
+		Type:        anomalyType,
+		Severity:    severity,
+		Description: description,
+		QueryName:   name,
+		Answers:     answers,
+		Suspects:    suspects,
+	})
+}
+
+// askAll sends q to every target over each of its transports, resolvers in
+// parallel, and returns the answers in target order. JSON DoH endpoints
+// only serve the Internet class and are skipped for other queries.
+func askAll(targets []model.DNSTarget, q Query) []observation {
+	results := make([][]observation, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t model.DNSTarget) {
+			defer wg.Done()
+			for _, protocol := range transports(t) {
+				if protocol == ProtocolDoH && q.class() != dnsmsg.ClassINET &&
+					DoHFormat(t.DoHURL, t.DoHFormat) == DoHJSON {
+					continue
+				}
+				resp, err := ask(t, protocol, q)
+				if err != nil {
+					continue
+				}
+				records := append([]string(nil), resp.Records...)
+				sort.Strings(records)
+				results[i] = append(results[i], observation{
+					target:   t.Name,
+					protocol: resp.Protocol,
+					rcode:    resp.Rcode,
+					records:  records,
+				})
+			}
+		}(i, t)
+	}
+	wg.Wait()
+
+	var obs []observation
+	for _, r := range results {
+		obs = append(obs, r...)
+	}
+	return obs
+}
+
+// transports lists the transports a target is configured for.
+func transports(t model.DNSTarget) []string {
+	var protocols []string
+	if t.IP != "" {
+		protocols = append(protocols, ProtocolUDP)
+	}
+	if t.DoHURL != "" {
+		protocols = append(protocols, ProtocolDoH)
+	}
+	if t.DoTServer != "" {
+		protocols = append(protocols, ProtocolDoT)
+	}
+	return protocols
+}
+
+// ask sends q to a target over one transport.
+func ask(t model.DNSTarget, protocol string, q Query) (*Response, error) {
+	switch protocol {
+	case ProtocolUDP:
+		return MeasureUDP(t.IP, q)
+	case ProtocolDoT:
+		return MeasureDoT(t.DoTServer, q)
+	}
+	return MeasureDoH(t.DoHURL, DoHFormat(t.DoHURL, t.DoHFormat), q)
+}
+
+func groupByTarget(obs []observation) map[string][]observation {
+	groups := make(map[string][]observation)
+	for _, o := range obs {
+		groups[o.target] = append(groups[o.target], o)
+	}
+	return groups
+}
+
+func protocols(obs []observation) string {
+	var names []string
+	for _, o := range obs {
+		names = append(names, strings.ToUpper(o.protocol))
+	}
+	return strings.Join(names, "/")
+}
+
+// anyAgree reports whether any answer of a agrees with any answer of b.
+func anyAgree(a, b []observation) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if agree(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// agree reports whether two answers are consistent. CDNs hand out
+// different addresses to different resolvers, so addresses in the same
+// /24 (IPv4) or /48 (IPv6) count as agreeing.
+func agree(a, b observation) bool {
+	if a.rcode != b.rcode {
+		return false
+	}
+	if len(a.records) == 0 && len(b.records) == 0 {
+		return true
+	}
+	for _, x := range a.records {
+		for _, y := range b.records {
+			if x == y || samePrefix(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func samePrefix(a, b string) bool {
+	x, y := net.ParseIP(a), net.ParseIP(b)
+	if x == nil || y == nil {
+		return false
+	}
+	if x4, y4 := x.To4(), y.To4(); x4 != nil && y4 != nil {
+		mask := net.CIDRMask(24, 32)
+		return x4.Mask(mask).Equal(y4.Mask(mask))
+	}
+	mask := net.CIDRMask(48, 128)
+	return x.To16().Mask(mask).Equal(y.To16().Mask(mask))
+}
+
+func identity(o observation) string {
+	return strings.Join(o.records, " ")
+}
+
+// sameIdentity compares two resolver names. Anycast sites name the
+// instance that answered ("res100.ams.rrdns.pch.net"), which may change
+// between queries, so dotted names are compared without their first label.
+func sameIdentity(a, b string) bool {
+	if a == b {
+		return true
+	}
+	_, siteA, okA := strings.Cut(a, ".")
+	_, siteB, okB := strings.Cut(b, ".")
+	return okA && okB && strings.Contains(siteA, ".") && siteA == siteB
+}
@@ -1,22 +1,19 @@
 package monitor
 
 import (
-	"context"
-	"crypto/tls"
-	"encoding/json"
-	"fmt"
-	"net"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/user/netpulse/internal/dnsmsg"
 	"github.com/user/netpulse/internal/model"
 )
 
+// client is shared by DoH queries. Certificates are verified as for DoT, so
+// a box intercepting DoH fails with a TLS error instead of answering.
 var client = &http.Client{
-	Timeout: 5 * time.Second,
-	Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	},
+	Timeout:   5 * time.Second,
+	Transport: &http.Transport{},
 }
 
 // DoHResponse represents a DNS-over-HTTPS response
@@ -28,120 +25,124 @@ type DoHResponse struct {
 	} `json:"Answer"`
 }
 
-// MeasureUDP resolves google.com via UDP 53 and returns latency + IP
-func MeasureUDP(resolverIP string) (int, string, error) {
-	resolverAddr := resolverIP + ":53"
-	start := time.Now()
-	
-	r := &net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-			d := net.Dialer{Timeout: 2 * time.Second}
-			return d.DialContext(ctx, "udp", resolverAddr)
-		},
-	}
-	
-	ips, err := r.LookupHost(context.Background(), "google.com")
-	if err != nil {
-		return 0, "", err
-	}
-	
-	resolvedIP := ""
-	if len(ips) > 0 {
-		resolvedIP = ips[0]
-	}
-	
-	return int(time.Since(start).Milliseconds()), resolvedIP, nil
-}
-
-// MeasureDoH performs a DNS-over-HTTPS check and returns latency + IP
-func MeasureDoH(url string) (int, string, error) {
-	start := time.Now()
-	req, err := http.NewRequest("GET", url+"?name=google.com&type=A", nil)
-    if err != nil { return 0, "", err }
-    req.Header.Set("Accept", "application/dns-json")
+// TargetProvider returns list of targets to monitor
+type TargetProvider func() ([]model.DNSTarget, error)
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, "", err
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != 200 {
-		return 0, "", fmt.Errorf("bad status: %d", resp.StatusCode)
-	}
+// DefaultQueryNames and DefaultQueryTypes are asked of targets that do
+// not configure their own.
+var (
+	DefaultQueryNames = []string{"google.com"}
+	DefaultQueryTypes = []string{"A"}
+)
 
-    var doh DoHResponse
-    if err := json.NewDecoder(resp.Body).Decode(&doh); err != nil {
-        return 0, "", err
-    }
-    
-    resolvedIP := ""
-    for _, ans := range doh.Answer {
-        if ans.Type == 1 { // A Record
-            resolvedIP = ans.Data
-            break
-        }
-    }
-	
-	return int(time.Since(start).Milliseconds()), resolvedIP, nil
+// DefaultTargets are the public resolvers that are always monitored.
+var DefaultTargets = []model.DNSTarget{
+	{Name: "Google", IP: "8.8.8.8", DoHURL: "https://dns.google/dns-query", DoTServer: "dns.google"},
+	{Name: "Cloudflare", IP: "1.1.1.1", DoHURL: "https://cloudflare-dns.com/dns-query", DoTServer: "one.one.one.one"},
+	{Name: "Quad9", IP: "9.9.9.9", DoHURL: "https://dns.quad9.net/dns-query", DoTServer: "dns.quad9.net"},
 }
 
-// TargetProvider returns list of targets to monitor
-type TargetProvider func() ([]model.DNSTarget, error)
-
 // Run starts the monitoring loop
 func Run(interval time.Duration, provider TargetProvider, callback func(model.DNSMetric)) {
-    // Default targets
-	defaults := []model.DNSTarget{
-		{Name: "Google", IP: "8.8.8.8", DoHURL: "https://dns.google/resolve"},
-		{Name: "Cloudflare", IP: "1.1.1.1", DoHURL: "https://cloudflare-dns.com/dns-query"},
-		{Name: "Quad9", IP: "9.9.9.9", DoHURL: "https://dns.quad9.net/dns-query"},
-	}
-
 	ticker := time.NewTicker(interval)
-	
+
 	check := func() {
-        targets := make([]model.DNSTarget, len(defaults))
-        copy(targets, defaults)
-        
-        // Add custom targets
-        if custom, err := provider(); err == nil {
-            targets = append(targets, custom...)
-        }
+		targets := make([]model.DNSTarget, len(DefaultTargets))
+		copy(targets, DefaultTargets)
+
+		// Add custom targets
+		if custom, err := provider(); err == nil {
+			targets = append(targets, custom...)
+		}
 
+		// Targets are measured in parallel so a slow resolver does not
+		// delay the others
+		var wg sync.WaitGroup
 		for _, t := range targets {
-			// UDP
-            if t.IP != "" {
-			    if lat, ip, err := MeasureUDP(t.IP); err == nil {
-				    callback(model.DNSMetric{
-                        Server: t.Name, 
-                        Protocol: "udp", 
-                        ResolvedIP: ip,
-                        LatencyMs: lat, 
-                        Timestamp: time.Now(),
-                    })
-			    }
-            }
-			// DoH
-            if t.DoHURL != "" {
-			    if lat, ip, err := MeasureDoH(t.DoHURL); err == nil {
-				    callback(model.DNSMetric{
-                        Server: t.Name, 
-                        Protocol: "doh", 
-                        ResolvedIP: ip,
-                        LatencyMs: lat, 
-                        Timestamp: time.Now(),
-                    })
-			    }
-            }
+			wg.Add(1)
+			go func(t model.DNSTarget) {
+				defer wg.Done()
+				measureTarget(t, callback)
+			}(t)
 		}
+		wg.Wait()
 	}
 
-    go func() {
-        check() // Run immediately
-        for range ticker.C {
-            check()
-        }
-    }()
+	go func() {
+		check() // Run immediately
+		for range ticker.C {
+			check()
+		}
+	}()
+}
+
+// measureTarget asks every configured query over each transport the
+// target supports. Failures are reported too, with their error class.
+func measureTarget(t model.DNSTarget, callback func(model.DNSMetric)) {
+	for _, q := range targetQueries(t) {
+		measure := func(protocol string, fn func() (*Response, error)) {
+			m := model.DNSMetric{
+				Server:    t.Name,
+				Protocol:  protocol,
+				QueryName: q.Name,
+				QueryType: dnsmsg.TypeString(q.Type),
+			}
+			start := time.Now()
+			resp, err := fn()
+			m.Timestamp = time.Now()
+
+			if err != nil {
+				m.LatencyMs = int(m.Timestamp.Sub(start).Milliseconds())
+				m.ErrorClass = Classify(err)
+				m.Error = err.Error()
+			} else {
+				m.Protocol = resp.Protocol
+				m.ResolvedIP = resp.ResolvedIP
+				m.LatencyMs = resp.LatencyMs
+				m.Rcode = dnsmsg.RcodeString(resp.Rcode)
+				m.AnswerCount = resp.Answers
+				m.ErrorClass = RcodeClass(resp.Rcode)
+				m.Success = m.ErrorClass == ""
+				if !m.Success {
+					m.Error = m.Rcode
+				}
+			}
+			callback(m)
+		}
+
+		if t.IP != "" {
+			measure(ProtocolUDP, func() (*Response, error) { return MeasureUDP(t.IP, q) })
+		}
+		if t.DoHURL != "" {
+			measure(ProtocolDoH, func() (*Response, error) {
+				return MeasureDoH(t.DoHURL, DoHFormat(t.DoHURL, t.DoHFormat), q)
+			})
+		}
+		if t.DoTServer != "" {
+			measure(ProtocolDoT, func() (*Response, error) { return MeasureDoT(t.DoTServer, q) })
+		}
+	}
+}
+
+// targetQueries returns every name and type combination of a target.
+func targetQueries(t model.DNSTarget) []Query {
+	names, typeNames := t.QueryNames, t.QueryTypes
+	if len(names) == 0 {
+		names = DefaultQueryNames
+	}
+	if len(typeNames) == 0 {
+		typeNames = DefaultQueryTypes
+	}
+	types, err := ParseQueryTypes(typeNames)
+	if err != nil {
+		types = []uint16{dnsmsg.TypeA}
+	}
+
+	var queries []Query
+	for _, name := range names {
+		for _, qtype := range types {
+			queries = append(queries, Query{Name: name, Type: qtype})
+		}
+	}
+	return queries
 }
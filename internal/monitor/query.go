@@ -0,0 +1,299 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/netpulse/internal/dnsmsg"
+)
+
+// Transports a measurement can be made over.
+const (
+	ProtocolUDP = "udp"
+	ProtocolTCP = "tcp" // UDP answer was truncated
+	ProtocolDoH = "doh"
+	ProtocolDoT = "dot"
+)
+
+// DoH request formats.
+const (
+	DoHWire = "wire" // RFC 8484 application/dns-message
+	DoHJSON = "json" // application/dns-json (Google/Cloudflare JSON API)
+)
+
+// queryTimeout bounds each UDP, TCP and DoT exchange.
+const queryTimeout = 2 * time.Second
+
+// Query is one question asked of a resolver.
+type Query struct {
+	Name  string
+	Type  uint16
+	Class uint16 // Default dnsmsg.ClassINET
+}
+
+// Response summarizes a resolver's answer to a query.
+type Response struct {
+	Protocol   string
+	LatencyMs  int
+	Rcode      int
+	Answers    int
+	ResolvedIP string   // First address answering an A or AAAA query
+	Records    []string // Data of the answers of the queried type
+}
+
+// MeasureUDP asks a resolver over UDP and retries over TCP when the answer
+// is truncated. server is an address with an optional port (default 53).
+func MeasureUDP(server string, q Query) (*Response, error) {
+	addr := withPort(server, "53")
+	query, err := packQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	conn, err := net.DialTimeout("udp", addr, queryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(queryTimeout))
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if n < 12 || binary.BigEndian.Uint16(buf) != binary.BigEndian.Uint16(query) {
+			continue
+		}
+		msg, err := dnsmsg.Unpack(buf[:n])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errMalformed, err)
+		}
+		if msg.Truncated {
+			return measureStream(start, ProtocolTCP, query, func() (net.Conn, error) {
+				return net.DialTimeout("tcp", addr, queryTimeout)
+			}, q)
+		}
+		return newResponse(ProtocolUDP, start, msg, q), nil
+	}
+}
+
+// MeasureDoT asks a resolver over DNS-over-TLS (RFC 7858, port 853 by
+// default). The certificate must be valid for the server's host name or IP.
+func MeasureDoT(server string, q Query) (*Response, error) {
+	query, err := packQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	addr := withPort(server, "853")
+	host, _, _ := net.SplitHostPort(addr)
+	return measureStream(time.Now(), ProtocolDoT, query, func() (net.Conn, error) {
+		d := &net.Dialer{Timeout: queryTimeout}
+		return tls.DialWithDialer(d, "tcp", addr, &tls.Config{ServerName: host})
+	}, q)
+}
+
+// measureStream sends a length-prefixed query over a connection from dial.
+// start is kept so a TCP retry includes the truncated UDP exchange.
+func measureStream(start time.Time, protocol string, query []byte, dial func() (net.Conn, error), q Query) (*Response, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(queryTimeout))
+
+	framed := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(framed, query...)); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	msg, err := dnsmsg.Unpack(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errMalformed, err)
+	}
+	if msg.ID != binary.BigEndian.Uint16(query) {
+		return nil, fmt.Errorf("%w: ID mismatch", errMalformed)
+	}
+	return newResponse(protocol, start, msg, q), nil
+}
+
+// MeasureDoH asks a DNS-over-HTTPS resolver, in RFC 8484 wire format or
+// through the JSON API.
+func MeasureDoH(endpoint, format string, q Query) (*Response, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	params := u.Query()
+	accept := "application/dns-message"
+	if format == DoHJSON {
+		params.Set("name", q.Name)
+		params.Set("type", dnsmsg.TypeString(q.Type))
+		accept = "application/dns-json"
+	} else {
+		// ID 0 keeps GET requests cacheable (RFC 8484 section 4.1)
+		msg := dnsmsg.NewQuery(0, q.Name, q.Type, q.class())
+		query, err := msg.Pack()
+		if err != nil {
+			return nil, err
+		}
+		params.Set("dns", base64.RawURLEncoding.EncodeToString(query))
+	}
+	u.RawQuery = params.Encode()
+
+	start := time.Now()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, &HTTPStatusError{Code: resp.StatusCode}
+	}
+
+	if format == DoHJSON {
+		var doh DoHResponse
+		if err := json.NewDecoder(resp.Body).Decode(&doh); err != nil {
+			return nil, fmt.Errorf("%w: %v", errMalformed, err)
+		}
+		r := &Response{
+			Protocol:  ProtocolDoH,
+			LatencyMs: int(time.Since(start).Milliseconds()),
+			Rcode:     doh.Status,
+			Answers:   len(doh.Answer),
+		}
+		for _, ans := range doh.Answer {
+			if uint16(ans.Type) != q.Type {
+				continue
+			}
+			if isAddressType(q.Type) && r.ResolvedIP == "" {
+				r.ResolvedIP = ans.Data
+			}
+			r.Records = append(r.Records, strings.Trim(ans.Data, `"`))
+		}
+		return r, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return nil, err
+	}
+	msg, err := dnsmsg.Unpack(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errMalformed, err)
+	}
+	return newResponse(ProtocolDoH, start, msg, q), nil
+}
+
+// DoHFormat returns the request format for a DoH endpoint: the configured
+// one, or JSON for Google-style /resolve endpoints and wire format otherwise.
+func DoHFormat(endpoint, configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if u, err := url.Parse(endpoint); err == nil && strings.HasSuffix(u.Path, "/resolve") {
+		return DoHJSON
+	}
+	return DoHWire
+}
+
+// packQuery encodes a recursive query with a random ID.
+func packQuery(q Query) ([]byte, error) {
+	return dnsmsg.NewQuery(uint16(rand.Intn(0xffff)), q.Name, q.Type, q.class()).Pack()
+}
+
+func (q Query) class() uint16 {
+	if q.Class == 0 {
+		return dnsmsg.ClassINET
+	}
+	return q.Class
+}
+
+// newResponse summarizes a decoded answer.
+func newResponse(protocol string, start time.Time, msg *dnsmsg.Message, q Query) *Response {
+	r := &Response{
+		Protocol:  protocol,
+		LatencyMs: int(time.Since(start).Milliseconds()),
+		Rcode:     msg.Rcode,
+		Answers:   len(msg.Answers),
+	}
+	for _, rr := range msg.Answers {
+		if rr.Type != q.Type {
+			continue
+		}
+		if rr.IP != nil && r.ResolvedIP == "" {
+			r.ResolvedIP = rr.IP.String()
+		}
+		r.Records = append(r.Records, recordData(rr))
+	}
+	return r
+}
+
+// recordData formats the data of a record for display and comparison.
+func recordData(rr dnsmsg.RR) string {
+	switch {
+	case rr.IP != nil:
+		return rr.IP.String()
+	case rr.Target != "":
+		return rr.Target
+	case rr.TXT != nil:
+		return strings.Join(rr.TXT, "")
+	}
+	return fmt.Sprintf("%x", rr.Data)
+}
+
+func isAddressType(t uint16) bool {
+	return t == dnsmsg.TypeA || t == dnsmsg.TypeAAAA
+}
+
+// withPort adds the default port to an address without one.
+func withPort(server, port string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), port)
+}
+
+// ParseQueryTypes converts record type names such as "AAAA" to types.
+func ParseQueryTypes(names []string) ([]uint16, error) {
+	var types []uint16
+	for _, name := range names {
+		t, ok := dnsmsg.ParseType(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown record type %q", name)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
@@ -0,0 +1,85 @@
+// Package oui maps MAC address prefixes to hardware vendors.
+package oui
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed oui.txt
+var builtin string
+
+// DB is a table of vendors keyed by the 24-bit MAC prefix ("001122").
+type DB struct {
+	vendors map[string]string
+}
+
+// Load returns the built-in table extended with an IEEE oui.txt from the
+// data directory, if one is present.
+func Load(dataDir string) *DB {
+	db := &DB{vendors: make(map[string]string)}
+	db.parse(strings.NewReader(builtin))
+
+	if f, err := os.Open(filepath.Join(dataDir, "oui.txt")); err == nil {
+		defer f.Close()
+		db.parse(f)
+	}
+
+	return db
+}
+
+// parse reads both the built-in "00:11:22 Vendor" format and the IEEE
+// "00-11-22   (hex)\t\tVendor" format. Other lines are ignored.
+func (db *DB) parse(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		prefix := normalize(fields[0])
+		if len(prefix) != 6 {
+			continue
+		}
+
+		if fields[1] == "(base" {
+			continue // oui.txt repeats every entry as "001122 (base 16)"
+		}
+		vendor := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		vendor = strings.TrimSpace(strings.TrimPrefix(vendor, "(hex)"))
+		if vendor != "" {
+			db.vendors[prefix] = vendor
+		}
+	}
+}
+
+// Lookup returns the vendor for a MAC address, or "" if it is unknown.
+// Randomized (locally administered) addresses are not registered with the
+// IEEE and normally return "".
+func (db *DB) Lookup(mac string) string {
+	prefix := normalize(mac)
+	if len(prefix) < 6 {
+		return ""
+	}
+	return db.vendors[prefix[:6]]
+}
+
+// normalize strips separators and upper-cases a MAC address or prefix.
+func normalize(s string) string {
+	s = strings.NewReplacer(":", "", "-", "", ".", "").Replace(s)
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return ""
+		}
+	}
+	return strings.ToUpper(s)
+}
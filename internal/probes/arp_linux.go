@@ -0,0 +1,190 @@
+package probes
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	ethPArp   = 0x0806
+	ethPIPv4  = 0x0800
+	arpReq    = 1
+	arpReply  = 2
+	arpFrame  = 42 // Ethernet header + ARP payload for IPv4
+	atfCom    = 0x02
+	arpProcFS = "/proc/net/arp"
+)
+
+// readARPTable parses /proc/net/arp. It is used when the netlink neighbor
+// dump is unavailable.
+func readARPTable() ([]neighbor, error) {
+	f, err := os.Open(arpProcFS)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var neighbors []neighbor
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // Header
+	for scanner.Scan() {
+		// IP address  HW type  Flags  HW address  Mask  Device
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		flags, err := strconv.ParseInt(fields[2], 0, 32)
+		if err != nil || flags&atfCom == 0 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		mac, err := net.ParseMAC(fields[3])
+		if ip == nil || err != nil {
+			continue
+		}
+		neighbors = append(neighbors, neighbor{
+			IP:        ip,
+			MAC:       mac,
+			Interface: fields[5],
+		})
+	}
+
+	return neighbors, scanner.Err()
+}
+
+// arpScan broadcasts an ARP request for every address in ips from the
+// interface attached to the subnet and returns the hosts that replied. It
+// needs CAP_NET_RAW.
+func arpScan(ctx context.Context, subnet *net.IPNet, ips []string, timeout time.Duration) ([]neighbor, error) {
+	iface, src, err := interfaceForSubnet(subnet)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(htons(ethPArp)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open packet socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: htons(ethPArp), Ifindex: iface.Index}); err != nil {
+		return nil, fmt.Errorf("failed to bind packet socket: %w", err)
+	}
+	tv := syscall.NsecToTimeval((100 * time.Millisecond).Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return nil, err
+	}
+
+	broadcast := &syscall.SockaddrLinklayer{
+		Protocol: htons(ethPArp),
+		Ifindex:  iface.Index,
+		Halen:    6,
+		Addr:     [8]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+	wanted := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		target := net.ParseIP(ip).To4()
+		if target == nil {
+			continue
+		}
+		wanted[target.String()] = true
+		if err := syscall.Sendto(fd, arpRequest(iface.HardwareAddr, src, target), 0, broadcast); err != nil {
+			return nil, fmt.Errorf("failed to send ARP request: %w", err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var neighbors []neighbor
+	buf := make([]byte, 128)
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline) && ctx.Err() == nil; {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			continue // Receive timeout
+		}
+		ip, mac, ok := parseARPReply(buf[:n])
+		if !ok || !wanted[ip.String()] || seen[ip.String()] {
+			continue
+		}
+		seen[ip.String()] = true
+		neighbors = append(neighbors, neighbor{
+			IP:        ip,
+			MAC:       mac,
+			Interface: iface.Name,
+			Reachable: true,
+		})
+	}
+
+	return neighbors, nil
+}
+
+// interfaceForSubnet returns the Ethernet interface with an IPv4 address in
+// the subnet and that address.
+func interfaceForSubnet(subnet *net.IPNet) (*net.Interface, net.IP, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range ifaces {
+		iface := &ifaces[i]
+		if iface.Flags&net.FlagUp == 0 || len(iface.HardwareAddr) != 6 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil && subnet.Contains(ipnet.IP) {
+				return iface, ipnet.IP.To4(), nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("no local interface on %s", subnet)
+}
+
+// arpRequest builds a broadcast Ethernet frame asking who has target.
+func arpRequest(srcMAC net.HardwareAddr, srcIP, target net.IP) []byte {
+	b := make([]byte, arpFrame)
+	copy(b[0:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	copy(b[6:12], srcMAC)
+	binary.BigEndian.PutUint16(b[12:14], ethPArp)
+
+	arp := b[14:]
+	binary.BigEndian.PutUint16(arp[0:2], 1) // Ethernet
+	binary.BigEndian.PutUint16(arp[2:4], ethPIPv4)
+	arp[4], arp[5] = 6, 4
+	binary.BigEndian.PutUint16(arp[6:8], arpReq)
+	copy(arp[8:14], srcMAC)
+	copy(arp[14:18], srcIP)
+	copy(arp[24:28], target)
+	return b
+}
+
+// parseARPReply returns the sender of an ARP reply frame.
+func parseARPReply(b []byte) (net.IP, net.HardwareAddr, bool) {
+	if len(b) < arpFrame || binary.BigEndian.Uint16(b[12:14]) != ethPArp {
+		return nil, nil, false
+	}
+	arp := b[14:]
+	if binary.BigEndian.Uint16(arp[2:4]) != ethPIPv4 || binary.BigEndian.Uint16(arp[6:8]) != arpReply {
+		return nil, nil, false
+	}
+	mac := net.HardwareAddr(append([]byte(nil), arp[8:14]...))
+	ip := net.IP(append([]byte(nil), arp[14:18]...))
+	return ip, mac, true
+}
+
+// htons converts a 16-bit value to network byte order.
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return binary.NativeEndian.Uint16(b[:])
+}
@@ -0,0 +1,143 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// bufferbloatInterval is the ping interval while measuring; fast enough to
+// catch queues building up within a few seconds.
+const bufferbloatInterval = 100 * time.Millisecond
+
+// bufferbloatRampUp is skipped at the start of each loaded phase, before
+// TCP has filled the queues.
+const bufferbloatRampUp = time.Second
+
+// BufferbloatTest describes a latency-under-load test: RTT to Target is
+// measured on the idle link and again while Server saturates it.
+type BufferbloatTest struct {
+	Target     string
+	Server     string // Throughput server, host or host:port
+	ServerPort int    // Used when Server has no port
+	Duration   time.Duration
+	Method     string // Ping method, icmp or tcp
+	Port       int    // tcp only
+}
+
+// RunBufferbloatTest measures idle latency, then latency during a download
+// and during an upload, and grades the increase.
+func RunBufferbloatTest(ctx context.Context, test BufferbloatTest) *model.BufferbloatResult {
+	if test.Duration <= 0 {
+		test.Duration = 10 * time.Second
+	}
+
+	result := &model.BufferbloatResult{
+		Target:    test.Target,
+		Server:    test.Server,
+		Timestamp: time.Now(),
+	}
+	stream := NewLatencyStream(test.Target, test.Method, test.Port, bufferbloatInterval)
+
+	idle, err := measureLatency(ctx, stream, nil, test.Duration/2)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.IdleMs = idle
+
+	var failures []string
+	for _, direction := range []string{DirectionDownload, DirectionUpload} {
+		var load *model.ThroughputResult
+		loaded, err := measureLatency(ctx, stream, func(ctx context.Context) {
+			load = RunThroughputTest(ctx, ThroughputTest{
+				Server:    test.Server,
+				Port:      test.ServerPort,
+				Protocol:  ThroughputTCP,
+				Direction: direction,
+				Duration:  test.Duration,
+			})
+		}, 0)
+		if load != nil && !load.Success {
+			err = fmt.Errorf("%s load failed: %s", direction, load.Error)
+		}
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+
+		if direction == DirectionDownload {
+			result.DownloadMs, result.DownloadMbps = loaded, load.Mbps
+		} else {
+			result.UploadMs, result.UploadMbps = loaded, load.Mbps
+		}
+		result.IncreaseMs = math.Max(result.IncreaseMs, loaded-idle)
+	}
+
+	if len(failures) == 2 {
+		result.Error = failures[0]
+		return result
+	}
+	result.Grade = BufferbloatGrade(result.IncreaseMs)
+	result.Success = true
+	return result
+}
+
+// measureLatency pings with stream and returns the average RTT. With a load
+// function it measures for as long as the load runs, skipping the ramp-up;
+// otherwise it measures for the given duration.
+func measureLatency(ctx context.Context, stream *LatencyStream, load func(context.Context), duration time.Duration) (float64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	var samples []LatencySample
+	done := make(chan error, 1)
+	go func() {
+		done <- stream.Run(ctx, func(s LatencySample) {
+			if load == nil || s.Time.Sub(start) >= bufferbloatRampUp {
+				samples = append(samples, s)
+			}
+		})
+	}()
+
+	if load != nil {
+		load(ctx)
+	} else {
+		select {
+		case <-time.After(duration):
+		case <-ctx.Done():
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		return 0, err
+	}
+
+	stats := SummarizeSamples(samples)
+	if stats.Received == 0 {
+		return 0, fmt.Errorf("no replies from %s", stream.target)
+	}
+	return stats.AvgMs, nil
+}
+
+// BufferbloatGrade grades a latency increase under load on the scale used by
+// the common bufferbloat tests.
+func BufferbloatGrade(increaseMs float64) string {
+	switch {
+	case increaseMs < 5:
+		return "A+"
+	case increaseMs < 30:
+		return "A"
+	case increaseMs < 60:
+		return "B"
+	case increaseMs < 200:
+		return "C"
+	case increaseMs < 400:
+		return "D"
+	}
+	return "F"
+}
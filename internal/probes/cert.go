@@ -0,0 +1,114 @@
+package probes
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// CertProbe collects the certificates served by TLS endpoints.
+type CertProbe struct {
+	timeout time.Duration
+}
+
+// NewCertProbe creates a new certificate probe.
+func NewCertProbe(timeout time.Duration) *CertProbe {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &CertProbe{timeout: timeout}
+}
+
+// Fetch handshakes with ip:port and describes the leaf certificate. The
+// chain is verified against the system roots separately from the handshake,
+// so invalid certificates are still collected.
+func (p *CertProbe) Fetch(ctx context.Context, ip string, port int) (*model.Certificate, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: p.timeout},
+		Config:    &tls.Config{InsecureSkipVerify: true},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	defer conn.Close()
+
+	chain := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no certificate presented")
+	}
+	leaf := chain[0]
+	sum := sha256.Sum256(leaf.Raw)
+
+	cert := &model.Certificate{
+		IP:          ip,
+		Port:        port,
+		Subject:     leaf.Subject.String(),
+		SANs:        certSANs(leaf),
+		Issuer:      leaf.Issuer.String(),
+		KeyType:     keyType(leaf),
+		Fingerprint: hex.EncodeToString(sum[:]),
+		SelfSigned:  isSelfSigned(leaf),
+		NotBefore:   leaf.NotBefore,
+		NotAfter:    leaf.NotAfter,
+		LastSeen:    time.Now(),
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Intermediates: intermediates}); err != nil {
+		cert.ChainError = err.Error()
+	} else {
+		cert.ChainValid = true
+	}
+
+	return cert, nil
+}
+
+// certSANs lists the DNS names and IP addresses a certificate covers.
+func certSANs(c *x509.Certificate) []string {
+	sans := append([]string(nil), c.DNSNames...)
+	for _, ip := range c.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return sans
+}
+
+// keyType describes the certificate's public key, e.g. "RSA-2048".
+func keyType(c *x509.Certificate) string {
+	switch key := c.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return c.PublicKeyAlgorithm.String()
+}
+
+// isSelfSigned reports whether a certificate is signed by its own key.
+func isSelfSigned(c *x509.Certificate) bool {
+	if !bytes.Equal(c.RawIssuer, c.RawSubject) {
+		return false
+	}
+	return c.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature) == nil
+}
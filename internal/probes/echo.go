@@ -0,0 +1,121 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// errICMPUnavailable is returned when neither an unprivileged datagram ICMP
+// socket nor a raw ICMP socket can be opened.
+var errICMPUnavailable = errors.New("ICMP sockets unavailable")
+
+// icmpConn is an ICMP socket used to send echo requests.
+type icmpConn struct {
+	conn net.PacketConn
+	v6   bool
+
+	// dgram is set for unprivileged datagram sockets. The kernel rewrites
+	// the echo identifier and only delivers replies to our own requests.
+	dgram bool
+}
+
+// listenICMP opens an unprivileged datagram ICMP socket (Linux
+// net.ipv4.ping_group_range, macOS) and falls back to a raw socket.
+func listenICMP(v6 bool) (*icmpConn, error) {
+	if conn, err := listenICMPDgram(v6); err == nil {
+		return &icmpConn{conn: conn, v6: v6, dgram: true}, nil
+	}
+
+	network, laddr := "ip4:icmp", "0.0.0.0"
+	if v6 {
+		network, laddr = "ip6:ipv6-icmp", "::"
+	}
+	conn, err := net.ListenPacket(network, laddr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errICMPUnavailable, err)
+	}
+	return &icmpConn{conn: conn, v6: v6}, nil
+}
+
+// Close closes the socket.
+func (c *icmpConn) Close() error {
+	return c.conn.Close()
+}
+
+// echo sends one echo request to ip and waits for the matching reply.
+func (c *icmpConn) echo(ctx context.Context, ip net.IP, timeout time.Duration) (time.Duration, error) {
+	id := rand.Intn(0xffff)
+	seq := rand.Intn(0xffff)
+
+	var msg []byte
+	if c.v6 {
+		msg = marshalEcho6(id, seq, []byte("netpulse"))
+	} else {
+		msg = marshalEcho(id, seq, []byte("netpulse"))
+	}
+
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if c.dgram {
+		dst = &net.UDPAddr{IP: ip}
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.conn.SetReadDeadline(deadline)
+
+	start := time.Now()
+	if _, err := c.conn.WriteTo(msg, dst); err != nil {
+		return 0, fmt.Errorf("failed to send echo request: %w", err)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		rtt := time.Since(start)
+
+		var reply *icmpMessage
+		if c.v6 {
+			reply, err = parseICMPv6(buf[:n])
+		} else {
+			reply, err = parseICMPv4(buf[:n])
+		}
+		if err != nil || !reply.isEchoReply() || reply.Seq != seq {
+			continue
+		}
+		if !c.dgram && (reply.ID != id || !addrIP(from).Equal(ip)) {
+			continue
+		}
+		return rtt, nil
+	}
+}
+
+// icmpEcho pings ip once and returns the round-trip time.
+func icmpEcho(ctx context.Context, ip net.IP, timeout time.Duration) (time.Duration, error) {
+	c, err := listenICMP(ip.To4() == nil)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	return c.echo(ctx, ip, timeout)
+}
+
+// addrIP returns the IP of a UDP or IP address.
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+// +build !windows
+
+package probes
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenICMPDgram opens an unprivileged datagram ICMP socket. Go exposes it
+// as a UDP connection, so it is addressed with net.UDPAddr.
+func listenICMPDgram(v6 bool) (net.PacketConn, error) {
+	family, proto := syscall.AF_INET, syscall.IPPROTO_ICMP
+	var sa syscall.Sockaddr = &syscall.SockaddrInet4{}
+	if v6 {
+		family, proto = syscall.AF_INET6, syscall.IPPROTO_ICMPV6
+		sa = &syscall.SockaddrInet6{}
+	}
+
+	fd, err := syscall.Socket(family, syscall.SOCK_DGRAM, proto)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("icmp-dgram-%d", fd))
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package probes
+
+import (
+	"errors"
+	"net"
+)
+
+// listenICMPDgram is not supported on Windows; raw sockets are used instead.
+func listenICMPDgram(v6 bool) (net.PacketConn, error) {
+	return nil, errors.New("datagram ICMP sockets not supported on windows")
+}
@@ -0,0 +1,133 @@
+package probes
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// HTTPCheck describes a synthetic HTTP request and what a healthy answer
+// looks like.
+type HTTPCheck struct {
+	Name         string
+	URL          string
+	Method       string // Defaults to GET
+	Headers      map[string]string
+	ExpectStatus int    // 0 accepts any 2xx/3xx status
+	ExpectBody   string // Substring the body must contain
+	Timeout      time.Duration
+}
+
+// maxCheckBody limits how much of a response body is searched.
+const maxCheckBody = 1 << 20
+
+// RunHTTPCheck performs a check on a fresh connection and records the DNS,
+// connect, TLS and time-to-first-byte timings of the request.
+func RunHTTPCheck(ctx context.Context, check HTTPCheck) *model.HTTPCheckResult {
+	method := check.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	result := &model.HTTPCheckResult{
+		Name:      check.Name,
+		URL:       check.URL,
+		Method:    method,
+		Timestamp: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dnsStart, connectStart, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			result.DNSMs = msSince(dnsStart)
+		},
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				result.ConnectMs = msSince(connectStart)
+			}
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				result.TLSMs = msSince(tlsStart)
+			}
+		},
+		GotFirstResponseByte: func() {
+			result.TTFBMs = msSince(result.Timestamp)
+		},
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), method, check.URL, nil)
+	if err != nil {
+		result.Error = fmt.Sprintf("invalid request: %v", err)
+		return result
+	}
+	req.Header.Set("User-Agent", "netpulse")
+	for name, value := range check.Headers {
+		req.Header.Set(name, value)
+	}
+
+	// A new transport per check keeps connection reuse from hiding the
+	// DNS, connect and TLS phases.
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			DisableKeepAlives: true,
+		},
+	}
+	defer client.CloseIdleConnections()
+	if check.ExpectStatus >= 300 && check.ExpectStatus < 400 {
+		// The redirect itself is what the check expects
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.TotalMs = msSince(result.Timestamp)
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCheckBody))
+	result.TotalMs = msSince(result.Timestamp)
+	result.StatusCode = resp.StatusCode
+
+	switch {
+	case err != nil:
+		result.Error = fmt.Sprintf("failed to read body: %v", err)
+	case check.ExpectStatus != 0 && resp.StatusCode != check.ExpectStatus:
+		result.Error = fmt.Sprintf("unexpected status %d (want %d)", resp.StatusCode, check.ExpectStatus)
+	case check.ExpectStatus == 0 && resp.StatusCode >= 400:
+		result.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	case check.ExpectBody != "" && !strings.Contains(string(body), check.ExpectBody):
+		result.Error = fmt.Sprintf("body does not contain %q", check.ExpectBody)
+	default:
+		result.Success = true
+	}
+
+	return result
+}
+
+// msSince returns the milliseconds elapsed since t.
+func msSince(t time.Time) float64 {
+	return float64(time.Since(t).Microseconds()) / 1000.0
+}
@@ -0,0 +1,253 @@
+package probes
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// ICMPv4 message types used by the native probes.
+const (
+	icmpEchoReply       = 0
+	icmpDestUnreachable = 3
+	icmpEchoRequest     = 8
+	icmpTimeExceeded    = 11
+)
+
+// ICMPv4 destination unreachable codes.
+const (
+	icmpCodeNetUnreachable   = 0
+	icmpCodeHostUnreachable  = 1
+	icmpCodeProtoUnreachable = 2
+	icmpCodePortUnreachable  = 3
+	icmpCodeFragNeeded       = 4
+	icmpCodeAdminProhibited  = 13
+)
+
+// ICMPv6 message types used by the native probes.
+const (
+	icmp6DestUnreachable = 1
+	icmp6PacketTooBig    = 2
+	icmp6TimeExceeded    = 3
+	icmp6EchoRequest     = 128
+	icmp6EchoReply       = 129
+)
+
+// ICMPv6 destination unreachable codes.
+const (
+	icmp6CodeNoRoute         = 0
+	icmp6CodeAdminProhibited = 1
+	icmp6CodeAddrUnreachable = 3
+	icmp6CodePortUnreachable = 4
+)
+
+// IP protocol numbers found in quoted packets.
+const (
+	protoICMP   = 1
+	protoTCP    = 6
+	protoUDP    = 17
+	protoICMPv6 = 58
+)
+
+// icmpMessage is a parsed ICMP message.
+type icmpMessage struct {
+	Type int
+	Code int
+	ID   int // Echo identifier
+	Seq  int // Echo sequence number
+	MTU  int // Next-hop MTU for "fragmentation needed" / "packet too big"
+	V6   bool
+
+	// Quoted holds the headers of the original datagram for error messages.
+	Quoted *quotedPacket
+}
+
+// quotedPacket holds the headers of the datagram that triggered an ICMP error.
+type quotedPacket struct {
+	Protocol int
+	Src      net.IP
+	Dst      net.IP
+	TotalLen int
+	// PayloadLen is the length of the transport header and data.
+	PayloadLen int
+
+	// UDP/TCP ports
+	SrcPort int
+	DstPort int
+
+	// ICMP echo fields
+	ID  int
+	Seq int
+}
+
+// isError reports whether the message is an ICMP error carrying a quoted packet.
+func (m *icmpMessage) isError() bool {
+	return m.Quoted != nil
+}
+
+// isEchoReply reports whether the message is an echo reply.
+func (m *icmpMessage) isEchoReply() bool {
+	if m.V6 {
+		return m.Type == icmp6EchoReply
+	}
+	return m.Type == icmpEchoReply
+}
+
+// isDestUnreachable reports whether the message is a destination unreachable error.
+func (m *icmpMessage) isDestUnreachable() bool {
+	if m.V6 {
+		return m.Type == icmp6DestUnreachable
+	}
+	return m.Type == icmpDestUnreachable
+}
+
+// parseICMPv4 parses an ICMPv4 message without the outer IP header.
+func parseICMPv4(b []byte) (*icmpMessage, error) {
+	if len(b) < 8 {
+		return nil, fmt.Errorf("icmp message too short: %d bytes", len(b))
+	}
+
+	msg := &icmpMessage{
+		Type: int(b[0]),
+		Code: int(b[1]),
+	}
+
+	switch msg.Type {
+	case icmpEchoReply, icmpEchoRequest:
+		msg.ID = int(binary.BigEndian.Uint16(b[4:6]))
+		msg.Seq = int(binary.BigEndian.Uint16(b[6:8]))
+	case icmpDestUnreachable, icmpTimeExceeded:
+		if msg.Type == icmpDestUnreachable && msg.Code == icmpCodeFragNeeded {
+			msg.MTU = int(binary.BigEndian.Uint16(b[6:8]))
+		}
+		quoted, err := parseQuotedIPv4(b[8:])
+		if err != nil {
+			return nil, err
+		}
+		msg.Quoted = quoted
+	}
+
+	return msg, nil
+}
+
+// parseQuotedIPv4 parses the IPv4 header and first 8 payload bytes embedded in
+// an ICMP error message.
+func parseQuotedIPv4(b []byte) (*quotedPacket, error) {
+	if len(b) < 20 || b[0]>>4 != 4 {
+		return nil, fmt.Errorf("invalid quoted IPv4 header")
+	}
+	ihl := int(b[0]&0x0f) * 4
+	if ihl < 20 || len(b) < ihl+8 {
+		return nil, fmt.Errorf("truncated quoted packet")
+	}
+
+	q := &quotedPacket{
+		Protocol: int(b[9]),
+		Src:      net.IP(append([]byte(nil), b[12:16]...)),
+		Dst:      net.IP(append([]byte(nil), b[16:20]...)),
+		TotalLen: int(binary.BigEndian.Uint16(b[2:4])),
+	}
+	q.PayloadLen = q.TotalLen - ihl
+	parseQuotedTransport(q, b[ihl:ihl+8], protoICMP)
+
+	return q, nil
+}
+
+// parseICMPv6 parses an ICMPv6 message. Raw IPv6 sockets never include the
+// IPv6 header.
+func parseICMPv6(b []byte) (*icmpMessage, error) {
+	if len(b) < 8 {
+		return nil, fmt.Errorf("icmpv6 message too short: %d bytes", len(b))
+	}
+
+	msg := &icmpMessage{
+		Type: int(b[0]),
+		Code: int(b[1]),
+		V6:   true,
+	}
+
+	switch msg.Type {
+	case icmp6EchoReply, icmp6EchoRequest:
+		msg.ID = int(binary.BigEndian.Uint16(b[4:6]))
+		msg.Seq = int(binary.BigEndian.Uint16(b[6:8]))
+	case icmp6DestUnreachable, icmp6PacketTooBig, icmp6TimeExceeded:
+		if msg.Type == icmp6PacketTooBig {
+			msg.MTU = int(binary.BigEndian.Uint32(b[4:8]))
+		}
+		quoted, err := parseQuotedIPv6(b[8:])
+		if err != nil {
+			return nil, err
+		}
+		msg.Quoted = quoted
+	}
+
+	return msg, nil
+}
+
+// parseQuotedIPv6 parses the IPv6 header and first 8 payload bytes embedded in
+// an ICMPv6 error message. Extension headers are not followed.
+func parseQuotedIPv6(b []byte) (*quotedPacket, error) {
+	if len(b) < 48 || b[0]>>4 != 6 {
+		return nil, fmt.Errorf("invalid quoted IPv6 header")
+	}
+
+	q := &quotedPacket{
+		Protocol:   int(b[6]),
+		Src:        net.IP(append([]byte(nil), b[8:24]...)),
+		Dst:        net.IP(append([]byte(nil), b[24:40]...)),
+		PayloadLen: int(binary.BigEndian.Uint16(b[4:6])),
+	}
+	q.TotalLen = 40 + q.PayloadLen
+	parseQuotedTransport(q, b[40:48], protoICMPv6)
+
+	return q, nil
+}
+
+// parseQuotedTransport fills the port or echo fields of a quoted packet.
+func parseQuotedTransport(q *quotedPacket, l4 []byte, icmpProto int) {
+	switch q.Protocol {
+	case protoUDP, protoTCP:
+		q.SrcPort = int(binary.BigEndian.Uint16(l4[0:2]))
+		q.DstPort = int(binary.BigEndian.Uint16(l4[2:4]))
+	case icmpProto:
+		q.ID = int(binary.BigEndian.Uint16(l4[4:6]))
+		q.Seq = int(binary.BigEndian.Uint16(l4[6:8]))
+	}
+}
+
+// marshalEcho builds an ICMPv4 echo request with a valid checksum.
+func marshalEcho(id, seq int, payload []byte) []byte {
+	b := make([]byte, 8+len(payload))
+	b[0] = icmpEchoRequest
+	binary.BigEndian.PutUint16(b[4:6], uint16(id))
+	binary.BigEndian.PutUint16(b[6:8], uint16(seq))
+	copy(b[8:], payload)
+	binary.BigEndian.PutUint16(b[2:4], icmpChecksum(b))
+	return b
+}
+
+// marshalEcho6 builds an ICMPv6 echo request. The checksum covers an IPv6
+// pseudo-header and is filled in by the kernel.
+func marshalEcho6(id, seq int, payload []byte) []byte {
+	b := make([]byte, 8+len(payload))
+	b[0] = icmp6EchoRequest
+	binary.BigEndian.PutUint16(b[4:6], uint16(id))
+	binary.BigEndian.PutUint16(b[6:8], uint16(seq))
+	copy(b[8:], payload)
+	return b
+}
+
+// icmpChecksum computes the Internet checksum (RFC 1071) of b.
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
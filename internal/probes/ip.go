@@ -3,32 +3,84 @@ package probes
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/user/netpulse/internal/dnsmsg"
+	"github.com/user/netpulse/internal/model"
+)
+
+// Address families tracked for the public IP.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// Public IP detection methods.
+const (
+	IPMethodHTTP = "http" // HTTPS service returning the address as text
+	IPMethodSTUN = "stun" // STUN binding request (RFC 5389)
+	IPMethodDNS  = "dns"  // DNS server answering with the querying address
 )
 
-// IPProvider represents a public IP provider.
+// DefaultIPQuorum is how many providers must report the same address.
+const DefaultIPQuorum = 2
+
+// IPProvider is a service that reports the address a request came from.
 type IPProvider struct {
-	Name string
-	URL  string
+	Name   string
+	Method string // http (default), stun or dns
+	URL    string // http
+	Server string // stun and dns: host[:port]
+	Query  string // dns: name answered with the client address
+	Record string // dns: "A" (A or AAAA by family) or "TXT"
 }
 
 // DefaultIPProviders returns the default IP providers.
 func DefaultIPProviders() []IPProvider {
 	return []IPProvider{
-		{Name: "ipify", URL: "https://api.ipify.org"},
-		{Name: "ifconfig.me", URL: "https://ifconfig.me/ip"},
-		{Name: "icanhazip", URL: "https://icanhazip.com"},
+		{Name: "ipify", Method: IPMethodHTTP, URL: "https://api64.ipify.org"},
+		{Name: "ifconfig.me", Method: IPMethodHTTP, URL: "https://ifconfig.me/ip"},
+		{Name: "icanhazip", Method: IPMethodHTTP, URL: "https://icanhazip.com"},
+		{Name: "google-stun", Method: IPMethodSTUN, Server: "stun.l.google.com:19302"},
+		{Name: "cloudflare-stun", Method: IPMethodSTUN, Server: "stun.cloudflare.com:3478"},
+		{Name: "opendns", Method: IPMethodDNS, Server: "resolver1.opendns.com", Query: "myip.opendns.com", Record: "A"},
+		// Must be asked directly: through a resolver it reports the resolver
+		{Name: "google-dns", Method: IPMethodDNS, Server: "ns1.google.com", Query: "o-o.myaddr.l.google.com", Record: "TXT"},
 	}
 }
 
+// IPDetection is the outcome of asking every provider for the public
+// address of one family.
+type IPDetection struct {
+	Family  string
+	IP      string // Address reported by the most providers, quorum or not
+	Votes   int
+	Quorum  int
+	Answers []model.IPProviderCheck
+}
+
+// Disagreeing returns the providers that reported another address.
+func (d *IPDetection) Disagreeing() []model.IPProviderCheck {
+	var answers []model.IPProviderCheck
+	for _, a := range d.Answers {
+		if a.Success && !a.Agreed {
+			answers = append(answers, a)
+		}
+	}
+	return answers
+}
+
 // IPProbe handles public IP detection.
 type IPProbe struct {
 	providers []IPProvider
+	quorum    int
 	client    *http.Client
 	timeout   time.Duration
 }
@@ -37,6 +89,7 @@ type IPProbe struct {
 func NewIPProbe() *IPProbe {
 	return &IPProbe{
 		providers: DefaultIPProviders(),
+		quorum:    DefaultIPQuorum,
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
@@ -44,59 +97,247 @@ func NewIPProbe() *IPProbe {
 	}
 }
 
+// SetProviders replaces the providers; an empty list keeps the defaults.
+func (p *IPProbe) SetProviders(providers []IPProvider) {
+	if len(providers) > 0 {
+		p.providers = providers
+	}
+}
+
+// SetQuorum sets how many providers must agree on the address. It is
+// capped at the number of providers.
+func (p *IPProbe) SetQuorum(quorum int) {
+	if quorum > 0 {
+		p.quorum = quorum
+	}
+}
+
 // GetPublicIP returns the public IP using consensus from multiple providers.
+// The address family is whatever the system prefers for outgoing connections.
 func (p *IPProbe) GetPublicIP(ctx context.Context) (string, error) {
-	results := make(chan string, len(p.providers))
-	errors := make(chan error, len(p.providers))
-	
-	for _, provider := range p.providers {
-		go func(prov IPProvider) {
-			ip, err := p.fetchIP(ctx, prov.URL)
+	return p.detectIP(ctx, "")
+}
+
+// GetPublicIPv4 returns the public IPv4 address.
+func (p *IPProbe) GetPublicIPv4(ctx context.Context) (string, error) {
+	return p.detectIP(ctx, FamilyIPv4)
+}
+
+// GetPublicIPv6 returns the public IPv6 address. It fails when the host has
+// no IPv6 connectivity.
+func (p *IPProbe) GetPublicIPv6(ctx context.Context) (string, error) {
+	return p.detectIP(ctx, FamilyIPv6)
+}
+
+func (p *IPProbe) detectIP(ctx context.Context, family string) (string, error) {
+	d, err := p.Detect(ctx, family)
+	if err != nil {
+		return "", err
+	}
+	return d.IP, nil
+}
+
+// Detect asks every provider, in parallel and over the given family only,
+// for the public address. The detection is always returned with each
+// provider's answer; an error means fewer providers than the quorum agreed.
+func (p *IPProbe) Detect(ctx context.Context, family string) (*IPDetection, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	client := p.clientFor(family)
+	now := time.Now()
+	d := &IPDetection{Family: family, Answers: make([]model.IPProviderCheck, len(p.providers))}
+
+	var wg sync.WaitGroup
+	for i, provider := range p.providers {
+		wg.Add(1)
+		go func(i int, prov IPProvider) {
+			defer wg.Done()
+			start := time.Now()
+			ip, err := p.ask(ctx, client, prov, family)
+			a := model.IPProviderCheck{
+				Provider:  prov.Name,
+				Method:    providerMethod(prov),
+				Family:    family,
+				IP:        ip,
+				Success:   err == nil,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000.0,
+				Timestamp: now,
+			}
 			if err != nil {
-				errors <- fmt.Errorf("%s: %w", prov.Name, err)
-				return
+				a.Error = err.Error()
 			}
-			results <- ip
-		}(provider)
+			d.Answers[i] = a
+		}(i, provider)
 	}
-	
-	// Collect results
+	wg.Wait()
+
+	var errs []string
 	var ips []string
-	var errs []error
-	
-	timeout := time.After(p.timeout)
-	for i := 0; i < len(p.providers); i++ {
-		select {
-		case ip := <-results:
-			ips = append(ips, ip)
-		case err := <-errors:
-			errs = append(errs, err)
-		case <-timeout:
-			break
-		case <-ctx.Done():
-			return "", ctx.Err()
+	for _, a := range d.Answers {
+		if a.Success {
+			ips = append(ips, a.IP)
+		} else {
+			errs = append(errs, fmt.Sprintf("%s: %s", a.Provider, a.Error))
 		}
 	}
-	
 	if len(ips) == 0 {
 		if len(errs) > 0 {
-			return "", fmt.Errorf("all providers failed: %v", errs)
+			return d, fmt.Errorf("all providers failed: %s", strings.Join(errs, "; "))
 		}
-		return "", fmt.Errorf("no IP providers responded")
+		return d, fmt.Errorf("no IP providers configured")
+	}
+
+	d.IP, d.Votes = p.consensus(ips)
+	for i := range d.Answers {
+		d.Answers[i].Agreed = d.Answers[i].Success && d.Answers[i].IP == d.IP
+	}
+
+	d.Quorum = p.quorum
+	if d.Quorum > len(p.providers) {
+		d.Quorum = len(p.providers)
+	}
+	if d.Votes < d.Quorum {
+		return d, fmt.Errorf("no quorum: %s reported by %d of %d required providers", d.IP, d.Votes, d.Quorum)
+	}
+	return d, nil
+}
+
+// ask queries one provider.
+func (p *IPProbe) ask(ctx context.Context, client *http.Client, prov IPProvider, family string) (string, error) {
+	var ip string
+	var err error
+	switch providerMethod(prov) {
+	case IPMethodSTUN:
+		ip, err = p.askSTUN(ctx, prov, family)
+	case IPMethodDNS:
+		ip, err = p.askDNS(ctx, prov, family)
+	case IPMethodHTTP:
+		return p.fetchIP(ctx, client, prov.URL, family)
+	default:
+		return "", fmt.Errorf("unknown method %q", prov.Method)
+	}
+	if err != nil {
+		return "", err
+	}
+	if family != "" && IPFamily(ip) != family {
+		return "", fmt.Errorf("expected %s address, got %s", family, ip)
+	}
+	return ip, nil
+}
+
+func providerMethod(prov IPProvider) string {
+	if prov.Method == "" {
+		return IPMethodHTTP
+	}
+	return prov.Method
+}
+
+// askSTUN sends a binding request over the given family.
+func (p *IPProbe) askSTUN(ctx context.Context, prov IPProvider, family string) (string, error) {
+	server, err := resolveServer(ctx, prov.Server, "3478", family)
+	if err != nil {
+		return "", err
+	}
+	ip, err := QuerySTUN(ctx, server, p.timeout)
+	if err != nil {
+		return "", err
+	}
+	return ip.String(), nil
+}
+
+// askDNS asks a DNS server that answers with the address of the client,
+// as an A/AAAA record or as the first TXT string holding an address.
+func (p *IPProbe) askDNS(ctx context.Context, prov IPProvider, family string) (string, error) {
+	server, err := resolveServer(ctx, prov.Server, "53", family)
+	if err != nil {
+		return "", err
+	}
+	qtype := dnsmsg.TypeTXT
+	if !strings.EqualFold(prov.Record, "TXT") {
+		qtype = dnsmsg.TypeA
+		if host, _, _ := net.SplitHostPort(server); IPFamily(host) == FamilyIPv6 {
+			qtype = dnsmsg.TypeAAAA
+		}
+	}
+
+	query := dnsmsg.NewQuery(uint16(rand.Intn(0xffff)), prov.Query, qtype, dnsmsg.ClassINET)
+	resp, err := exchangeUDP(ctx, query, server, p.timeout)
+	if err != nil {
+		return "", err
+	}
+	msg, err := dnsmsg.Unpack(resp)
+	if err != nil {
+		return "", err
+	}
+	if msg.Rcode != dnsmsg.RcodeSuccess {
+		return "", fmt.Errorf("%s", dnsmsg.RcodeString(msg.Rcode))
+	}
+	for _, rr := range msg.Answers {
+		if rr.Type != qtype {
+			continue
+		}
+		if rr.IP != nil {
+			return rr.IP.String(), nil
+		}
+		for _, txt := range rr.TXT {
+			if isValidIP(txt) {
+				return txt, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no address in answer")
+}
+
+// resolveServer resolves host[:port] to an address of the given family
+// ("" for any), so the request leaves over that family.
+func resolveServer(ctx context.Context, server, defaultPort, family string) (string, error) {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host, port = strings.Trim(server, "[]"), defaultPort
+	}
+	network := "ip"
+	switch family {
+	case FamilyIPv4:
+		network = "ip4"
+	case FamilyIPv6:
+		network = "ip6"
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, network, host)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ips[0].String(), port), nil
+}
+
+// clientFor returns an HTTP client that only connects over the given family.
+func (p *IPProbe) clientFor(family string) *http.Client {
+	network := ""
+	switch family {
+	case FamilyIPv4:
+		network = "tcp4"
+	case FamilyIPv6:
+		network = "tcp6"
+	default:
+		return p.client
 	}
 	
-	// Return consensus IP (most common)
-	return p.consensus(ips), nil
+	dialer := &net.Dialer{Timeout: p.timeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return &http.Client{Timeout: p.client.Timeout, Transport: transport}
 }
 
-func (p *IPProbe) fetchIP(ctx context.Context, url string) (string, error) {
+func (p *IPProbe) fetchIP(ctx context.Context, client *http.Client, url, family string) (string, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("User-Agent", "netpulse/1.0")
 	
-	resp, err := p.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -115,11 +356,16 @@ func (p *IPProbe) fetchIP(ctx context.Context, url string) (string, error) {
 	if !isValidIP(ip) {
 		return "", fmt.Errorf("invalid IP: %s", ip)
 	}
+	if family != "" && IPFamily(ip) != family {
+		return "", fmt.Errorf("expected %s address, got %s", family, ip)
+	}
 	
 	return ip, nil
 }
 
-func (p *IPProbe) consensus(ips []string) string {
+// consensus returns the address reported most often and its count. Ties go
+// to the address reported by the earlier provider.
+func (p *IPProbe) consensus(ips []string) (string, int) {
 	counts := make(map[string]int)
 	for _, ip := range ips {
 		counts[ip]++
@@ -127,59 +373,30 @@ func (p *IPProbe) consensus(ips []string) string {
 	
 	var maxIP string
 	var maxCount int
-	for ip, count := range counts {
-		if count > maxCount {
+	for _, ip := range ips {
+		if counts[ip] > maxCount {
 			maxIP = ip
-			maxCount = count
+			maxCount = counts[ip]
 		}
 	}
 	
-	return maxIP
+	return maxIP, maxCount
 }
 
 func isValidIP(ip string) bool {
-	// Basic validation: contains dots (IPv4) or colons (IPv6)
-	if len(ip) < 7 || len(ip) > 45 {
-		return false
-	}
-	return strings.Contains(ip, ".") || strings.Contains(ip, ":")
-}
-
-// ASNInfo holds ASN and ISP information.
-type ASNInfo struct {
-	IP      string `json:"query"`
-	ASN     string `json:"as"`
-	ISP     string `json:"isp"`
-	Org     string `json:"org"`
-	Country string `json:"country"`
-	City    string `json:"city"`
+	return net.ParseIP(ip) != nil
 }
 
-// GetASNInfo fetches ASN and ISP information for an IP.
-func GetASNInfo(ctx context.Context, ip string) (*ASNInfo, error) {
-	// Using ip-api.com (free, no auth required)
-	url := fmt.Sprintf("http://ip-api.com/json/%s?fields=query,as,isp,org,country,city", ip)
-	
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch ASN info: %w", err)
+// IPFamily returns FamilyIPv4 or FamilyIPv6 for an address, or "" if it
+// cannot be parsed.
+func IPFamily(ip string) string {
+	parsed := net.ParseIP(ip)
+	switch {
+	case parsed == nil:
+		return ""
+	case parsed.To4() != nil:
+		return FamilyIPv4
+	default:
+		return FamilyIPv6
 	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ASN API returned status %d", resp.StatusCode)
-	}
-	
-	var info ASNInfo
-	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		return nil, fmt.Errorf("failed to decode ASN response: %w", err)
-	}
-	
-	return &info, nil
 }
@@ -0,0 +1,442 @@
+package probes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/netpulse/internal/dnsmsg"
+)
+
+// Discovery sources for devices found by multicast service discovery.
+const (
+	DiscoveredByMDNS = "mdns"
+	DiscoveredBySSDP = "ssdp"
+)
+
+var (
+	mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+	ssdpGroup = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+)
+
+// dnssdServices is the DNS-SD meta-query listing every advertised service type.
+const dnssdServices = "_services._dns-sd._udp.local."
+
+// LANDevice is a device that announced itself over mDNS or SSDP.
+type LANDevice struct {
+	IP       string
+	Hostname string
+	Services []string // e.g. _ipp._tcp, upnp:MediaRenderer
+	Info     string   // Friendly name and model
+	Source   string
+}
+
+// packet is a datagram received during discovery.
+type packet struct {
+	from net.IP
+	data []byte
+}
+
+// listenDiscovery reads datagrams from conns until ctx is done.
+func listenDiscovery(ctx context.Context, conns ...*net.UDPConn) <-chan packet {
+	ch := make(chan packet, 64)
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		if conn == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(conn *net.UDPConn) {
+			defer wg.Done()
+			buf := make([]byte, 9000)
+			for {
+				if deadline, ok := ctx.Deadline(); ok {
+					conn.SetReadDeadline(deadline)
+				}
+				n, from, err := conn.ReadFromUDP(buf)
+				if err != nil {
+					return
+				}
+				select {
+				case ch <- packet{from: from.IP, data: append([]byte(nil), buf[:n]...)}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(conn)
+	}
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+	return ch
+}
+
+// mdnsInstance is a DNS-SD service instance, e.g.
+// "Living Room._googlecast._tcp.local.".
+type mdnsInstance struct {
+	service string
+	host    string
+	txt     map[string]string
+	from    net.IP
+}
+
+// mdnsBrowser collects DNS-SD records.
+type mdnsBrowser struct {
+	conn      *net.UDPConn
+	types     map[string]bool
+	instances map[string]*mdnsInstance
+	addrs     map[string][]net.IP // host name -> addresses
+	hostOf    map[string]string   // responder IP -> host name from A records
+}
+
+// DiscoverMDNS browses DNS-SD services for the given window. Queries ask for
+// unicast replies; announcements on the multicast group are picked up too
+// when port 5353 can be shared.
+func DiscoverMDNS(ctx context.Context, window time.Duration) ([]LANDevice, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Passive listener; not fatal if another responder owns the port
+	group, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err == nil {
+		defer group.Close()
+	}
+
+	b := &mdnsBrowser{
+		conn:      conn,
+		types:     make(map[string]bool),
+		instances: make(map[string]*mdnsInstance),
+		addrs:     make(map[string][]net.IP),
+		hostOf:    make(map[string]string),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+	packets := listenDiscovery(ctx, conn, group)
+
+	b.query(dnssdServices, dnsmsg.TypePTR)
+	for p := range packets {
+		msg, err := dnsmsg.Unpack(p.data)
+		if err != nil || !msg.Response {
+			continue
+		}
+		b.handle(p.from, msg)
+	}
+
+	return b.devices(), nil
+}
+
+// query sends a question to the mDNS group asking for a unicast reply.
+func (b *mdnsBrowser) query(name string, qtype uint16) {
+	msg := dnsmsg.NewQuery(0, name, qtype, dnsmsg.ClassINET|dnsmsg.ClassUnicastResponse)
+	msg.RecursionDesired = false
+	if data, err := msg.Pack(); err == nil {
+		b.conn.WriteToUDP(data, mdnsGroup)
+	}
+}
+
+// handle records the answers of one response and queries whatever it
+// points at that is still unknown.
+func (b *mdnsBrowser) handle(from net.IP, msg *dnsmsg.Message) {
+	records := append(append([]dnsmsg.RR(nil), msg.Answers...), msg.Additional...)
+
+	var follow []string
+	for _, rr := range records {
+		name := strings.ToLower(rr.Name)
+		switch rr.Type {
+		case dnsmsg.TypePTR:
+			if name == dnssdServices {
+				if !b.types[rr.Target] {
+					b.types[rr.Target] = true
+					b.query(rr.Target, dnsmsg.TypePTR)
+				}
+			} else if strings.HasPrefix(name, "_") {
+				if _, ok := b.instances[rr.Target]; !ok {
+					b.instances[rr.Target] = &mdnsInstance{service: name, txt: map[string]string{}, from: from}
+					follow = append(follow, rr.Target)
+				}
+			}
+		case dnsmsg.TypeSRV:
+			b.instance(rr.Name, from).host = strings.ToLower(rr.Target)
+		case dnsmsg.TypeTXT:
+			inst := b.instance(rr.Name, from)
+			for _, kv := range rr.TXT {
+				k, v, _ := strings.Cut(kv, "=")
+				inst.txt[strings.ToLower(k)] = v
+			}
+		case dnsmsg.TypeA:
+			b.addrs[name] = appendIP(b.addrs[name], rr.IP)
+			b.hostOf[rr.IP.String()] = name
+		}
+	}
+
+	// Instances announced without their SRV record need a second query
+	for _, name := range follow {
+		if b.instances[name].host == "" {
+			b.query(name, dnsmsg.TypeSRV)
+		}
+	}
+}
+
+// instance returns the instance record for name, creating it if needed.
+func (b *mdnsBrowser) instance(name string, from net.IP) *mdnsInstance {
+	inst, ok := b.instances[name]
+	if !ok {
+		// Service type is everything after the instance label
+		service := ""
+		if i := strings.Index(name, "._"); i >= 0 {
+			service = strings.ToLower(name[i+1:])
+		}
+		inst = &mdnsInstance{service: service, txt: map[string]string{}, from: from}
+		b.instances[name] = inst
+	}
+	return inst
+}
+
+// devices merges instances and address records into one device per IP.
+func (b *mdnsBrowser) devices() []LANDevice {
+	byIP := make(map[string]*LANDevice)
+	get := func(ip net.IP) *LANDevice {
+		key := ip.String()
+		if dev, ok := byIP[key]; ok {
+			return dev
+		}
+		dev := &LANDevice{IP: key, Source: DiscoveredByMDNS}
+		byIP[key] = dev
+		return dev
+	}
+
+	for name, inst := range b.instances {
+		ips := b.addrs[inst.host]
+		if len(ips) == 0 && inst.from != nil {
+			ips = []net.IP{inst.from}
+		}
+		for _, ip := range ips {
+			dev := get(ip)
+			if inst.host != "" {
+				dev.Hostname = strings.TrimSuffix(inst.host, ".")
+			}
+			if inst.service != "" {
+				dev.Services = appendUnique(dev.Services, strings.TrimSuffix(strings.TrimSuffix(inst.service, "."), ".local"))
+			}
+			if dev.Info == "" {
+				dev.Info = instanceInfo(name, inst)
+			}
+		}
+	}
+
+	// Hosts that only announced an address record
+	for ip, host := range b.hostOf {
+		dev := get(net.ParseIP(ip))
+		if dev.Hostname == "" {
+			dev.Hostname = strings.TrimSuffix(host, ".")
+		}
+	}
+
+	return sortDevices(byIP)
+}
+
+// instanceInfo describes an instance by its friendly name and model, taken
+// from the usual TXT keys (fn/md for Cast, ty for IPP printers).
+func instanceInfo(name string, inst *mdnsInstance) string {
+	friendly := inst.txt["fn"]
+	if friendly == "" {
+		if i := strings.Index(name, "._"); i > 0 {
+			friendly = strings.ReplaceAll(name[:i], `\ `, " ")
+		}
+	}
+	for _, key := range []string{"md", "ty", "model", "usb_mdl"} {
+		if model := inst.txt[key]; model != "" && model != friendly {
+			return friendly + " (" + model + ")"
+		}
+	}
+	return friendly
+}
+
+// upnpDescription is the part of a UPnP device description we use.
+type upnpDescription struct {
+	Device struct {
+		FriendlyName string `xml:"friendlyName"`
+		Manufacturer string `xml:"manufacturer"`
+		ModelName    string `xml:"modelName"`
+		ModelNumber  string `xml:"modelNumber"`
+	} `xml:"device"`
+}
+
+// DiscoverSSDP sends M-SEARCH requests, listens for NOTIFY announcements
+// for the given window, then fetches the UPnP description of each device.
+func DiscoverSSDP(ctx context.Context, window time.Duration) ([]LANDevice, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	group, err := net.ListenMulticastUDP("udp4", nil, ssdpGroup)
+	if err == nil {
+		defer group.Close()
+	}
+
+	searchCtx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+	packets := listenDiscovery(searchCtx, conn, group)
+
+	// Sent twice as UDP multicast is lossy
+	for i := 0; i < 2; i++ {
+		conn.WriteToUDP([]byte(ssdpSearch), ssdpGroup)
+	}
+
+	byIP := make(map[string]*LANDevice)
+	locations := make(map[string]string)
+	for p := range packets {
+		headers, ok := parseSSDP(p.data)
+		if !ok {
+			continue
+		}
+		key := p.from.String()
+		dev, ok := byIP[key]
+		if !ok {
+			dev = &LANDevice{IP: key, Source: DiscoveredBySSDP}
+			byIP[key] = dev
+		}
+		target := headers["st"]
+		if target == "" {
+			target = headers["nt"]
+		}
+		if service := upnpService(target); service != "" {
+			dev.Services = appendUnique(dev.Services, service)
+		}
+		if loc := headers["location"]; loc != "" && locations[key] == "" {
+			locations[key] = loc
+		}
+		if dev.Info == "" {
+			dev.Info = headers["server"]
+		}
+	}
+
+	for ip, loc := range locations {
+		if ctx.Err() != nil {
+			break
+		}
+		if desc := fetchUPnPDescription(ctx, ip, loc); desc != nil {
+			d := desc.Device
+			info := strings.TrimSpace(d.Manufacturer + " " + d.ModelName + " " + d.ModelNumber)
+			if d.FriendlyName != "" {
+				info = strings.TrimSpace(d.FriendlyName + " (" + info + ")")
+				info = strings.TrimSuffix(info, " ()")
+			}
+			if info != "" {
+				byIP[ip].Info = info
+			}
+		}
+	}
+
+	return sortDevices(byIP), nil
+}
+
+// parseSSDP parses an M-SEARCH response or NOTIFY into lower-cased headers.
+func parseSSDP(data []byte) (map[string]string, bool) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	start, err := r.ReadString('\n')
+	if err != nil {
+		return nil, false
+	}
+	if !strings.HasPrefix(start, "HTTP/1.1 200") && !strings.HasPrefix(start, "NOTIFY ") {
+		return nil, false
+	}
+
+	headers := make(map[string]string)
+	for {
+		line, err := r.ReadString('\n')
+		if name, value, ok := strings.Cut(strings.TrimSpace(line), ":"); ok {
+			headers[strings.ToLower(name)] = strings.TrimSpace(value)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if headers["nts"] == "ssdp:byebye" {
+		return nil, false
+	}
+	return headers, true
+}
+
+// upnpService shortens a search target such as
+// "urn:schemas-upnp-org:device:MediaRenderer:1" to "upnp:MediaRenderer".
+// Root device and UUID targets carry no information and are dropped.
+func upnpService(target string) string {
+	parts := strings.Split(target, ":")
+	if len(parts) == 5 && parts[0] == "urn" {
+		return "upnp:" + parts[3]
+	}
+	return ""
+}
+
+// fetchUPnPDescription downloads a device description. Only locations on the
+// announcing device itself are followed.
+func fetchUPnPDescription(ctx context.Context, ip, location string) *upnpDescription {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != "http" || u.Hostname() != ip {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	var desc upnpDescription
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&desc); err != nil {
+		return nil
+	}
+	return &desc
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func appendIP(list []net.IP, ip net.IP) []net.IP {
+	for _, v := range list {
+		if v.Equal(ip) {
+			return list
+		}
+	}
+	return append(list, ip)
+}
+
+// sortDevices returns the devices ordered by IP.
+func sortDevices(byIP map[string]*LANDevice) []LANDevice {
+	devices := make([]LANDevice, 0, len(byIP))
+	for _, dev := range byIP {
+		sort.Strings(dev.Services)
+		devices = append(devices, *dev)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].IP < devices[j].IP })
+	return devices
+}
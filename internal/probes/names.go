@@ -0,0 +1,259 @@
+package probes
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/netpulse/internal/dnsmsg"
+	"github.com/user/netpulse/internal/model"
+)
+
+// Name resolvers tried for alive hosts, in the default priority order.
+const (
+	ResolverDNS     = "dns"     // Reverse DNS (PTR) through the system resolver
+	ResolverNetBIOS = "netbios" // NetBIOS Node Status (UDP 137)
+	ResolverLLMNR   = "llmnr"   // LLMNR reverse query (UDP 5355)
+)
+
+// DefaultNameResolvers returns the resolvers used when none are configured.
+func DefaultNameResolvers() []string {
+	return []string{ResolverDNS, ResolverNetBIOS, ResolverLLMNR}
+}
+
+// nbstatName is the wildcard name "*" in NetBIOS first-level encoding.
+const nbstatName = "CKAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+
+const (
+	typeNBSTAT = 0x21
+
+	nbGroupFlag = 0x8000
+)
+
+var errNoName = errors.New("no name in response")
+
+// NetBIOSInfo is what a NetBIOS Node Status response reveals about a host.
+type NetBIOSInfo struct {
+	Name      string
+	Workgroup string // Workgroup or domain
+	MAC       string
+}
+
+// resolveNames asks every configured resolver in parallel and fills the
+// hostname from the first one, in priority order, that returned a name.
+// NetBIOS also supplies the workgroup and, when ARP could not, the MAC.
+func (p *PingProbe) resolveNames(ctx context.Context, host *model.ScanHost) {
+	addr := net.ParseIP(host.IP)
+	if addr == nil {
+		return
+	}
+	timeout := p.timeout
+	if timeout > time.Second {
+		timeout = time.Second
+	}
+
+	names := make([]string, len(p.resolvers))
+	var nbInfo *NetBIOSInfo
+	var wg sync.WaitGroup
+	for i, resolver := range p.resolvers {
+		wg.Add(1)
+		go func(i int, resolver string) {
+			defer wg.Done()
+			switch resolver {
+			case ResolverDNS:
+				if res, err := net.DefaultResolver.LookupAddr(ctx, host.IP); err == nil && len(res) > 0 {
+					names[i] = res[0]
+				}
+			case ResolverNetBIOS:
+				if info, err := QueryNetBIOS(ctx, addr, timeout); err == nil {
+					names[i] = info.Name
+					nbInfo = info
+				}
+			case ResolverLLMNR:
+				if name, err := QueryLLMNR(ctx, addr, timeout); err == nil {
+					names[i] = name
+				}
+			}
+		}(i, resolver)
+	}
+	wg.Wait()
+
+	for _, name := range names {
+		if name != "" {
+			host.Hostname = name
+			break
+		}
+	}
+	if nbInfo != nil {
+		host.Workgroup = nbInfo.Workgroup
+		if host.MAC == "" {
+			host.MAC = nbInfo.MAC
+		}
+	}
+}
+
+// QueryNetBIOS sends a NetBIOS Node Status request (RFC 1002) to an IPv4
+// host and returns its computer name, workgroup and adapter MAC.
+func QueryNetBIOS(ctx context.Context, ip net.IP, timeout time.Duration) (*NetBIOSInfo, error) {
+	if ip.To4() == nil {
+		return nil, errors.New("netbios is IPv4 only")
+	}
+	query := dnsmsg.NewQuery(uint16(rand.Intn(0xffff)), nbstatName, typeNBSTAT, dnsmsg.ClassINET)
+	query.RecursionDesired = false
+
+	resp, err := exchangeUDP(ctx, query, net.JoinHostPort(ip.String(), "137"), timeout)
+	if err != nil {
+		return nil, err
+	}
+	return parseNBSTAT(resp)
+}
+
+// parseNBSTAT decodes a Node Status response. It is parsed by hand because
+// the NBSTAT record type collides with SRV.
+func parseNBSTAT(b []byte) (*NetBIOSInfo, error) {
+	if len(b) < 12 || binary.BigEndian.Uint16(b[6:8]) == 0 {
+		return nil, errNoName
+	}
+	off := 12
+	for i := binary.BigEndian.Uint16(b[4:6]); i > 0 && off >= 0; i-- {
+		if off = skipName(b, off); off >= 0 {
+			off += 4
+		}
+	}
+	if off >= 0 {
+		off = skipName(b, off)
+	}
+	if off < 0 || off+10 > len(b) || binary.BigEndian.Uint16(b[off:]) != typeNBSTAT {
+		return nil, errNoName
+	}
+	length := int(binary.BigEndian.Uint16(b[off+8:]))
+	off += 10
+	if off+length > len(b) || length < 1 {
+		return nil, errNoName
+	}
+	data := b[off : off+length]
+
+	count := int(data[0])
+	data = data[1:]
+	if len(data) < count*18 {
+		return nil, errNoName
+	}
+
+	info := &NetBIOSInfo{}
+	for i := 0; i < count; i++ {
+		entry := data[i*18 : (i+1)*18]
+		name := strings.TrimRight(string(entry[:15]), " \x00")
+		suffix := entry[15]
+		group := binary.BigEndian.Uint16(entry[16:18])&nbGroupFlag != 0
+		if suffix != 0x00 || name == "" {
+			continue
+		}
+		if group {
+			if info.Workgroup == "" {
+				info.Workgroup = name
+			}
+		} else if info.Name == "" {
+			info.Name = name
+		}
+	}
+
+	// The statistics block starts with the adapter's unit ID (MAC)
+	if stats := data[count*18:]; len(stats) >= 6 {
+		mac := net.HardwareAddr(stats[:6])
+		if !isZeroMAC(mac) {
+			info.MAC = mac.String()
+		}
+	}
+
+	if info.Name == "" {
+		return nil, errNoName
+	}
+	return info, nil
+}
+
+// QueryLLMNR sends a unicast LLMNR reverse query (RFC 4795) to the host
+// and returns the name it answers with.
+func QueryLLMNR(ctx context.Context, ip net.IP, timeout time.Duration) (string, error) {
+	query := dnsmsg.NewQuery(uint16(rand.Intn(0xffff)), dnsmsg.ReverseName(ip), dnsmsg.TypePTR, dnsmsg.ClassINET)
+	query.RecursionDesired = false
+
+	resp, err := exchangeUDP(ctx, query, net.JoinHostPort(ip.String(), "5355"), timeout)
+	if err != nil {
+		return "", err
+	}
+	msg, err := dnsmsg.Unpack(resp)
+	if err != nil {
+		return "", err
+	}
+	for _, rr := range msg.Answers {
+		if rr.Type == dnsmsg.TypePTR && rr.Target != "" {
+			return strings.TrimSuffix(rr.Target, "."), nil
+		}
+	}
+	return "", errNoName
+}
+
+// exchangeUDP sends a query and returns the first datagram answering its ID.
+func exchangeUDP(ctx context.Context, query *dnsmsg.Message, addr string, timeout time.Duration) ([]byte, error) {
+	data, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	if _, err := conn.Write(data); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if n >= 12 && binary.BigEndian.Uint16(buf[0:2]) == query.ID {
+			return buf[:n], nil
+		}
+	}
+}
+
+// skipName returns the offset after the name at off, or -1.
+func skipName(b []byte, off int) int {
+	for off >= 0 && off < len(b) {
+		c := int(b[off])
+		switch {
+		case c == 0:
+			return off + 1
+		case c&0xc0 == 0xc0:
+			return off + 2
+		default:
+			off += 1 + c
+		}
+	}
+	return -1
+}
+
+func isZeroMAC(mac net.HardwareAddr) bool {
+	for _, b := range mac {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package probes
+
+import (
+	"context"
+	"net"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// Discovery methods for hosts found through the link layer.
+const (
+	DetectedByARP = "arp" // IPv4 ARP reply or neighbor cache entry
+	DetectedByNDP = "ndp" // IPv6 neighbor cache entry
+)
+
+// allNodes is the IPv6 link-local all-nodes multicast group.
+var allNodes = net.ParseIP("ff02::1")
+
+// neighbor is an entry from the kernel's neighbor (ARP/NDP) cache.
+type neighbor struct {
+	IP        net.IP
+	MAC       net.HardwareAddr
+	Interface string
+	Reachable bool // Confirmed recently rather than stale
+}
+
+// discoverIPv6 finds hosts in an IPv6 prefix. The prefix is far too large to
+// sweep, so the all-nodes group is pinged to fill the neighbor cache and the
+// cache entries inside the prefix are probed instead.
+func (p *PingProbe) discoverIPv6(ctx context.Context, prefix *net.IPNet) ([]model.ScanHost, error) {
+	replies := p.pingAllNodes(ctx, prefix)
+
+	candidates := make(map[string]bool)
+	neighbors, err := readNeighbors(syscall.AF_INET6)
+	if err != nil && len(replies) == 0 {
+		return nil, err
+	}
+	for _, n := range neighbors {
+		if prefix.Contains(n.IP) {
+			candidates[n.IP.String()] = true
+		}
+	}
+	for ip := range replies {
+		candidates[ip] = true
+	}
+
+	ips := make([]string, 0, len(candidates))
+	for ip := range candidates {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	hosts := p.PingHosts(ctx, ips)
+	for i := range hosts {
+		if hosts[i].Alive {
+			continue
+		}
+		if rtt, ok := replies[hosts[i].IP]; ok {
+			hosts[i].Alive = true
+			hosts[i].LatencyMs = rtt
+			hosts[i].DetectedBy = SweepMethodICMP
+		}
+	}
+	applyNeighbors(hosts, neighbors, DetectedByNDP)
+
+	return hosts, nil
+}
+
+// applyNeighbors copies MAC addresses from neighbor entries onto the matching
+// hosts. Hosts that ignored ICMP and TCP but have a confirmed neighbor entry
+// are marked alive.
+func applyNeighbors(hosts []model.ScanHost, neighbors []neighbor, detectedBy string) {
+	byIP := make(map[string]neighbor, len(neighbors))
+	for _, n := range neighbors {
+		if len(n.MAC) > 0 {
+			byIP[n.IP.String()] = n
+		}
+	}
+
+	for i := range hosts {
+		n, ok := byIP[hosts[i].IP]
+		if !ok {
+			continue
+		}
+		hosts[i].MAC = n.MAC.String()
+		if !hosts[i].Alive && n.Reachable {
+			hosts[i].Alive = true
+			hosts[i].DetectedBy = detectedBy
+		}
+	}
+}
+
+// pingAllNodes sends an ICMPv6 echo to ff02::1 on every interface with an
+// address in the prefix and returns the round-trip time per responder. The
+// socket is bound to the prefix address so hosts answer from their address in
+// the same prefix rather than their link-local one. It needs raw socket
+// privileges and returns nothing without them.
+func (p *PingProbe) pingAllNodes(ctx context.Context, prefix *net.IPNet) map[string]float64 {
+	replies := make(map[string]float64)
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return replies
+	}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.To4() != nil || !prefix.Contains(ipnet.IP) {
+				continue
+			}
+			for ip, rtt := range p.pingGroup(ctx, ipnet.IP, iface.Name, prefix) {
+				replies[ip] = rtt
+			}
+			break
+		}
+	}
+
+	return replies
+}
+
+// pingGroup pings the all-nodes group from src on one interface.
+func (p *PingProbe) pingGroup(ctx context.Context, src net.IP, iface string, prefix *net.IPNet) map[string]float64 {
+	replies := make(map[string]float64)
+
+	conn, err := net.ListenPacket("ip6:ipv6-icmp", src.String())
+	if err != nil {
+		return replies
+	}
+	defer conn.Close()
+
+	id := int(time.Now().UnixNano() & 0xffff)
+	start := time.Now()
+	if _, err := conn.WriteTo(marshalEcho6(id, 1, []byte("netpulse")), &net.IPAddr{IP: allNodes, Zone: iface}); err != nil {
+		return replies
+	}
+
+	buf := make([]byte, 1500)
+	conn.SetReadDeadline(start.Add(p.timeout))
+	for ctx.Err() == nil {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		msg, err := parseICMPv6(buf[:n])
+		if err != nil || !msg.isEchoReply() || msg.ID != id {
+			continue
+		}
+		addr, ok := from.(*net.IPAddr)
+		if !ok || !prefix.Contains(addr.IP) || addr.IP.Equal(src) {
+			continue
+		}
+		replies[addr.IP.String()] = float64(time.Since(start).Microseconds()) / 1000.0
+	}
+
+	return replies
+}
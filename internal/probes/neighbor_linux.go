@@ -0,0 +1,96 @@
+package probes
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// Neighbor cache message layout and states (linux/neighbour.h).
+const (
+	ndmsgLen  = 12 // sizeof(struct ndmsg)
+	ndaDst    = 1
+	ndaLLAddr = 2
+
+	nudIncomplete = 0x01
+	nudReachable  = 0x02
+	nudFailed     = 0x20
+	nudNoARP      = 0x40
+	nudPermanent  = 0x80
+)
+
+// readNeighbors dumps the kernel neighbor cache for the given address family
+// (syscall.AF_INET or syscall.AF_INET6) over rtnetlink. Incomplete and failed
+// entries are skipped. IPv4 falls back to /proc/net/arp.
+func readNeighbors(family int) ([]neighbor, error) {
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, family)
+	if err != nil {
+		if family == syscall.AF_INET {
+			if neighbors, arpErr := readARPTable(); arpErr == nil {
+				return neighbors, nil
+			}
+		}
+		return nil, fmt.Errorf("failed to dump neighbor table: %w", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse neighbor table: %w", err)
+	}
+
+	names := make(map[int]string)
+	if ifaces, err := net.Interfaces(); err == nil {
+		for _, iface := range ifaces {
+			names[iface.Index] = iface.Name
+		}
+	}
+
+	var neighbors []neighbor
+	for _, m := range msgs {
+		if m.Header.Type == syscall.NLMSG_DONE {
+			break
+		}
+		if m.Header.Type != syscall.RTM_NEWNEIGH || len(m.Data) < ndmsgLen {
+			continue
+		}
+		if int(m.Data[0]) != family {
+			continue
+		}
+		ifindex := int(int32(binary.NativeEndian.Uint32(m.Data[4:8])))
+		state := binary.NativeEndian.Uint16(m.Data[8:10])
+		if state&(nudIncomplete|nudFailed|nudNoARP) != 0 {
+			continue
+		}
+
+		n := neighbor{
+			Interface: names[ifindex],
+			Reachable: state&(nudReachable|nudPermanent) != 0,
+		}
+		for b := m.Data[ndmsgLen:]; len(b) >= syscall.SizeofRtAttr; {
+			l := int(binary.NativeEndian.Uint16(b[0:2]))
+			typ := binary.NativeEndian.Uint16(b[2:4])
+			if l < syscall.SizeofRtAttr || l > len(b) {
+				break
+			}
+			value := b[syscall.SizeofRtAttr:l]
+			switch typ {
+			case ndaDst:
+				n.IP = net.IP(append([]byte(nil), value...))
+			case ndaLLAddr:
+				n.MAC = net.HardwareAddr(append([]byte(nil), value...))
+			}
+			// Attributes are padded to 4 bytes
+			l = (l + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+			if l > len(b) {
+				break
+			}
+			b = b[l:]
+		}
+
+		if n.IP != nil {
+			neighbors = append(neighbors, n)
+		}
+	}
+
+	return neighbors, nil
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package probes
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"time"
+)
+
+// readNeighbors is only implemented on Linux.
+func readNeighbors(family int) ([]neighbor, error) {
+	return nil, fmt.Errorf("neighbor table not supported on %s", runtime.GOOS)
+}
+
+// arpScan is only implemented on Linux.
+func arpScan(ctx context.Context, subnet *net.IPNet, ips []string, timeout time.Duration) ([]neighbor, error) {
+	return nil, fmt.Errorf("active ARP not supported on %s", runtime.GOOS)
+}
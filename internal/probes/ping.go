@@ -4,16 +4,28 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/user/netpulse/internal/model"
 )
 
+// Host discovery methods.
+const (
+	SweepMethodICMP = "icmp" // ICMP echo only
+	SweepMethodTCP  = "tcp"  // TCP connect to common ports only
+	SweepMethodBoth = "both" // ICMP echo, then TCP for hosts that ignore it
+)
+
 // PingProbe handles ping sweep operations.
 type PingProbe struct {
 	concurrency int
 	timeout     time.Duration
+	method      string
+	activeARP   bool
+	resolvers   []string
 }
 
 // NewPingProbe creates a new ping probe.
@@ -27,16 +39,57 @@ func NewPingProbe(concurrency int, timeout time.Duration) *PingProbe {
 	return &PingProbe{
 		concurrency: concurrency,
 		timeout:     timeout,
+		method:      SweepMethodBoth,
+		resolvers:   DefaultNameResolvers(),
+	}
+}
+
+// SetActiveARP enables broadcasting ARP requests during IPv4 sweeps (Linux
+// only, needs CAP_NET_RAW). Without it only the kernel's ARP cache is read.
+func (p *PingProbe) SetActiveARP(enabled bool) {
+	p.activeARP = enabled
+}
+
+// SetNameResolvers sets the resolvers used to name alive hosts, highest
+// priority first. Unknown names are ignored; an empty list disables naming.
+func (p *PingProbe) SetNameResolvers(resolvers []string) {
+	p.resolvers = p.resolvers[:0:0]
+	for _, r := range resolvers {
+		switch r {
+		case ResolverDNS, ResolverNetBIOS, ResolverLLMNR:
+			p.resolvers = append(p.resolvers, r)
+		}
+	}
+}
+
+// SetMethod selects the discovery method ("icmp", "tcp" or "both").
+func (p *PingProbe) SetMethod(method string) {
+	if method == SweepMethodICMP || method == SweepMethodTCP || method == SweepMethodBoth {
+		p.method = method
 	}
 }
 
-// SweepSubnet performs a ping sweep on the given CIDR subnet.
+// SweepSubnet performs a ping sweep on the given CIDR subnet. IPv6 prefixes
+// are discovered through the neighbor cache instead of being enumerated.
 func (p *PingProbe) SweepSubnet(ctx context.Context, cidr string) ([]model.ScanHost, error) {
+	if _, ipnet, err := net.ParseCIDR(cidr); err == nil && ipnet.IP.To4() == nil {
+		return p.discoverIPv6(ctx, ipnet)
+	}
+	
 	ips, err := expandCIDR(cidr)
 	if err != nil {
 		return nil, fmt.Errorf("invalid CIDR: %w", err)
 	}
 	
+	// An ICMP-only sweep cannot find anything without an ICMP socket
+	if p.method == SweepMethodICMP {
+		c, err := listenICMP(false)
+		if err != nil {
+			return nil, err
+		}
+		c.Close()
+	}
+	
 	// Worker pool
 	jobs := make(chan string, len(ips))
 	results := make(chan model.ScanHost, len(ips))
@@ -82,10 +135,23 @@ func (p *PingProbe) SweepSubnet(ctx context.Context, cidr string) ([]model.ScanH
 		hosts = append(hosts, host)
 	}
 	
+	// Add MAC addresses, and hosts that only answer ARP, from the link layer
+	if _, subnet, err := net.ParseCIDR(cidr); err == nil {
+		if p.activeARP {
+			if replies, err := arpScan(ctx, subnet, ips, p.timeout); err == nil {
+				applyNeighbors(hosts, replies, DetectedByARP)
+			}
+		}
+		if neighbors, err := readNeighbors(syscall.AF_INET); err == nil {
+			applyNeighbors(hosts, neighbors, DetectedByARP)
+		}
+	}
+	
 	return hosts, nil
 }
 
-// pingHost pings a single host using TCP SYN (connect) method.
+// pingHost checks a single host with an ICMP echo and/or a TCP connect to
+// common ports, depending on the configured method.
 func (p *PingProbe) pingHost(ctx context.Context, ip string) model.ScanHost {
 	host := model.ScanHost{
 		IP:       ip,
@@ -93,18 +159,44 @@ func (p *PingProbe) pingHost(ctx context.Context, ip string) model.ScanHost {
 		Alive:    false,
 	}
 	
+	if p.method != SweepMethodTCP {
+		if addr := net.ParseIP(ip); addr != nil {
+			if rtt, err := icmpEcho(ctx, addr, p.timeout); err == nil {
+				host.Alive = true
+				host.LatencyMs = float64(rtt.Microseconds()) / 1000.0
+				host.DetectedBy = SweepMethodICMP
+			}
+		}
+	}
+	
+	if !host.Alive && p.method != SweepMethodICMP {
+		p.tcpPing(ip, &host)
+	}
+	
+	// Name alive hosts through reverse DNS, NetBIOS and LLMNR
+	if host.Alive {
+		p.resolveNames(ctx, &host)
+	}
+	
+	return host
+}
+
+// tcpPing marks the host alive if one of the common ports accepts or refuses
+// a TCP connection.
+func (p *PingProbe) tcpPing(ip string, host *model.ScanHost) {
 	// Try common ports for TCP ping
 	ports := []int{80, 443, 22, 21, 445, 139}
 	
 	for _, port := range ports {
 		start := time.Now()
-		conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", ip, port), p.timeout)
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(port)), p.timeout)
 		latency := float64(time.Since(start).Microseconds()) / 1000.0
 		
 		if err == nil {
 			conn.Close()
 			host.Alive = true
 			host.LatencyMs = latency
+			host.DetectedBy = SweepMethodTCP
 			break
 		}
 		
@@ -112,18 +204,10 @@ func (p *PingProbe) pingHost(ctx context.Context, ip string) model.ScanHost {
 		if isConnectionRefused(err) {
 			host.Alive = true
 			host.LatencyMs = latency
+			host.DetectedBy = SweepMethodTCP
 			break
 		}
 	}
-	
-	// Attempt reverse DNS lookup for alive hosts
-	if host.Alive {
-		if names, err := net.LookupAddr(ip); err == nil && len(names) > 0 {
-			host.Hostname = names[0]
-		}
-	}
-	
-	return host
 }
 
 // PingHosts pings a list of specific hosts.
@@ -148,12 +232,17 @@ func (p *PingProbe) PingHosts(ctx context.Context, ips []string) []model.ScanHos
 	return results
 }
 
-// expandCIDR expands a CIDR to a list of IP addresses.
+// expandCIDR expands an IPv4 CIDR to a list of IP addresses. IPv6 prefixes
+// are rejected since even a /64 cannot be enumerated.
 func expandCIDR(cidr string) ([]string, error) {
 	ip, ipnet, err := net.ParseCIDR(cidr)
 	if err != nil {
 		return nil, err
 	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("cannot enumerate IPv6 prefix %s", cidr)
+	}
+	ip = ip.To4()
 	
 	var ips []string
 	for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); incIP(ip) {
@@ -0,0 +1,286 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// Smallest MTUs every IPv4 (RFC 791) and IPv6 (RFC 8200) path must carry.
+const (
+	minMTU4 = 68
+	minMTU6 = 1280
+)
+
+// maxPacket is the largest IP packet a probe can describe.
+const maxPacket = 65535
+
+// pmtuOutcome is what happened to one probe size.
+type pmtuOutcome int
+
+const (
+	pmtuReply  pmtuOutcome = iota // Echo reply received
+	pmtuTooBig                    // "Fragmentation needed" / "packet too big"
+	pmtuLocal                     // Refused by the local stack (EMSGSIZE)
+	pmtuSilent                    // No answer at all
+)
+
+// PMTUProbe discovers the path MTU to a target with ICMP echo requests that
+// may not be fragmented.
+type PMTUProbe struct {
+	timeout  time.Duration
+	attempts int
+}
+
+// NewPMTUProbe creates a new path MTU probe.
+func NewPMTUProbe() *PMTUProbe {
+	return &PMTUProbe{
+		timeout:  2 * time.Second,
+		attempts: 2,
+	}
+}
+
+// SetTimeout sets how long to wait for the answer to each probe.
+func (p *PMTUProbe) SetTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		p.timeout = timeout
+	}
+}
+
+// pmtuSearch holds the state of one discovery run.
+type pmtuSearch struct {
+	conn    net.PacketConn
+	dst     *net.IPAddr
+	v6      bool
+	timeout time.Duration
+	id      int
+}
+
+// Discover binary-searches the largest packet (IP total length) that reaches
+// the target. It starts at the MTU of the outgoing interface, follows the MTU
+// reported by routers in ICMP errors, and flags a black hole when larger
+// packets vanish without any error. Raw ICMP sockets are required to receive
+// those errors.
+func (p *PMTUProbe) Discover(ctx context.Context, target string) *model.PMTUResult {
+	result := &model.PMTUResult{
+		Target:    target,
+		Timestamp: time.Now(),
+	}
+	if err := p.discover(ctx, target, result); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Success = true
+	return result
+}
+
+func (p *PMTUProbe) discover(ctx context.Context, target string, result *model.PMTUResult) error {
+	dst, err := net.ResolveIPAddr("ip", target)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", target, err)
+	}
+	v6 := dst.IP.To4() == nil
+
+	network, laddr, minSize := "ip4:icmp", "0.0.0.0", minMTU4
+	if v6 {
+		network, laddr, minSize = "ip6:ipv6-icmp", "::", minMTU6
+	}
+	conn, err := net.ListenPacket(network, laddr)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRawSocketUnavailable, err)
+	}
+	defer conn.Close()
+	if err := setDontFragment(conn, v6); err != nil {
+		return fmt.Errorf("failed to set don't-fragment: %w", err)
+	}
+
+	result.LocalMTU = routeMTU(dst.IP)
+	s := &pmtuSearch{
+		conn:    conn,
+		dst:     dst,
+		v6:      v6,
+		timeout: p.timeout,
+		id:      rand.Intn(0xffff),
+	}
+
+	// The path MTU lies in [lo, hi]. The interface MTU is tried first since
+	// most paths carry it.
+	lo, hi := minSize, result.LocalMTU
+	size := hi
+	var bound pmtuOutcome
+	for lo <= hi {
+		outcome, mtu, hop, err := s.probeSize(ctx, size, p.attempts)
+		if err != nil {
+			return err
+		}
+
+		switch outcome {
+		case pmtuReply:
+			result.PMTU = size
+			lo = size + 1
+		case pmtuTooBig:
+			result.ReportedBy, result.ReportedMTU = hop, mtu
+			hi, bound = size-1, outcome
+			// Try the reported MTU next; routers may report 0 (RFC 1191)
+			if mtu >= lo && mtu < size {
+				hi, size = mtu, mtu
+				continue
+			}
+		default:
+			hi, bound = size-1, outcome
+		}
+		size = (lo + hi + 1) / 2
+	}
+
+	if result.PMTU == 0 {
+		return fmt.Errorf("no echo replies from %s", target)
+	}
+	result.BlackHole = result.PMTU < result.LocalMTU && bound == pmtuSilent
+	return nil
+}
+
+// probeSize sends up to attempts echo requests of size bytes and reports the
+// first conclusive outcome, with the MTU and router of an ICMP error.
+func (s *pmtuSearch) probeSize(ctx context.Context, size, attempts int) (pmtuOutcome, int, string, error) {
+	header := 20 + 8
+	if s.v6 {
+		header = 40 + 8
+	}
+	payload := make([]byte, size-header)
+
+	for i := 0; i < attempts; i++ {
+		if ctx.Err() != nil {
+			return 0, 0, "", ctx.Err()
+		}
+
+		seq := rand.Intn(0xffff)
+		var msg []byte
+		if s.v6 {
+			msg = marshalEcho6(s.id, seq, payload)
+		} else {
+			msg = marshalEcho(s.id, seq, payload)
+		}
+
+		// A previous "fragmentation needed" leaves a pending socket error
+		// that would fail the next send
+		clearSocketError(s.conn)
+		if _, err := s.conn.WriteTo(msg, s.dst); err != nil {
+			if errors.Is(err, syscall.EMSGSIZE) {
+				return pmtuLocal, 0, "", nil
+			}
+			return 0, 0, "", fmt.Errorf("failed to send probe: %w", err)
+		}
+
+		outcome, mtu, hop, err := s.await(ctx, seq)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		if outcome != pmtuSilent {
+			return outcome, mtu, hop, nil
+		}
+	}
+	return pmtuSilent, 0, "", nil
+}
+
+// await reads until the reply or an ICMP error for the probe arrives, or the
+// timeout expires.
+func (s *pmtuSearch) await(ctx context.Context, seq int) (pmtuOutcome, int, string, error) {
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	s.conn.SetReadDeadline(deadline)
+
+	buf := make([]byte, maxPacket+1)
+	for {
+		n, from, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return pmtuSilent, 0, "", nil
+			}
+			if errors.Is(err, syscall.EMSGSIZE) {
+				continue
+			}
+			return 0, 0, "", err
+		}
+
+		var msg *icmpMessage
+		if s.v6 {
+			msg, err = parseICMPv6(buf[:n])
+		} else {
+			msg, err = parseICMPv4(buf[:n])
+		}
+		if err != nil {
+			continue
+		}
+
+		if msg.isEchoReply() {
+			if msg.ID == s.id && msg.Seq == seq && addrIP(from).Equal(s.dst.IP) {
+				return pmtuReply, 0, "", nil
+			}
+			continue
+		}
+
+		q := msg.Quoted
+		if q == nil || q.ID != s.id || q.Seq != seq || !q.Dst.Equal(s.dst.IP) {
+			continue
+		}
+		if q.Protocol != protoICMP && q.Protocol != protoICMPv6 {
+			continue
+		}
+		hop := addrIP(from).String()
+		if s.tooBig(msg) {
+			return pmtuTooBig, msg.MTU, hop, nil
+		}
+		if msg.isDestUnreachable() {
+			return 0, 0, "", fmt.Errorf("%s unreachable (reported by %s)", s.dst.IP, hop)
+		}
+	}
+}
+
+// tooBig reports whether msg says the probe exceeded a link MTU.
+func (s *pmtuSearch) tooBig(msg *icmpMessage) bool {
+	if msg.V6 {
+		return msg.Type == icmp6PacketTooBig
+	}
+	return msg.Type == icmpDestUnreachable && msg.Code == icmpCodeFragNeeded
+}
+
+// routeMTU returns the MTU of the interface that routes to ip, or 1500 when
+// it cannot be determined.
+func routeMTU(ip net.IP) int {
+	mtu := 1500
+	conn, err := net.Dial("udp", net.JoinHostPort(ip.String(), "9"))
+	if err != nil {
+		return mtu
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return mtu
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(local) && iface.MTU > 0 {
+				mtu = iface.MTU
+			}
+		}
+	}
+	if mtu > maxPacket {
+		mtu = maxPacket
+	}
+	return mtu
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package probes
+
+import (
+	"errors"
+	"net"
+)
+
+// setDontFragment is only implemented on Linux, where the path MTU cache
+// can be bypassed per socket.
+func setDontFragment(conn net.PacketConn, v6 bool) error {
+	return errors.New("path MTU discovery is not supported on this platform")
+}
+
+// clearSocketError is a no-op where setDontFragment is unsupported.
+func clearSocketError(conn net.PacketConn) {}
@@ -2,8 +2,8 @@ package probes
 
 import (
 	"context"
-	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -15,6 +15,8 @@ type PortScanner struct {
 	concurrency int
 	timeout     time.Duration
 	ports       []int
+	udpPorts    []int
+	detector    *ServiceDetector
 }
 
 // NewPortScanner creates a new port scanner.
@@ -32,6 +34,7 @@ func NewPortScanner(concurrency int, timeout time.Duration, ports []int) *PortSc
 		concurrency: concurrency,
 		timeout:     timeout,
 		ports:       ports,
+		detector:    NewServiceDetector(timeout),
 	}
 }
 
@@ -59,28 +62,46 @@ var serviceNames = map[int]string{
 
 // ScanResult represents the result of scanning a single port.
 type ScanResult struct {
-	Port    int
-	State   string
-	Service string
-	Banner  string
+	Port     int
+	Protocol string
+	State    string
+	Service  string
+	Banner   string
+	Product  string
+	Version  string
+	Info     string
+	TLS      bool
 }
 
-// ScanHost scans a single host for open ports.
+// portJob is a single port to probe.
+type portJob struct {
+	port     int
+	protocol string
+}
+
+// ScanHost scans a single host for open TCP ports and responsive UDP ports.
+// UDP ports that refused the probe are included as closed.
 func (s *PortScanner) ScanHost(ctx context.Context, host string) ([]model.ScanPort, error) {
-	jobs := make(chan int, len(s.ports))
-	results := make(chan *ScanResult, len(s.ports))
+	total := len(s.ports) + len(s.udpPorts)
+	jobs := make(chan portJob, total)
+	results := make(chan *ScanResult, total)
 	
 	var wg sync.WaitGroup
 	for i := 0; i < s.concurrency; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for port := range jobs {
+			for job := range jobs {
 				select {
 				case <-ctx.Done():
 					return
 				default:
-					result := s.scanPort(ctx, host, port)
+					var result *ScanResult
+					if job.protocol == "udp" {
+						result = s.scanUDPPort(ctx, host, job.port)
+					} else {
+						result = s.scanPort(ctx, host, job.port)
+					}
 					if result != nil {
 						results <- result
 					}
@@ -91,14 +112,21 @@ func (s *PortScanner) ScanHost(ctx context.Context, host string) ([]model.ScanPo
 	
 	// Send jobs
 	go func() {
+		defer close(jobs)
+		queue := make([]portJob, 0, total)
 		for _, port := range s.ports {
+			queue = append(queue, portJob{port, "tcp"})
+		}
+		for _, port := range s.udpPorts {
+			queue = append(queue, portJob{port, "udp"})
+		}
+		for _, job := range queue {
 			select {
-			case jobs <- port:
+			case jobs <- job:
 			case <-ctx.Done():
-				break
+				return
 			}
 		}
-		close(jobs)
 	}()
 	
 	// Wait and close results
@@ -112,10 +140,14 @@ func (s *PortScanner) ScanHost(ctx context.Context, host string) ([]model.ScanPo
 	for result := range results {
 		ports = append(ports, model.ScanPort{
 			Port:     result.Port,
-			Protocol: "tcp",
+			Protocol: result.Protocol,
 			Service:  result.Service,
 			State:    result.State,
 			Banner:   result.Banner,
+			Product:  result.Product,
+			Version:  result.Version,
+			Info:     result.Info,
+			TLS:      result.TLS,
 			LastSeen: time.Now(),
 		})
 	}
@@ -124,24 +156,28 @@ func (s *PortScanner) ScanHost(ctx context.Context, host string) ([]model.ScanPo
 }
 
 func (s *PortScanner) scanPort(ctx context.Context, host string, port int) *ScanResult {
-	addr := fmt.Sprintf("%s:%d", host, port)
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
 	
 	conn, err := net.DialTimeout("tcp", addr, s.timeout)
 	if err != nil {
 		return nil // Port closed or filtered
 	}
-	defer conn.Close()
+	conn.Close()
 	
-	result := &ScanResult{
-		Port:    port,
-		State:   "open",
-		Service: getServiceName(port),
-	}
-	
-	// Try to grab banner
-	result.Banner = grabBanner(conn, s.timeout/2)
+	// Identify the service with protocol probes
+	info := s.detector.Detect(ctx, host, port)
 	
-	return result
+	return &ScanResult{
+		Port:     port,
+		Protocol: "tcp",
+		State:    PortOpen,
+		Service:  info.Service,
+		Banner:   info.Banner,
+		Product:  info.Product,
+		Version:  info.Version,
+		Info:     info.Info,
+		TLS:      info.TLS,
+	}
 }
 
 func getServiceName(port int) string {
@@ -151,24 +187,6 @@ func getServiceName(port int) string {
 	return "unknown"
 }
 
-func grabBanner(conn net.Conn, timeout time.Duration) string {
-	conn.SetReadDeadline(time.Now().Add(timeout))
-	
-	buf := make([]byte, 1024)
-	n, err := conn.Read(buf)
-	if err != nil || n == 0 {
-		return ""
-	}
-	
-	// Clean up banner
-	banner := string(buf[:n])
-	if len(banner) > 200 {
-		banner = banner[:200]
-	}
-	
-	return banner
-}
-
 // ScanMultipleHosts scans multiple hosts for open ports.
 func (s *PortScanner) ScanMultipleHosts(ctx context.Context, hosts []string) (map[string][]model.ScanPort, error) {
 	results := make(map[string][]model.ScanPort)
@@ -203,3 +221,8 @@ func (s *PortScanner) SetPorts(ports []int) {
 		s.ports = ports
 	}
 }
+
+// SetUDPPorts sets the UDP ports to scan. An empty list disables UDP scanning.
+func (s *PortScanner) SetUDPPorts(ports []int) {
+	s.udpPorts = ports
+}
@@ -0,0 +1,409 @@
+package probes
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServiceInfo is what active fingerprinting learned about a TCP service.
+type ServiceInfo struct {
+	Service string
+	Product string
+	Version string
+	Info    string // Extra detail such as page title or certificate subject
+	TLS     bool
+	Banner  string
+}
+
+// ServiceDetector identifies TCP services by sending protocol probes.
+type ServiceDetector struct {
+	timeout time.Duration
+}
+
+// NewServiceDetector creates a service detector.
+func NewServiceDetector(timeout time.Duration) *ServiceDetector {
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	return &ServiceDetector{timeout: timeout}
+}
+
+// tlsPorts are tried with a TLS ClientHello before plain-text probes.
+var tlsPorts = map[int]bool{
+	443: true, 465: true, 636: true, 993: true, 995: true, 2376: true,
+	4443: true, 6443: true, 7443: true, 8443: true, 9443: true, 10250: true, 10443: true,
+}
+
+var (
+	htmlTitle     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	redisVersion  = regexp.MustCompile(`redis_version:([^\r\n]+)`)
+	bannerProduct = regexp.MustCompile(`(?i)\b(vsFTPd|ProFTPD|Pure-FTPd|FileZilla Server|Postfix|Exim|Sendmail|Dovecot|Courier|OpenSMTPD|Microsoft ESMTP)[ /]?v?([0-9][\w.-]*)?`)
+)
+
+// Detect fingerprints the service on an open TCP port. Services that talk
+// first are matched on their greeting; silent ones get HTTP, TLS and Redis
+// probes. When nothing matches, the service name is guessed from the port.
+func (d *ServiceDetector) Detect(ctx context.Context, host string, port int) *ServiceInfo {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+
+	if banner, ok := d.greeting(ctx, addr); ok {
+		info := matchGreeting(banner)
+		if info == nil {
+			info = &ServiceInfo{Service: getServiceName(port)}
+		}
+		info.Banner = printable(banner, 200)
+		return info
+	}
+
+	probes := []func(context.Context, string, string) *ServiceInfo{d.probeHTTP, d.probeTLS, d.probeRedis}
+	switch {
+	case tlsPorts[port]:
+		probes = []func(context.Context, string, string) *ServiceInfo{d.probeTLS, d.probeHTTP, d.probeRedis}
+	case getServiceName(port) == "redis":
+		probes = []func(context.Context, string, string) *ServiceInfo{d.probeRedis, d.probeHTTP, d.probeTLS}
+	}
+
+	for _, probe := range probes {
+		if ctx.Err() != nil {
+			break
+		}
+		if info := probe(ctx, addr, host); info != nil {
+			// TLS without HTTP inside, e.g. IMAPS: keep the port's name
+			if info.Service == "tls" && getServiceName(port) != "unknown" {
+				info.Service = getServiceName(port)
+			}
+			return info
+		}
+	}
+
+	return &ServiceInfo{Service: getServiceName(port)}
+}
+
+// dial opens a TCP connection with the detector's timeout as deadline.
+func (d *ServiceDetector) dial(ctx context.Context, addr string) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: d.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(d.timeout))
+	return conn, nil
+}
+
+// greeting waits briefly for the server to speak first.
+func (d *ServiceDetector) greeting(ctx context.Context, addr string) ([]byte, bool) {
+	conn, err := d.dial(ctx, addr)
+	if err != nil {
+		return nil, false
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(d.timeout / 2))
+	buf := make([]byte, 1024)
+	n, _ := conn.Read(buf)
+	if n == 0 {
+		return nil, false
+	}
+	return buf[:n], true
+}
+
+// matchGreeting identifies services that send a banner on connect.
+func matchGreeting(b []byte) *ServiceInfo {
+	if info := parseMySQLGreeting(b); info != nil {
+		return info
+	}
+
+	line := strings.TrimSpace(strings.SplitN(string(b), "\n", 2)[0])
+	switch {
+	case strings.HasPrefix(line, "SSH-"):
+		return parseSSHVersion(line)
+	case strings.HasPrefix(line, "RFB "):
+		return &ServiceInfo{Service: "vnc", Product: "RFB", Version: strings.TrimPrefix(line, "RFB ")}
+	case strings.HasPrefix(line, "+OK"):
+		return withProduct(&ServiceInfo{Service: "pop3", Info: line}, line)
+	case strings.HasPrefix(line, "* OK"):
+		return withProduct(&ServiceInfo{Service: "imap", Info: line}, line)
+	case strings.HasPrefix(line, "220"):
+		service := "ftp"
+		if strings.Contains(strings.ToUpper(line), "SMTP") {
+			service = "smtp"
+		}
+		return withProduct(&ServiceInfo{Service: service, Info: line}, line)
+	}
+	return nil
+}
+
+// withProduct fills product and version from well-known servers named in a
+// greeting line.
+func withProduct(info *ServiceInfo, line string) *ServiceInfo {
+	if m := bannerProduct.FindStringSubmatch(line); m != nil {
+		info.Product = m[1]
+		info.Version = m[2]
+	}
+	return info
+}
+
+// parseSSHVersion parses an RFC 4253 identification string such as
+// "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6".
+func parseSSHVersion(line string) *ServiceInfo {
+	info := &ServiceInfo{Service: "ssh"}
+
+	parts := strings.SplitN(line, "-", 3)
+	if len(parts) < 3 {
+		return info
+	}
+	software, comment, _ := strings.Cut(parts[2], " ")
+	info.Info = strings.TrimSpace("protocol " + parts[1] + " " + comment)
+
+	if i := strings.LastIndex(software, "_"); i > 0 {
+		info.Product = strings.ReplaceAll(software[:i], "_", " ")
+		info.Version = software[i+1:]
+	} else {
+		info.Product = software
+	}
+	return info
+}
+
+// parseMySQLGreeting parses a MySQL/MariaDB initial handshake packet, or the
+// error packet sent to hosts that are not allowed to connect.
+func parseMySQLGreeting(b []byte) *ServiceInfo {
+	if len(b) < 6 || b[3] != 0 {
+		return nil
+	}
+	length := int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+	if length+4 > len(b) || length < 2 {
+		return nil
+	}
+	payload := b[4 : 4+length]
+
+	switch payload[0] {
+	case 0x0a: // Protocol version 10
+		end := strings.IndexByte(string(payload[1:]), 0)
+		if end <= 0 {
+			return nil
+		}
+		full := string(payload[1 : 1+end])
+		info := &ServiceInfo{Service: "mysql", Product: "MySQL", Info: full}
+		version := full
+		if strings.Contains(full, "MariaDB") {
+			info.Product = "MariaDB"
+			version = strings.TrimPrefix(version, "5.5.5-")
+		}
+		info.Version, _, _ = strings.Cut(version, "-")
+		return info
+	case 0xff: // ERR packet
+		if len(payload) < 3 {
+			return nil
+		}
+		code := binary.LittleEndian.Uint16(payload[1:3])
+		return &ServiceInfo{
+			Service: "mysql",
+			Product: "MySQL",
+			Info:    fmt.Sprintf("error %d: %s", code, printable(payload[3:], 200)),
+		}
+	}
+	return nil
+}
+
+// probeHTTP sends HEAD / and, if the server speaks HTTP, GET / for the title.
+func (d *ServiceDetector) probeHTTP(ctx context.Context, addr, host string) *ServiceInfo {
+	status, headers, ok := d.httpRequest(ctx, addr, host, "HEAD", false)
+	if !ok {
+		return nil
+	}
+	// Many HTTPS servers answer plain HTTP with 400 Bad Request
+	if strings.Contains(status, " 400 ") {
+		if info := d.probeTLS(ctx, addr, host); info != nil {
+			return info
+		}
+	}
+
+	info := &ServiceInfo{Service: "http", Banner: status}
+	info.Product, info.Version = splitProduct(headers["server"])
+	info.Info = d.httpTitle(ctx, addr, host, false)
+	return info
+}
+
+// probeTLS performs a TLS handshake and records the certificate subject and
+// negotiated ALPN protocol. HTTP is then tried inside TLS.
+func (d *ServiceDetector) probeTLS(ctx context.Context, addr, host string) *ServiceInfo {
+	conn, err := d.dial(ctx, addr)
+	if err != nil {
+		return nil
+	}
+	tlsConn := tls.Client(conn, tlsProbeConfig(host, "h2", "http/1.1"))
+	defer tlsConn.Close()
+
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil
+	}
+	state := tlsConn.ConnectionState()
+
+	info := &ServiceInfo{Service: "tls", TLS: true}
+	var details []string
+	if len(state.PeerCertificates) > 0 {
+		details = append(details, "subject: "+state.PeerCertificates[0].Subject.String())
+	}
+	if state.NegotiatedProtocol != "" {
+		details = append(details, "alpn: "+state.NegotiatedProtocol)
+	}
+
+	if status, headers, ok := d.httpRequest(ctx, addr, host, "HEAD", true); ok {
+		info.Service = "https"
+		info.Banner = status
+		info.Product, info.Version = splitProduct(headers["server"])
+		if title := d.httpTitle(ctx, addr, host, true); title != "" {
+			details = append([]string{title}, details...)
+		}
+	}
+	info.Info = strings.Join(details, "; ")
+	return info
+}
+
+// probeRedis sends PING and, when allowed, INFO server for the version.
+func (d *ServiceDetector) probeRedis(ctx context.Context, addr, host string) *ServiceInfo {
+	conn, err := d.dial(ctx, addr)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		return nil
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil
+	}
+	line = strings.TrimSpace(line)
+
+	info := &ServiceInfo{Service: "redis", Product: "Redis", Banner: line}
+	switch {
+	case line == "+PONG":
+	case strings.HasPrefix(line, "-NOAUTH"), strings.HasPrefix(line, "-DENIED"):
+		info.Info = "authentication required"
+		return info
+	default:
+		return nil
+	}
+
+	if _, err := conn.Write([]byte("INFO server\r\n")); err != nil {
+		return info
+	}
+	reply := make([]byte, 4096)
+	n, _ := io.ReadAtLeast(r, reply, 1)
+	if m := redisVersion.FindSubmatch(reply[:n]); m != nil {
+		info.Version = strings.TrimSpace(string(m[1]))
+	}
+	return info
+}
+
+// httpRequest sends a bodiless request and returns the status line and headers.
+func (d *ServiceDetector) httpRequest(ctx context.Context, addr, host, method string, useTLS bool) (string, map[string]string, bool) {
+	conn, err := d.httpConn(ctx, addr, host, useTLS)
+	if err != nil {
+		return "", nil, false
+	}
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, httpRequestLine(method, addr)); err != nil {
+		return "", nil, false
+	}
+
+	r := bufio.NewReader(conn)
+	status, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(status, "HTTP/") {
+		return "", nil, false
+	}
+
+	headers := make(map[string]string) // Lower-cased names
+	for {
+		line, err := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if err != nil || line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			headers[strings.ToLower(name)] = strings.TrimSpace(value)
+		}
+	}
+	return strings.TrimSpace(status), headers, true
+}
+
+// httpTitle fetches / and extracts the HTML title.
+func (d *ServiceDetector) httpTitle(ctx context.Context, addr, host string, useTLS bool) string {
+	conn, err := d.httpConn(ctx, addr, host, useTLS)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, httpRequestLine("GET", addr)); err != nil {
+		return ""
+	}
+	body, _ := io.ReadAll(io.LimitReader(conn, 32*1024))
+	m := htmlTitle.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+	title := strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+	if len(title) > 100 {
+		title = title[:100]
+	}
+	return title
+}
+
+// httpConn dials addr, wrapping the connection in TLS if asked. Only
+// HTTP/1.1 is offered so the server answers plain HTTP requests.
+func (d *ServiceDetector) httpConn(ctx context.Context, addr, host string, useTLS bool) (net.Conn, error) {
+	conn, err := d.dial(ctx, addr)
+	if err != nil || !useTLS {
+		return conn, err
+	}
+	tlsConn := tls.Client(conn, tlsProbeConfig(host, "http/1.1"))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func httpRequestLine(method, addr string) string {
+	return method + " / HTTP/1.0\r\n" +
+		"Host: " + addr + "\r\n" +
+		"User-Agent: netpulse\r\n" +
+		"Accept: */*\r\n" +
+		"Connection: close\r\n\r\n"
+}
+
+// tlsProbeConfig returns a client config that accepts any certificate, since
+// the point is to look at it rather than trust it.
+func tlsProbeConfig(host string, alpn ...string) *tls.Config {
+	cfg := &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         alpn,
+	}
+	if net.ParseIP(host) == nil {
+		cfg.ServerName = host
+	}
+	return cfg
+}
+
+// splitProduct splits a Server header such as "nginx/1.24.0 (Ubuntu)" into
+// product and version.
+func splitProduct(server string) (string, string) {
+	server, _, _ = strings.Cut(server, " ")
+	product, version, _ := strings.Cut(server, "/")
+	return product, version
+}
@@ -0,0 +1,168 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// Latency stream methods.
+const (
+	StreamMethodICMP = "icmp"
+	StreamMethodTCP  = "tcp"
+)
+
+// LatencySample is the outcome of one ping in a latency stream.
+type LatencySample struct {
+	Time  time.Time
+	RTTMs float64
+	Lost  bool
+}
+
+// LatencyStream sends a steady stream of pings to one target, like mtr does
+// for the final hop.
+type LatencyStream struct {
+	target   string
+	method   string
+	port     int
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// NewLatencyStream creates a stream pinging target once per interval. ICMP
+// streams fall back to TCP connects when ICMP sockets are unavailable.
+func NewLatencyStream(target, method string, port int, interval time.Duration) *LatencyStream {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if port <= 0 {
+		port = 443
+	}
+	if method != StreamMethodTCP {
+		method = StreamMethodICMP
+	}
+	// Replies slower than one interval, or 2s on fast streams, count as lost
+	timeout := interval
+	if timeout > 2*time.Second {
+		timeout = 2 * time.Second
+	}
+	return &LatencyStream{
+		target:   target,
+		method:   method,
+		port:     port,
+		interval: interval,
+		timeout:  timeout,
+	}
+}
+
+// Method returns the method the stream ended up using.
+func (s *LatencyStream) Method() string {
+	return s.method
+}
+
+// Run pings the target until ctx is cancelled and passes every sample to
+// report. Samples are reported from the calling goroutine.
+func (s *LatencyStream) Run(ctx context.Context, report func(LatencySample)) error {
+	addr, err := net.ResolveIPAddr("ip", s.target)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", s.target, err)
+	}
+
+	var icmp *icmpConn
+	if s.method == StreamMethodICMP {
+		icmp, err = listenICMP(addr.IP.To4() == nil)
+		if err != nil {
+			s.method = StreamMethodTCP
+		} else {
+			defer icmp.Close()
+		}
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		sample := LatencySample{Time: time.Now()}
+		var rtt time.Duration
+		if icmp != nil {
+			rtt, err = icmp.echo(ctx, addr.IP, s.timeout)
+		} else {
+			rtt, err = s.tcpConnect(ctx, addr.IP)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			sample.Lost = true
+		} else {
+			sample.RTTMs = float64(rtt.Microseconds()) / 1000.0
+		}
+		report(sample)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// tcpConnect times a TCP handshake. A refused connection still proves the
+// target answered, so it counts as a reply.
+func (s *LatencyStream) tcpConnect(ctx context.Context, ip net.IP) (time.Duration, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), strconv.Itoa(s.port)))
+	rtt := time.Since(start)
+	if err != nil {
+		if isConnectionRefused(err) {
+			return rtt, nil
+		}
+		return 0, err
+	}
+	conn.Close()
+	return rtt, nil
+}
+
+// SummarizeSamples aggregates stream samples into loss and latency
+// statistics. Jitter is the standard deviation of the RTTs.
+func SummarizeSamples(samples []LatencySample) model.LossStats {
+	stats := model.LossStats{Sent: len(samples)}
+
+	var rtts []float64
+	for _, s := range samples {
+		if !s.Lost {
+			rtts = append(rtts, s.RTTMs)
+		}
+	}
+	stats.Received = len(rtts)
+	if stats.Sent > 0 {
+		stats.LossPct = float64(stats.Sent-stats.Received) / float64(stats.Sent) * 100
+	}
+	if len(rtts) == 0 {
+		return stats
+	}
+
+	sort.Float64s(rtts)
+	stats.MinMs = rtts[0]
+	stats.MaxMs = rtts[len(rtts)-1]
+	stats.AvgMs = meanRTT(rtts)
+
+	var variance float64
+	for _, rtt := range rtts {
+		variance += (rtt - stats.AvgMs) * (rtt - stats.AvgMs)
+	}
+	stats.JitterMs = math.Sqrt(variance / float64(len(rtts)))
+
+	// Nearest-rank 95th percentile
+	rank := int(math.Ceil(0.95*float64(len(rtts)))) - 1
+	stats.P95Ms = rtts[rank]
+
+	return stats
+}
@@ -0,0 +1,127 @@
+package probes
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// STUN message types and attributes (RFC 5389).
+const (
+	stunBindingRequest  = 0x0001
+	stunBindingResponse = 0x0101
+	stunMagicCookie     = 0x2112a442
+
+	stunAttrMappedAddress    = 0x0001
+	stunAttrXORMappedAddress = 0x0020
+)
+
+var errNoMappedAddress = errors.New("no mapped address in STUN response")
+
+// QuerySTUN sends a binding request to a STUN server (host:port) and
+// returns the address the server saw the request come from.
+func QuerySTUN(ctx context.Context, server string, timeout time.Duration) (net.IP, error) {
+	req := make([]byte, 20)
+	binary.BigEndian.PutUint16(req[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint32(req[4:8], stunMagicCookie)
+	if _, err := rand.Read(req[8:20]); err != nil {
+		return nil, err
+	}
+
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	// UDP may lose the request; retry a few times within the deadline
+	buf := make([]byte, 1500)
+	for attempt := 0; ; attempt++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(minTime(deadline, time.Now().Add(500*time.Millisecond<<attempt)))
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() && time.Now().Before(deadline) {
+					break
+				}
+				return nil, err
+			}
+			if n < 20 || binary.BigEndian.Uint16(buf[0:2]) != stunBindingResponse ||
+				!bytes.Equal(buf[4:20], req[4:20]) {
+				continue
+			}
+			return parseSTUNAddress(buf[:n])
+		}
+	}
+}
+
+// parseSTUNAddress returns the XOR-MAPPED-ADDRESS of a binding response,
+// or the MAPPED-ADDRESS sent by RFC 3489 servers.
+func parseSTUNAddress(msg []byte) (net.IP, error) {
+	length := int(binary.BigEndian.Uint16(msg[2:4]))
+	if 20+length > len(msg) {
+		return nil, fmt.Errorf("truncated STUN response")
+	}
+
+	var mapped net.IP
+	for attrs := msg[20 : 20+length]; len(attrs) >= 4; {
+		typ := binary.BigEndian.Uint16(attrs[0:2])
+		size := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+size > len(attrs) {
+			return nil, fmt.Errorf("truncated STUN attribute")
+		}
+		value := attrs[4 : 4+size]
+
+		if (typ == stunAttrXORMappedAddress || typ == stunAttrMappedAddress) && len(value) >= 8 {
+			ip := make(net.IP, 4)
+			if value[1] == 0x02 && len(value) >= 20 {
+				ip = make(net.IP, 16)
+			}
+			copy(ip, value[4:4+len(ip)])
+			if typ == stunAttrXORMappedAddress {
+				// XORed with the magic cookie and, for IPv6, the
+				// transaction ID that follows it
+				for i := range ip {
+					ip[i] ^= msg[4+i]
+				}
+				return ip, nil
+			}
+			mapped = ip
+		}
+
+		// Attributes are padded to 4 bytes
+		next := 4 + (size+3)&^3
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+
+	if mapped == nil {
+		return nil, errNoMappedAddress
+	}
+	return mapped, nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
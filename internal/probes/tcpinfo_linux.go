@@ -0,0 +1,23 @@
+package probes
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcpRetransmits returns how many segments the kernel has retransmitted on
+// the connection so far.
+func tcpRetransmits(conn *net.TCPConn) int {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	var retrans int
+	raw.Control(func(fd uintptr) {
+		if info, err := unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO); err == nil {
+			retrans = int(info.Total_retrans)
+		}
+	})
+	return retrans
+}
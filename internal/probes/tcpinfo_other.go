@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package probes
+
+import "net"
+
+// tcpRetransmits is not available without TCP_INFO and reports 0.
+func tcpRetransmits(conn *net.TCPConn) int {
+	return 0
+}
@@ -0,0 +1,295 @@
+package probes
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// Throughput test protocols and directions. Directions are seen from the
+// client: upload sends to the server, download receives from it.
+const (
+	ThroughputTCP     = "tcp"
+	ThroughputUDP     = "udp"
+	DirectionUpload   = "upload"
+	DirectionDownload = "download"
+)
+
+// DefaultThroughputPort is the TCP and UDP port of the throughput server.
+const DefaultThroughputPort = 5301
+
+// The control protocol is line based over TCP. The client sends
+//
+//	NETPULSE-TPUT/1 <upload|download|udp> <duration ms> [bits/s]
+//
+// and the server answers "OK [session]" or "ERR <reason>". TCP downloads are
+// sent as fixed-size blocks whose first byte is 0, except for the final
+// block, which carries the server's result line. Uploads and UDP tests end
+// with a result line from the server on the control connection.
+const (
+	throughputMagic   = "NETPULSE-TPUT/1"
+	throughputBlock   = 128 * 1024
+	maxThroughputTime = 60 * time.Second
+
+	udpTestHeader  = 16 // session, sequence, send time (ns)
+	udpTestPayload = 1200
+)
+
+// ThroughputTest describes one timed transfer.
+type ThroughputTest struct {
+	Server    string // host or host:port
+	Port      int    // Used when Server has no port; DefaultThroughputPort if 0
+	Protocol  string // tcp or udp
+	Direction string // upload or download; UDP tests are upload only
+	Duration  time.Duration
+	UDPRate   int64 // Target UDP send rate in bits/s
+}
+
+// RunThroughputTest runs a test against a netpulse throughput server.
+func RunThroughputTest(ctx context.Context, test ThroughputTest) *model.ThroughputResult {
+	if test.Duration <= 0 {
+		test.Duration = 10 * time.Second
+	}
+	if test.Duration > maxThroughputTime {
+		test.Duration = maxThroughputTime
+	}
+	test.Server = throughputAddr(test.Server, test.Port)
+
+	result := &model.ThroughputResult{
+		Server:    test.Server,
+		Protocol:  test.Protocol,
+		Direction: test.Direction,
+		Timestamp: time.Now(),
+	}
+
+	var err error
+	switch {
+	case test.Protocol == ThroughputTCP && test.Direction == DirectionUpload:
+		err = tcpUpload(ctx, test, result)
+	case test.Protocol == ThroughputTCP && test.Direction == DirectionDownload:
+		err = tcpDownload(ctx, test, result)
+	case test.Protocol == ThroughputUDP && test.Direction == DirectionUpload:
+		err = udpUpload(ctx, test, result)
+	default:
+		err = fmt.Errorf("unsupported test %s %s", test.Protocol, test.Direction)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	if result.DurationMs > 0 {
+		result.Mbps = float64(result.Bytes) * 8 / (result.DurationMs * 1000)
+	}
+	result.Success = true
+	return result
+}
+
+// throughputAddr adds the port to a server given as a bare host name or
+// address, including an IPv6 address with or without brackets.
+func throughputAddr(server string, port int) string {
+	if port <= 0 {
+		port = DefaultThroughputPort
+	}
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	host := strings.Trim(server, "[]")
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// openControl connects to the server and starts a test.
+func openControl(ctx context.Context, test ThroughputTest, mode string, args ...string) (*net.TCPConn, *bufio.Reader, string, error) {
+	d := net.Dialer{Timeout: 5 * time.Second}
+	c, err := d.DialContext(ctx, "tcp", test.Server)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	conn := c.(*net.TCPConn)
+
+	// Bound the whole exchange, including the server's final report
+	conn.SetDeadline(time.Now().Add(test.Duration + 15*time.Second))
+
+	line := strings.Join(append([]string{throughputMagic, mode, strconv.FormatInt(test.Duration.Milliseconds(), 10)}, args...), " ")
+	if _, err := conn.Write([]byte(line + "\n")); err != nil {
+		conn.Close()
+		return nil, nil, "", err
+	}
+
+	r := bufio.NewReader(conn)
+	reply, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, nil, "", fmt.Errorf("no reply from server: %w", err)
+	}
+	fields := strings.Fields(reply)
+	if len(fields) == 0 || fields[0] != "OK" {
+		conn.Close()
+		return nil, nil, "", fmt.Errorf("server refused test: %s", strings.TrimSpace(reply))
+	}
+	session := ""
+	if len(fields) > 1 {
+		session = fields[1]
+	}
+	return conn, r, session, nil
+}
+
+// tcpUpload streams data to the server, which counts what arrived.
+func tcpUpload(ctx context.Context, test ThroughputTest, result *model.ThroughputResult) error {
+	conn, r, _, err := openControl(ctx, test, DirectionUpload)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	block := make([]byte, throughputBlock)
+	end := time.Now().Add(test.Duration)
+	for time.Now().Before(end) && ctx.Err() == nil {
+		if _, err := conn.Write(block); err != nil {
+			return fmt.Errorf("upload failed: %w", err)
+		}
+	}
+	result.Retransmits = tcpRetransmits(conn)
+	conn.CloseWrite()
+
+	report, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("no result from server: %w", err)
+	}
+	bytes, elapsed, _, err := parseThroughputReport(report)
+	if err != nil {
+		return err
+	}
+	result.Bytes = bytes
+	result.DurationMs = float64(elapsed.Microseconds()) / 1000.0
+	return nil
+}
+
+// tcpDownload receives blocks until the server's final block, which
+// reports how many segments the server had to resend.
+func tcpDownload(ctx context.Context, test ThroughputTest, result *model.ThroughputResult) error {
+	conn, r, _, err := openControl(ctx, test, DirectionDownload)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	block := make([]byte, throughputBlock)
+	var start time.Time
+	for {
+		if _, err := io.ReadFull(r, block); err != nil {
+			return fmt.Errorf("download failed: %w", err)
+		}
+		if start.IsZero() {
+			start = time.Now()
+		}
+		if block[0] != 0 {
+			break
+		}
+		result.Bytes += throughputBlock
+	}
+	result.DurationMs = msSince(start)
+
+	report, _, _ := strings.Cut(string(block[1:]), "\n")
+	_, _, retrans, err := parseThroughputReport(report)
+	if err != nil {
+		return err
+	}
+	result.Retransmits = retrans
+	return nil
+}
+
+// udpUpload sends paced datagrams and compares the count with what the
+// server received. Jitter is computed by the server from the send times.
+func udpUpload(ctx context.Context, test ThroughputTest, result *model.ThroughputResult) error {
+	rate := test.UDPRate
+	if rate <= 0 {
+		rate = 10_000_000
+	}
+	conn, r, session, err := openControl(ctx, test, ThroughputUDP, strconv.FormatInt(rate, 10))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	id, err := strconv.ParseUint(session, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid session %q", session)
+	}
+
+	udp, err := net.Dial("udp", test.Server)
+	if err != nil {
+		return err
+	}
+	defer udp.Close()
+
+	// Send in small bursts to keep up with the target rate
+	packet := make([]byte, udpTestPayload)
+	binary.BigEndian.PutUint32(packet[0:4], uint32(id))
+	perSecond := float64(rate) / float64(udpTestPayload*8)
+	start := time.Now()
+	end := start.Add(test.Duration)
+	var sent uint32
+	for now := start; now.Before(end) && ctx.Err() == nil; now = time.Now() {
+		due := uint32(now.Sub(start).Seconds() * perSecond)
+		for ; sent <= due; sent++ {
+			binary.BigEndian.PutUint32(packet[4:8], sent)
+			binary.BigEndian.PutUint64(packet[8:16], uint64(time.Now().UnixNano()))
+			udp.Write(packet)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := fmt.Fprintf(conn, "DONE %d\n", sent); err != nil {
+		return err
+	}
+	report, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("no result from server: %w", err)
+	}
+	fields := strings.Fields(report)
+	if len(fields) != 5 || fields[0] != "RESULT" {
+		return fmt.Errorf("malformed result: %s", strings.TrimSpace(report))
+	}
+	received, _ := strconv.ParseInt(fields[1], 10, 64)
+	bytes, _ := strconv.ParseInt(fields[2], 10, 64)
+	elapsed, _ := strconv.ParseInt(fields[3], 10, 64)
+	jitter, _ := strconv.ParseInt(fields[4], 10, 64)
+
+	result.Bytes = bytes
+	result.DurationMs = float64(elapsed) / 1000.0
+	result.JitterMs = float64(jitter) / 1000.0
+	if sent > 0 {
+		lost := int64(sent) - received
+		if lost < 0 {
+			lost = 0
+		}
+		result.LossPct = float64(lost) / float64(sent) * 100
+	}
+	return nil
+}
+
+// parseThroughputReport parses "RESULT <bytes> <µs> <retransmits>".
+func parseThroughputReport(line string) (int64, time.Duration, int, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 4 || fields[0] != "RESULT" {
+		return 0, 0, 0, fmt.Errorf("malformed result: %s", strings.TrimSpace(line))
+	}
+	bytes, err1 := strconv.ParseInt(fields[1], 10, 64)
+	us, err2 := strconv.ParseInt(fields[2], 10, 64)
+	retrans, err3 := strconv.Atoi(fields[3])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, 0, 0, fmt.Errorf("malformed result: %s", strings.TrimSpace(line))
+	}
+	return bytes, time.Duration(us) * time.Microsecond, retrans, nil
+}
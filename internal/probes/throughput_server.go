@@ -0,0 +1,201 @@
+package probes
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThroughputServer answers throughput tests from netpulse clients on one
+// TCP and one UDP port.
+type ThroughputServer struct {
+	addr string
+
+	mu       sync.Mutex
+	sessions map[uint32]*udpSession
+}
+
+// udpSession accumulates the datagrams of one UDP test.
+type udpSession struct {
+	received    int64
+	bytes       int64
+	first, last time.Time
+	transit     float64 // Previous receive minus send time, ns
+	jitter      float64 // RFC 3550 interarrival jitter, ns
+}
+
+// NewThroughputServer creates a server listening on addr (host:port).
+func NewThroughputServer(addr string) *ThroughputServer {
+	return &ThroughputServer{
+		addr:     addr,
+		sessions: make(map[uint32]*udpSession),
+	}
+}
+
+// ListenAndServe serves tests until ctx is cancelled.
+func (s *ThroughputServer) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on tcp %s: %w", s.addr, err)
+	}
+	udp, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		ln.Close()
+		return fmt.Errorf("failed to listen on udp %s: %w", s.addr, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+		udp.Close()
+	}()
+	go s.readUDP(udp)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		go s.handle(conn.(*net.TCPConn))
+	}
+}
+
+// handle runs one test on a control connection.
+func (s *ThroughputServer) handle(conn *net.TCPConn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[0] != throughputMagic {
+		fmt.Fprintf(conn, "ERR bad request\n")
+		return
+	}
+	ms, err := strconv.ParseInt(fields[2], 10, 64)
+	duration := time.Duration(ms) * time.Millisecond
+	if err != nil || duration <= 0 || duration > maxThroughputTime {
+		fmt.Fprintf(conn, "ERR bad duration\n")
+		return
+	}
+	conn.SetDeadline(time.Now().Add(duration + 15*time.Second))
+
+	switch fields[1] {
+	case DirectionUpload:
+		s.receiveTCP(conn, r)
+	case DirectionDownload:
+		s.sendTCP(conn, duration)
+	case ThroughputUDP:
+		s.receiveUDP(conn, r)
+	default:
+		fmt.Fprintf(conn, "ERR unknown mode\n")
+	}
+}
+
+// receiveTCP counts upload bytes until the client closes its side.
+func (s *ThroughputServer) receiveTCP(conn *net.TCPConn, r *bufio.Reader) {
+	fmt.Fprintf(conn, "OK\n")
+	start := time.Now()
+	n, _ := io.Copy(io.Discard, r)
+	fmt.Fprintf(conn, "RESULT %d %d 0\n", n, time.Since(start).Microseconds())
+}
+
+// sendTCP streams blocks for the duration, then the final block with the
+// result line.
+func (s *ThroughputServer) sendTCP(conn *net.TCPConn, duration time.Duration) {
+	fmt.Fprintf(conn, "OK\n")
+
+	block := make([]byte, throughputBlock)
+	start := time.Now()
+	var n int64
+	for time.Since(start) < duration {
+		if _, err := conn.Write(block); err != nil {
+			return
+		}
+		n += throughputBlock
+	}
+
+	final := make([]byte, throughputBlock)
+	final[0] = 1
+	copy(final[1:], fmt.Sprintf("RESULT %d %d %d\n", n, time.Since(start).Microseconds(), tcpRetransmits(conn)))
+	conn.Write(final)
+}
+
+// receiveUDP registers a session, waits for the client to finish sending
+// and reports what arrived.
+func (s *ThroughputServer) receiveUDP(conn *net.TCPConn, r *bufio.Reader) {
+	id := rand.Uint32()
+	s.mu.Lock()
+	s.sessions[id] = &udpSession{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, id)
+		s.mu.Unlock()
+	}()
+
+	fmt.Fprintf(conn, "OK %d\n", id)
+	if _, err := r.ReadString('\n'); err != nil {
+		return
+	}
+	// Let datagrams still in flight arrive
+	time.Sleep(250 * time.Millisecond)
+
+	s.mu.Lock()
+	sess := *s.sessions[id]
+	s.mu.Unlock()
+	fmt.Fprintf(conn, "RESULT %d %d %d %d\n",
+		sess.received, sess.bytes, sess.last.Sub(sess.first).Microseconds(), int64(sess.jitter/1000))
+}
+
+// readUDP accounts datagrams to their sessions.
+func (s *ThroughputServer) readUDP(conn net.PacketConn) {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if n < udpTestHeader {
+			continue
+		}
+		now := time.Now()
+		id := binary.BigEndian.Uint32(buf[0:4])
+		sent := int64(binary.BigEndian.Uint64(buf[8:16]))
+
+		s.mu.Lock()
+		if sess, ok := s.sessions[id]; ok {
+			transit := float64(now.UnixNano() - sent)
+			if sess.received == 0 {
+				sess.first = now
+			} else {
+				sess.jitter += (math.Abs(transit-sess.transit) - sess.jitter) / 16
+			}
+			sess.transit = transit
+			sess.last = now
+			sess.received++
+			sess.bytes += int64(n)
+		}
+		s.mu.Unlock()
+	}
+}
@@ -3,9 +3,10 @@ package probes
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"os/exec"
-	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -14,30 +15,72 @@ import (
 	"github.com/user/netpulse/internal/model"
 )
 
+// Traceroute engines.
+const (
+	TraceMethodNative = "native" // Raw-socket engine built into netpulse
+	TraceMethodSystem = "system" // Exec the system traceroute binary
+)
+
+// Probe protocols for the native engine.
+const (
+	TraceProtocolUDP  = "udp"
+	TraceProtocolICMP = "icmp"
+)
+
+// Trace modes. Classic varies the flow per probe, Paris keeps the 5-tuple
+// constant so ECMP load balancers send every probe down the same path, and
+// MDA deliberately varies flows to enumerate every next hop per TTL.
+const (
+	TraceModeClassic = "classic"
+	TraceModeParis   = "paris"
+	TraceModeMDA     = "mda"
+)
+
 // TracerouteProbe handles traceroute operations.
 type TracerouteProbe struct {
-	maxHops int
-	timeout time.Duration
+	maxHops      int
+	timeout      time.Duration
+	method       string
+	protocol     string
+	mode         string
+	probesPerHop int
 }
 
 // NewTracerouteProbe creates a new traceroute probe.
 func NewTracerouteProbe() *TracerouteProbe {
 	return &TracerouteProbe{
-		maxHops: 30,
-		timeout: 3 * time.Second,
+		maxHops:      30,
+		timeout:      3 * time.Second,
+		method:       TraceMethodNative,
+		protocol:     TraceProtocolUDP,
+		mode:         TraceModeParis,
+		probesPerHop: 3,
 	}
 }
 
-// Trace performs a traceroute to the target using system command.
+// Trace performs a traceroute to the target. The native engine is used unless
+// the system method is configured; if raw sockets are not permitted it falls
+// back to the system traceroute command.
 func (p *TracerouteProbe) Trace(ctx context.Context, target string) (*model.TraceResult, error) {
 	result := &model.TraceResult{
 		Target:    target,
 		Timestamp: time.Now(),
+		Mode:      p.mode,
 		Hops:      make([]model.TraceHop, 0, p.maxHops),
 	}
 
-	// Use system traceroute command
-	hops, err := p.runSystemTraceroute(ctx, target)
+	var hops []model.TraceHop
+	var err error
+	if p.method == TraceMethodSystem {
+		hops, err = p.runSystemTraceroute(ctx, target)
+		result.Mode = TraceModeClassic
+	} else {
+		hops, err = p.runNativeTraceroute(ctx, target)
+		if errors.Is(err, errRawSocketUnavailable) {
+			hops, err = p.runSystemTraceroute(ctx, target)
+			result.Mode = TraceModeClassic
+		}
+	}
 	if err != nil {
 		return result, err
 	}
@@ -47,63 +90,178 @@ func (p *TracerouteProbe) Trace(ctx context.Context, target string) (*model.Trac
 }
 
 func (p *TracerouteProbe) runSystemTraceroute(ctx context.Context, target string) ([]model.TraceHop, error) {
-	// Build command based on OS
-	var cmd *exec.Cmd
-	
 	// Use traceroute on Unix (macOS/Linux)
-	// -n = numeric output (no DNS), -q 1 = 1 probe per hop, -w = wait time
-	cmd = exec.CommandContext(ctx, "traceroute", "-n", "-q", "1", "-w", "2", "-m", 
-		strconv.Itoa(p.maxHops), target)
+	// -n = numeric output (no DNS), -q = probes per hop, -w = wait time
+	args := []string{"-n", "-q", strconv.Itoa(p.probesPerHop), "-w", "2", "-m", strconv.Itoa(p.maxHops)}
 
-	output, err := cmd.Output()
-	if err != nil {
-		// Try fallback to ICMP traceroute
-		cmd = exec.CommandContext(ctx, "traceroute", "-n", "-I", "-q", "1", "-w", "2", "-m",
-			strconv.Itoa(p.maxHops), target)
-		output, err = cmd.Output()
+	// Commands to try in order; the ICMP variants are fallbacks
+	type command struct {
+		name string
+		args []string
+		icmp bool
+	}
+	var commands []command
+	if isIPv6Literal(target) {
+		// Linux traceroute takes -6, BSD and macOS ship traceroute6
+		commands = []command{
+			{"traceroute", append([]string{"-6"}, args...), false},
+			{"traceroute6", args, false},
+			{"traceroute", append([]string{"-6", "-I"}, args...), true},
+			{"traceroute6", append([]string{"-I"}, args...), true},
+		}
+	} else {
+		commands = []command{
+			{"traceroute", args, false},
+			{"traceroute", append([]string{"-I"}, args...), true},
+		}
+	}
+
+	var lastErr error
+	for _, c := range commands {
+		cmd := exec.CommandContext(ctx, c.name, append(c.args, target)...)
+		output, err := cmd.Output()
 		if err != nil {
-			return nil, fmt.Errorf("traceroute failed: %w", err)
+			lastErr = err
+			continue
 		}
+		return p.parseTracerouteOutput(string(output), target, c.icmp)
 	}
 
-	return p.parseTracerouteOutput(string(output))
+	return nil, fmt.Errorf("traceroute failed: %w", lastErr)
+}
+
+// isIPv6Literal reports whether s is an IPv6 address.
+func isIPv6Literal(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() == nil
 }
 
 // parseTracerouteOutput parses the output of the traceroute command.
-func (p *TracerouteProbe) parseTracerouteOutput(output string) ([]model.TraceHop, error) {
+// Hop lines look like " 1  192.168.0.1  1.234 ms  1.101 ms  * !X" or " 1  * * *";
+// traceroute6 output has the same layout with IPv6 addresses.
+func (p *TracerouteProbe) parseTracerouteOutput(output, target string, icmp bool) ([]model.TraceHop, error) {
 	var hops []model.TraceHop
 
-	// Regex to match hop lines
-	// Format: " 1  192.168.0.1  1.234 ms" or " 1  * * *"
-	hopRegex := regexp.MustCompile(`^\s*(\d+)\s+(?:(\d+\.\d+\.\d+\.\d+)\s+(\d+\.?\d*)\s*ms|\*\s+\*\s+\*)`)
-	
 	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
-		line := scanner.Text()
-		
-		matches := hopRegex.FindStringSubmatch(line)
-		if len(matches) >= 2 {
-			hopNum, _ := strconv.Atoi(matches[1])
-			hop := model.TraceHop{
-				HopNum: hopNum,
-				Lost:   true,
-			}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		hopNum, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue // Header line
+		}
 
-			if len(matches) >= 4 && matches[2] != "" {
-				hop.IP = matches[2]
-				hop.Lost = false
-				if matches[3] != "" {
-					hop.LatencyMs, _ = strconv.ParseFloat(matches[3], 64)
+		hop := model.TraceHop{
+			HopNum: hopNum,
+			Lost:   true,
+		}
+		annotated := false
+
+		for i := 1; i < len(fields); i++ {
+			field := fields[i]
+			switch {
+			case field == "*":
+			case strings.HasPrefix(field, "!"):
+				if typ, code, ok := unreachableAnnotation(field, isIPv6Literal(hop.IP)); ok {
+					hop.ICMPType, hop.ICMPCode = typ, code
+					annotated = true
+				}
+			case i+1 < len(fields) && fields[i+1] == "ms":
+				if rtt, err := strconv.ParseFloat(field, 64); err == nil {
+					hop.RTTs = append(hop.RTTs, rtt)
+				}
+				i++
+			case net.ParseIP(field) != nil:
+				if hop.IP == "" {
+					hop.IP = field
+				}
+				if !containsString(hop.HopSet, field) {
+					hop.HopSet = append(hop.HopSet, field)
 				}
 			}
+		}
 
-			hops = append(hops, hop)
+		if hop.IP != "" {
+			hop.Lost = false
+			hop.LatencyMs = meanRTT(hop.RTTs)
+			if !annotated {
+				hop.ICMPType, hop.ICMPCode = inferICMP(hop.IP, target, icmp)
+			}
 		}
+
+		hops = append(hops, hop)
 	}
 
 	return hops, nil
 }
 
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// inferICMP returns the ICMP type and code a hop most likely answered with,
+// for traceroute output that does not show it.
+func inferICMP(hopIP, target string, icmp bool) (int, int) {
+	v6 := isIPv6Literal(hopIP)
+	reached := hopIP == target
+	if ip := net.ParseIP(target); ip != nil {
+		reached = ip.Equal(net.ParseIP(hopIP))
+	}
+
+	switch {
+	case !reached && v6:
+		return icmp6TimeExceeded, 0
+	case !reached:
+		return icmpTimeExceeded, 0
+	case icmp && v6:
+		return icmp6EchoReply, 0
+	case icmp:
+		return icmpEchoReply, 0
+	case v6:
+		return icmp6DestUnreachable, icmp6CodePortUnreachable
+	default:
+		return icmpDestUnreachable, icmpCodePortUnreachable
+	}
+}
+
+// unreachableAnnotation maps traceroute's "!X" style flags to ICMP type/code.
+func unreachableAnnotation(flag string, v6 bool) (int, int, bool) {
+	if v6 {
+		switch flag {
+		case "!N":
+			return icmp6DestUnreachable, icmp6CodeNoRoute, true
+		case "!A", "!H":
+			return icmp6DestUnreachable, icmp6CodeAddrUnreachable, true
+		case "!X", "!S":
+			return icmp6DestUnreachable, icmp6CodeAdminProhibited, true
+		case "!P":
+			return icmp6DestUnreachable, icmp6CodePortUnreachable, true
+		}
+		return 0, 0, false
+	}
+
+	switch flag {
+	case "!N":
+		return icmpDestUnreachable, icmpCodeNetUnreachable, true
+	case "!H":
+		return icmpDestUnreachable, icmpCodeHostUnreachable, true
+	case "!P":
+		return icmpDestUnreachable, icmpCodeProtoUnreachable, true
+	case "!F":
+		return icmpDestUnreachable, icmpCodeFragNeeded, true
+	case "!X":
+		return icmpDestUnreachable, icmpCodeAdminProhibited, true
+	}
+	return 0, 0, false
+}
+
 // TraceMultiple traces multiple targets concurrently.
 func (p *TracerouteProbe) TraceMultiple(ctx context.Context, targets []string) ([]*model.TraceResult, error) {
 	results := make([]*model.TraceResult, len(targets))
@@ -147,3 +305,106 @@ func (p *TracerouteProbe) SetTimeout(timeout time.Duration) {
 		p.timeout = timeout
 	}
 }
+
+// SetMethod selects the traceroute engine ("native" or "system").
+func (p *TracerouteProbe) SetMethod(method string) {
+	if method == TraceMethodNative || method == TraceMethodSystem {
+		p.method = method
+	}
+}
+
+// SetProtocol selects the probe protocol for the native engine ("udp" or "icmp").
+func (p *TracerouteProbe) SetProtocol(protocol string) {
+	if protocol == TraceProtocolUDP || protocol == TraceProtocolICMP {
+		p.protocol = protocol
+	}
+}
+
+// SetMode selects the trace mode ("classic", "paris" or "mda").
+func (p *TracerouteProbe) SetMode(mode string) {
+	if mode == TraceModeClassic || mode == TraceModeParis || mode == TraceModeMDA {
+		p.mode = mode
+	}
+}
+
+// SetProbesPerHop sets the number of probes sent for each TTL.
+func (p *TracerouteProbe) SetProbesPerHop(n int) {
+	if n > 0 && n <= 10 {
+		p.probesPerHop = n
+	}
+}
+
+// HopAddresses returns every address seen at a hop. Lost hops return nil.
+func HopAddresses(hop model.TraceHop) []string {
+	if hop.Lost {
+		return nil
+	}
+	if len(hop.HopSet) > 0 {
+		return hop.HopSet
+	}
+	if hop.IP != "" {
+		return []string{hop.IP}
+	}
+	return nil
+}
+
+// SharesAddress reports whether two hop address sets have an address in
+// common, as load-balanced hops answering from the same set do.
+func SharesAddress(a, b []string) bool {
+	for _, x := range a {
+		if containsString(b, x) {
+			return true
+		}
+	}
+	return false
+}
+
+// RouteChanged reports whether two traces took different routes. Hops that
+// answered from overlapping address sets (load-balanced paths) are treated as
+// the same hop; timeouts are ignored.
+func RouteChanged(old, new []model.TraceHop) bool {
+	if !answered(old) || !answered(new) {
+		return answered(old) != answered(new)
+	}
+
+	oldSets := make(map[int][]string)
+	for _, hop := range old {
+		oldSets[hop.HopNum] = HopAddresses(hop)
+	}
+
+	for _, hop := range new {
+		newSet := HopAddresses(hop)
+		oldSet, ok := oldSets[hop.HopNum]
+		if !ok {
+			if len(newSet) > 0 {
+				return true
+			}
+			continue
+		}
+		if len(oldSet) == 0 || len(newSet) == 0 {
+			continue
+		}
+		if !SharesAddress(oldSet, newSet) {
+			return true
+		}
+	}
+
+	// The path got shorter
+	for _, hop := range old {
+		if hop.HopNum > len(new) && len(HopAddresses(hop)) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// answered reports whether any hop of a trace answered.
+func answered(hops []model.TraceHop) bool {
+	for _, hop := range hops {
+		if len(HopAddresses(hop)) > 0 {
+			return true
+		}
+	}
+	return false
+}
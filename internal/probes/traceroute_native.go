@@ -0,0 +1,382 @@
+package probes
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// errRawSocketUnavailable is returned when the native engine cannot open a raw
+// ICMP socket, usually because the process lacks CAP_NET_RAW or admin rights.
+var errRawSocketUnavailable = errors.New("raw ICMP socket unavailable")
+
+const (
+	// traceBasePort is the first destination port used for UDP probes.
+	traceBasePort = 33434
+
+	// parisBaseLen is the smallest UDP payload used in Paris mode. Probes are
+	// told apart by payload length, which is echoed in the quoted IP header.
+	parisBaseLen = 16
+
+	// parisChecksum is the value the Paris ICMP probes' checksum is held at.
+	parisChecksum = 0x5a5a
+)
+
+// mdaStopping holds, for k next hops seen so far, the number of probes needed
+// to rule out a (k+1)th next hop with 95% confidence (Veitch et al.).
+var mdaStopping = []int{0, 6, 11, 16, 21, 27, 33, 38, 44, 51, 57, 63, 70, 76, 83, 90, 96}
+
+// nativeTrace holds the state of a single native traceroute run.
+type nativeTrace struct {
+	conn     net.PacketConn // Raw ICMP socket receiving replies
+	udp      net.PacketConn // Probe socket for UDP mode
+	srcPort  int
+	dst      *net.IPAddr
+	v6       bool
+	protocol string
+	mode     string
+	probes   int
+	timeout  time.Duration
+	id       int
+	seq      int
+}
+
+// hopReply is a single ICMP answer to a probe.
+type hopReply struct {
+	seq  int
+	from string
+	rtt  float64
+	typ  int
+	code int
+
+	// final is set when the reply comes from the destination itself or
+	// reports it unreachable.
+	final bool
+}
+
+// runNativeTraceroute traces the route using raw sockets instead of the
+// system traceroute binary. IPv6 targets are traced with ICMPv6 and hop limits.
+func (p *TracerouteProbe) runNativeTraceroute(ctx context.Context, target string) ([]model.TraceHop, error) {
+	dst, err := net.ResolveIPAddr("ip", target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", target, err)
+	}
+	v6 := dst.IP.To4() == nil
+
+	icmpNetwork, laddr, udpNetwork := "ip4:icmp", "0.0.0.0", "udp4"
+	if v6 {
+		icmpNetwork, laddr, udpNetwork = "ip6:ipv6-icmp", "::", "udp6"
+	}
+
+	conn, err := net.ListenPacket(icmpNetwork, laddr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errRawSocketUnavailable, err)
+	}
+	defer conn.Close()
+
+	t := &nativeTrace{
+		conn:     conn,
+		dst:      dst,
+		v6:       v6,
+		protocol: p.protocol,
+		mode:     p.mode,
+		probes:   p.probesPerHop,
+		timeout:  p.timeout,
+		id:       os.Getpid() & 0xffff,
+	}
+
+	// A single UDP socket keeps the source port fixed for the whole trace
+	if t.protocol != TraceProtocolICMP {
+		udp, err := net.ListenPacket(udpNetwork, ":0")
+		if err != nil {
+			return nil, fmt.Errorf("failed to open UDP socket: %w", err)
+		}
+		defer udp.Close()
+		t.udp = udp
+		t.srcPort = udp.LocalAddr().(*net.UDPAddr).Port
+	}
+
+	var hops []model.TraceHop
+	for ttl := 1; ttl <= p.maxHops; ttl++ {
+		select {
+		case <-ctx.Done():
+			return hops, ctx.Err()
+		default:
+		}
+
+		replies, err := t.probeHop(ctx, ttl)
+		if err != nil {
+			return hops, err
+		}
+
+		hop, reached := buildHop(ttl, replies)
+		hops = append(hops, hop)
+		if reached {
+			break
+		}
+	}
+
+	return hops, nil
+}
+
+// probeHop sends the probes for one TTL and collects the replies. In MDA mode
+// it keeps sending new flows until the stopping rule is satisfied.
+func (t *nativeTrace) probeHop(ctx context.Context, ttl int) ([]hopReply, error) {
+	if err := t.setTTL(ttl); err != nil {
+		return nil, fmt.Errorf("failed to set TTL: %w", err)
+	}
+
+	count := t.probes
+	if t.mode == TraceModeMDA {
+		count = mdaStopping[1]
+	}
+
+	pending, err := t.send(count)
+	if err != nil {
+		return nil, err
+	}
+	replies := t.collect(ctx, pending)
+	if t.mode != TraceModeMDA || len(replies) == 0 {
+		return replies, nil
+	}
+
+	sent := count
+	for ctx.Err() == nil {
+		k := len(distinctResponders(replies))
+		if k >= len(mdaStopping) || sent >= mdaStopping[k] {
+			break
+		}
+		pending, err := t.send(mdaStopping[k] - sent)
+		if err != nil {
+			return nil, err
+		}
+		sent = mdaStopping[k]
+		replies = append(replies, t.collect(ctx, pending)...)
+	}
+
+	sort.Slice(replies, func(i, j int) bool { return replies[i].seq < replies[j].seq })
+	return replies, nil
+}
+
+// setTTL applies the TTL to whichever socket sends the probes.
+func (t *nativeTrace) setTTL(ttl int) error {
+	var rc syscall.RawConn
+	var err error
+	if t.udp != nil {
+		rc, err = t.udp.(*net.UDPConn).SyscallConn()
+	} else {
+		rc, err = t.conn.(*net.IPConn).SyscallConn()
+	}
+	if err != nil {
+		return err
+	}
+	network := "ip4"
+	if t.v6 {
+		network = "ip6"
+	}
+	return setTTL(ttl)(network, "", rc)
+}
+
+// send transmits count probes and returns their send times keyed by sequence.
+func (t *nativeTrace) send(count int) (map[int]time.Time, error) {
+	pending := make(map[int]time.Time, count)
+
+	for i := 0; i < count; i++ {
+		t.seq++
+		var err error
+		pending[t.seq] = time.Now()
+		if t.udp != nil {
+			payload, port := t.udpProbe(t.seq)
+			_, err = t.udp.WriteTo(payload, &net.UDPAddr{IP: t.dst.IP, Port: port})
+		} else {
+			_, err = t.conn.WriteTo(t.icmpProbe(t.seq), t.dst)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to send probe: %w", err)
+		}
+	}
+
+	return pending, nil
+}
+
+// udpProbe returns the payload and destination port for a UDP probe.
+// Classic and MDA modes change the destination port (and so the flow) per
+// probe. Paris mode keeps the 5-tuple and the UDP checksum constant and
+// encodes the sequence in the payload length instead.
+func (t *nativeTrace) udpProbe(seq int) ([]byte, int) {
+	if t.mode != TraceModeParis {
+		return []byte("netpulse"), traceBasePort + seq
+	}
+
+	payload := make([]byte, parisBaseLen+2*seq)
+	udpLen := uint16(8 + len(payload))
+	// The length is summed twice (pseudo-header and UDP header); the first
+	// payload word cancels it out so the checksum never changes.
+	binary.BigEndian.PutUint16(payload[0:2], onesAdd(parisChecksum, ^onesAdd(udpLen, udpLen)))
+	return payload, traceBasePort
+}
+
+// icmpProbe builds an ICMP echo probe. Paris mode compensates the sequence
+// number in the payload so type, code and checksum (the flow as seen by
+// load balancers) stay the same for every probe.
+func (t *nativeTrace) icmpProbe(seq int) []byte {
+	payload := []byte("netpulse")
+	if t.mode == TraceModeParis {
+		binary.BigEndian.PutUint16(payload[0:2], onesAdd(parisChecksum, ^uint16(seq)))
+	}
+	if t.v6 {
+		return marshalEcho6(t.id, seq, payload)
+	}
+	return marshalEcho(t.id, seq, payload)
+}
+
+// collect reads ICMP messages until every pending probe is answered or the
+// per-hop timeout expires.
+func (t *nativeTrace) collect(ctx context.Context, pending map[int]time.Time) []hopReply {
+	var replies []hopReply
+	buf := make([]byte, 1500)
+	deadline := time.Now().Add(t.timeout)
+
+	for len(pending) > 0 {
+		if ctx.Err() != nil {
+			break
+		}
+		t.conn.SetReadDeadline(deadline)
+		n, from, err := t.conn.ReadFrom(buf)
+		if err != nil {
+			break // Timeout or closed socket
+		}
+		received := time.Now()
+
+		var msg *icmpMessage
+		if t.v6 {
+			msg, err = parseICMPv6(buf[:n])
+		} else {
+			msg, err = parseICMPv4(buf[:n])
+		}
+		if err != nil {
+			continue
+		}
+
+		seq, ok := t.match(msg, from)
+		if !ok {
+			continue
+		}
+		sentAt, ok := pending[seq]
+		if !ok {
+			continue
+		}
+		delete(pending, seq)
+
+		replies = append(replies, hopReply{
+			seq:   seq,
+			from:  from.(*net.IPAddr).IP.String(),
+			rtt:   float64(received.Sub(sentAt).Microseconds()) / 1000.0,
+			typ:   msg.Type,
+			code:  msg.Code,
+			final: msg.isEchoReply() || msg.isDestUnreachable(),
+		})
+	}
+
+	sort.Slice(replies, func(i, j int) bool { return replies[i].seq < replies[j].seq })
+	return replies
+}
+
+// match returns the probe sequence number an ICMP message answers.
+func (t *nativeTrace) match(msg *icmpMessage, from net.Addr) (int, bool) {
+	if msg.isError() {
+		q := msg.Quoted
+		if !q.Dst.Equal(t.dst.IP) {
+			return 0, false
+		}
+		icmpProto := protoICMP
+		if t.v6 {
+			icmpProto = protoICMPv6
+		}
+		switch {
+		case t.udp == nil:
+			if q.Protocol == icmpProto && q.ID == t.id {
+				return q.Seq, true
+			}
+		case q.Protocol == protoUDP && q.SrcPort == t.srcPort:
+			if t.mode == TraceModeParis {
+				return (q.PayloadLen - 8 - parisBaseLen) / 2, true
+			}
+			return q.DstPort - traceBasePort, true
+		}
+		return 0, false
+	}
+
+	if t.udp == nil && msg.isEchoReply() && msg.ID == t.id {
+		if addr, ok := from.(*net.IPAddr); ok && addr.IP.Equal(t.dst.IP) {
+			return msg.Seq, true
+		}
+	}
+	return 0, false
+}
+
+// buildHop aggregates the replies for one TTL into a hop. It reports whether
+// the trace should stop because the destination answered or was unreachable.
+func buildHop(ttl int, replies []hopReply) (model.TraceHop, bool) {
+	hop := model.TraceHop{
+		HopNum: ttl,
+		Lost:   len(replies) == 0,
+	}
+
+	reached := false
+	counts := distinctResponders(replies)
+	for _, r := range replies {
+		if hop.IP == "" || counts[r.from] > counts[hop.IP] {
+			hop.IP = r.from
+		}
+		hop.RTTs = append(hop.RTTs, r.rtt)
+		hop.ICMPType = r.typ
+		hop.ICMPCode = r.code
+		if r.final {
+			reached = true
+		}
+	}
+	hop.LatencyMs = meanRTT(hop.RTTs)
+
+	for ip := range counts {
+		hop.HopSet = append(hop.HopSet, ip)
+	}
+	sort.Strings(hop.HopSet)
+
+	return hop, reached
+}
+
+// distinctResponders counts replies per responding address.
+func distinctResponders(replies []hopReply) map[string]int {
+	counts := make(map[string]int)
+	for _, r := range replies {
+		counts[r.from]++
+	}
+	return counts
+}
+
+// meanRTT returns the average of the given round-trip times.
+func meanRTT(rtts []float64) float64 {
+	if len(rtts) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, rtt := range rtts {
+		sum += rtt
+	}
+	return sum / float64(len(rtts))
+}
+
+// onesAdd adds two 16-bit words in one's complement arithmetic.
+func onesAdd(a, b uint16) uint16 {
+	sum := uint32(a) + uint32(b)
+	return uint16(sum&0xffff + sum>>16)
+}
@@ -3,15 +3,20 @@
 package probes
 
 import (
+	"strings"
 	"syscall"
 )
 
-// setTTL returns a control function to set TTL on Unix systems.
+// setTTL returns a control function to set the TTL (or IPv6 hop limit) on Unix systems.
 func setTTL(ttl int) func(network, address string, c syscall.RawConn) error {
 	return func(network, address string, c syscall.RawConn) error {
+		level, opt := syscall.IPPROTO_IP, syscall.IP_TTL
+		if strings.HasSuffix(network, "6") {
+			level, opt = syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS
+		}
 		var opErr error
 		err := c.Control(func(fd uintptr) {
-			opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
+			opErr = syscall.SetsockoptInt(int(fd), level, opt, ttl)
 		})
 		if err != nil {
 			return err
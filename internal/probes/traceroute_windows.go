@@ -3,17 +3,22 @@
 package probes
 
 import (
+	"strings"
 	"syscall"
 
 	"golang.org/x/sys/windows"
 )
 
-// setTTL returns a control function to set TTL on Windows.
+// setTTL returns a control function to set the TTL (or IPv6 hop limit) on Windows.
 func setTTL(ttl int) func(network, address string, c syscall.RawConn) error {
 	return func(network, address string, c syscall.RawConn) error {
+		level, opt := windows.IPPROTO_IP, windows.IP_TTL
+		if strings.HasSuffix(network, "6") {
+			level, opt = windows.IPPROTO_IPV6, windows.IPV6_UNICAST_HOPS
+		}
 		var opErr error
 		err := c.Control(func(fd uintptr) {
-			opErr = windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IP, windows.IP_TTL, ttl)
+			opErr = windows.SetsockoptInt(windows.Handle(fd), level, opt, ttl)
 		})
 		if err != nil {
 			return err
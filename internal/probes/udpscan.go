@@ -0,0 +1,178 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/user/netpulse/internal/dnsmsg"
+)
+
+// Port states.
+const (
+	PortOpen         = "open"
+	PortOpenFiltered = "open|filtered" // UDP port that neither answered nor refused
+	PortClosed       = "closed"
+)
+
+// UDP service names mapping.
+var udpServiceNames = map[int]string{
+	53: "dns", 67: "dhcp", 69: "tftp", 123: "ntp", 137: "netbios-ns",
+	161: "snmp", 500: "isakmp", 514: "syslog", 1194: "openvpn", 1900: "ssdp",
+	4500: "ipsec-nat-t", 5353: "mdns", 51820: "wireguard",
+}
+
+// snmpGetSysDescr is an SNMPv1 GetRequest for sysDescr.0 with community "public".
+var snmpGetSysDescr = []byte{
+	0x30, 0x26, 0x02, 0x01, 0x00, 0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+	0xa0, 0x19, 0x02, 0x01, 0x01, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00,
+	0x30, 0x0e, 0x30, 0x0c, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, 0x05, 0x00,
+}
+
+const ssdpSearch = "M-SEARCH * HTTP/1.1\r\n" +
+	"HOST: 239.255.255.250:1900\r\n" +
+	"MAN: \"ssdp:discover\"\r\n" +
+	"MX: 1\r\n" +
+	"ST: ssdp:all\r\n\r\n"
+
+// udpPayload returns the probe datagram for a port. Ports without a known
+// protocol get an empty datagram, which still draws ICMP port unreachable
+// from closed ports.
+func udpPayload(port int) []byte {
+	switch port {
+	case 53:
+		// version.bind CH TXT; servers that refuse it still answer
+		return dnsQuery(0x4e50, "version.bind", dnsmsg.TypeTXT, dnsmsg.ClassCHAOS)
+	case 123:
+		ntp := make([]byte, 48)
+		ntp[0] = 0x1b // LI 0, version 3, client mode
+		return ntp
+	case 161:
+		return snmpGetSysDescr
+	case 1900:
+		return []byte(ssdpSearch)
+	case 5353:
+		// Legacy unicast query, answered from port 5353 by responders
+		return dnsQuery(0, "_services._dns-sd._udp.local", dnsmsg.TypePTR, dnsmsg.ClassINET)
+	}
+	return nil
+}
+
+// dnsQuery builds a DNS query message with a single question.
+func dnsQuery(id uint16, name string, qtype, qclass uint16) []byte {
+	b, _ := dnsmsg.NewQuery(id, name, qtype, qclass).Pack()
+	return b
+}
+
+// scanUDPPort probes a UDP port. A reply means open, ICMP port unreachable
+// (reported as ECONNREFUSED on a connected socket) means closed, and silence
+// means open|filtered. A port that cannot be probed at all returns nil.
+func (s *PortScanner) scanUDPPort(ctx context.Context, host string, port int) *ScanResult {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+
+	conn, err := net.DialTimeout("udp", addr, s.timeout)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	result := &ScanResult{
+		Port:     port,
+		Protocol: "udp",
+		State:    PortOpenFiltered,
+		Service:  getUDPServiceName(port),
+	}
+
+	// Datagrams get lost, so try twice before settling on open|filtered
+	payload := udpPayload(port)
+	buf := make([]byte, 2048)
+	for attempt := 0; attempt < 2 && ctx.Err() == nil; attempt++ {
+		if _, err := conn.Write(payload); err != nil {
+			if isPortUnreachable(err) {
+				result.State = PortClosed
+				return result
+			}
+			continue
+		}
+
+		conn.SetReadDeadline(time.Now().Add(s.timeout / 2))
+		n, err := conn.Read(buf)
+		if err == nil {
+			result.State = PortOpen
+			result.Banner = udpBanner(port, buf[:n])
+			return result
+		}
+		if isPortUnreachable(err) {
+			result.State = PortClosed
+			return result
+		}
+	}
+
+	return result
+}
+
+// isPortUnreachable reports whether a UDP socket error was caused by an ICMP
+// port unreachable.
+func isPortUnreachable(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func getUDPServiceName(port int) string {
+	if name, ok := udpServiceNames[port]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// udpBanner summarizes a UDP reply.
+func udpBanner(port int, resp []byte) string {
+	switch port {
+	case 123:
+		if len(resp) >= 48 {
+			return fmt.Sprintf("NTPv%d stratum %d", resp[0]>>3&0x07, resp[1])
+		}
+	case 1900:
+		for _, line := range strings.Split(string(resp), "\r\n") {
+			if strings.HasPrefix(strings.ToUpper(line), "SERVER:") {
+				return strings.TrimSpace(line[len("SERVER:"):])
+			}
+		}
+	}
+	return printable(resp, 200)
+}
+
+// printable keeps the runs of printable ASCII in b, joined by spaces, up to
+// max bytes.
+func printable(b []byte, max int) string {
+	var sb strings.Builder
+	run := 0
+	for _, c := range b {
+		if c >= 0x20 && c < 0x7f {
+			sb.WriteByte(c)
+			run++
+		} else if run > 0 {
+			// Drop short fragments of binary data that happen to be printable
+			if run < 4 {
+				s := sb.String()
+				sb.Reset()
+				sb.WriteString(s[:len(s)-run])
+			} else {
+				sb.WriteByte(' ')
+			}
+			run = 0
+		}
+		if sb.Len() >= max {
+			break
+		}
+	}
+	if run > 0 && run < 4 {
+		s := sb.String()
+		return strings.TrimSpace(s[:len(s)-run])
+	}
+	return strings.TrimSpace(sb.String())
+}
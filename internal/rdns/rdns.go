@@ -0,0 +1,132 @@
+// Package rdns resolves the reverse DNS (PTR) names of addresses, with a
+// persistent cache and a limit on the lookups sent to the resolver.
+package rdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// How long cached names are reused. Addresses without a name are retried
+// sooner since operators add records over time.
+const (
+	NameTTL     = 7 * 24 * time.Hour
+	NoNameTTL   = 24 * time.Hour
+	lookupLimit = 2 * time.Second
+)
+
+// Cache persists PTR answers.
+type Cache interface {
+	// Get returns the entry for ip, or nil.
+	Get(ip string) (*model.PTRRecord, error)
+	Save(record *model.PTRRecord) error
+}
+
+// Resolver looks up PTR names, from the cache when fresh and otherwise
+// from the system resolver at no more than a set rate.
+type Resolver struct {
+	cache    Cache
+	interval time.Duration // Between uncached lookups
+	lookup   func(ctx context.Context, addr string) ([]string, error)
+
+	mu   sync.Mutex
+	next time.Time // Earliest start of the next uncached lookup
+}
+
+// New creates a resolver sending at most rate lookups per second.
+func New(cache Cache, rate int) *Resolver {
+	if rate <= 0 {
+		rate = 10
+	}
+	return &Resolver{
+		cache:    cache,
+		interval: time.Second / time.Duration(rate),
+		lookup:   net.DefaultResolver.LookupAddr,
+	}
+}
+
+// Lookup returns the name of ip, or "" if it has none. Temporary failures
+// are returned as errors and not cached.
+func (r *Resolver) Lookup(ctx context.Context, ip string) (string, error) {
+	if r.cache != nil {
+		if cached, err := r.cache.Get(ip); err == nil && cached != nil && fresh(cached) {
+			return cached.Hostname, nil
+		}
+	}
+
+	if err := r.wait(ctx); err != nil {
+		return "", err
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, lookupLimit)
+	names, err := r.lookup(lookupCtx, ip)
+	cancel()
+
+	var dnsErr *net.DNSError
+	if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		return "", err
+	}
+	name := ""
+	if len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".")
+	}
+	if r.cache != nil {
+		r.cache.Save(&model.PTRRecord{IP: ip, Hostname: name, UpdatedAt: time.Now()})
+	}
+	return name, nil
+}
+
+// LookupAll resolves every distinct address in turn and returns the names
+// found. It stops early when ctx is done.
+func (r *Resolver) LookupAll(ctx context.Context, ips []string) map[string]string {
+	names := make(map[string]string)
+	done := make(map[string]bool)
+	for _, ip := range ips {
+		if ip == "" || done[ip] {
+			continue
+		}
+		done[ip] = true
+		name, err := r.Lookup(ctx, ip)
+		if ctx.Err() != nil {
+			break
+		}
+		if err == nil && name != "" {
+			names[ip] = name
+		}
+	}
+	return names
+}
+
+// wait blocks until the next lookup may be sent.
+func (r *Resolver) wait(ctx context.Context) error {
+	r.mu.Lock()
+	now := time.Now()
+	start := r.next
+	if start.Before(now) {
+		start = now
+	}
+	r.next = start.Add(r.interval)
+	r.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func fresh(record *model.PTRRecord) bool {
+	ttl := NameTTL
+	if record.Hostname == "" {
+		ttl = NoNameTTL
+	}
+	return time.Since(record.UpdatedAt) < ttl
+}
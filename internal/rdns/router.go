@@ -0,0 +1,165 @@
+package rdns
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// interfacePattern matches interface names operators put first in router
+// names: ae1, xe-0-0-0, et-1-0-2, be2, hu0-0-0-1, gi0-1, bundle-ether10...
+var interfacePattern = regexp.MustCompile(`^(ae|xe|et|ge|te|be|hu|fo|gi|fa|po|so|lo|irb|eth|vlan|bond|ve|bundle-ether|tengige|hundredgige|port-channel)-?\d`)
+
+// devicePattern matches a router name: a role prefix, an optional dash and
+// a number ("cr1", "bbr01", "edge-2").
+var devicePattern = regexp.MustCompile(`^([a-z]+)-?(\d+)[a-z]?$`)
+
+// locationPattern matches a location code followed by a site number
+// ("fra1", "lhr25s34").
+var locationPattern = regexp.MustCompile(`^([a-z]+)\d*`)
+
+// roles maps device prefixes to the role of the router.
+var roles = map[string]string{
+	"cr": "core", "ccr": "core", "core": "core", "cor": "core", "p": "core",
+	"br": "border", "bbr": "border", "bdr": "border", "border": "border", "bb": "backbone",
+	"ir": "interconnect", "ix": "interconnect", "peer": "interconnect", "pr": "interconnect",
+	"er": "edge", "edge": "edge", "pe": "edge", "ce": "edge", "bng": "edge", "bras": "edge",
+	"ar": "aggregation", "agg": "aggregation", "agr": "aggregation", "dr": "aggregation", "dist": "aggregation",
+	"gw": "gateway", "gateway": "gateway", "rtr": "router", "rt": "router", "r": "router",
+	"rr": "route reflector", "lb": "load balancer", "fw": "firewall",
+}
+
+type location struct {
+	city    string
+	country string
+}
+
+// locations maps airport and metro codes, and city names, that operators use
+// in router names to the city.
+var locations = map[string]location{
+	// Europe
+	"ams": {"Amsterdam", "NL"}, "amsterdam": {"Amsterdam", "NL"},
+	"fra": {"Frankfurt", "DE"}, "frankfurt": {"Frankfurt", "DE"},
+	"lhr": {"London", "GB"}, "lon": {"London", "GB"}, "ldn": {"London", "GB"}, "london": {"London", "GB"},
+	"cdg": {"Paris", "FR"}, "par": {"Paris", "FR"}, "paris": {"Paris", "FR"},
+	"mad": {"Madrid", "ES"}, "madrid": {"Madrid", "ES"},
+	"mil": {"Milan", "IT"}, "mxp": {"Milan", "IT"}, "milan": {"Milan", "IT"},
+	"vie": {"Vienna", "AT"}, "vienna": {"Vienna", "AT"},
+	"zrh": {"Zurich", "CH"}, "zurich": {"Zurich", "CH"},
+	"arn": {"Stockholm", "SE"}, "sto": {"Stockholm", "SE"}, "stockholm": {"Stockholm", "SE"},
+	"cph": {"Copenhagen", "DK"}, "copenhagen": {"Copenhagen", "DK"},
+	"osl": {"Oslo", "NO"}, "hel": {"Helsinki", "FI"},
+	"dub": {"Dublin", "IE"}, "dublin": {"Dublin", "IE"},
+	"bru": {"Brussels", "BE"}, "brussels": {"Brussels", "BE"},
+	"muc": {"Munich", "DE"}, "munich": {"Munich", "DE"},
+	"ber": {"Berlin", "DE"}, "berlin": {"Berlin", "DE"},
+	"ham": {"Hamburg", "DE"}, "hamburg": {"Hamburg", "DE"},
+	"dus": {"Dusseldorf", "DE"}, "dusseldorf": {"Dusseldorf", "DE"},
+	"mrs": {"Marseille", "FR"}, "marseille": {"Marseille", "FR"},
+	"waw": {"Warsaw", "PL"}, "warsaw": {"Warsaw", "PL"},
+	"prg": {"Prague", "CZ"}, "prague": {"Prague", "CZ"},
+	"bud": {"Budapest", "HU"}, "lis": {"Lisbon", "PT"}, "ath": {"Athens", "GR"},
+	"sof": {"Sofia", "BG"}, "otp": {"Bucharest", "RO"}, "buh": {"Bucharest", "RO"},
+	"ist": {"Istanbul", "TR"}, "kbp": {"Kyiv", "UA"}, "iev": {"Kyiv", "UA"},
+	"mow": {"Moscow", "RU"}, "svo": {"Moscow", "RU"}, "led": {"Saint Petersburg", "RU"},
+	// North America
+	"iad": {"Ashburn", "US"}, "ash": {"Ashburn", "US"}, "ashburn": {"Ashburn", "US"},
+	"was": {"Washington", "US"}, "washington": {"Washington", "US"},
+	"nyc": {"New York", "US"}, "jfk": {"New York", "US"}, "lga": {"New York", "US"}, "newyork": {"New York", "US"},
+	"ewr": {"Newark", "US"}, "bos": {"Boston", "US"}, "phl": {"Philadelphia", "US"},
+	"atl": {"Atlanta", "US"}, "atlanta": {"Atlanta", "US"},
+	"mia": {"Miami", "US"}, "miami": {"Miami", "US"},
+	"ord": {"Chicago", "US"}, "chi": {"Chicago", "US"}, "chicago": {"Chicago", "US"},
+	"dfw": {"Dallas", "US"}, "dal": {"Dallas", "US"}, "dallas": {"Dallas", "US"},
+	"iah": {"Houston", "US"}, "hou": {"Houston", "US"},
+	"den": {"Denver", "US"}, "denver": {"Denver", "US"},
+	"phx": {"Phoenix", "US"}, "slc": {"Salt Lake City", "US"}, "las": {"Las Vegas", "US"},
+	"lax": {"Los Angeles", "US"}, "losangeles": {"Los Angeles", "US"},
+	"sjc": {"San Jose", "US"}, "sanjose": {"San Jose", "US"},
+	"sfo": {"San Francisco", "US"}, "pao": {"Palo Alto", "US"},
+	"sea": {"Seattle", "US"}, "seattle": {"Seattle", "US"},
+	"msp": {"Minneapolis", "US"},
+	"yyz": {"Toronto", "CA"}, "tor": {"Toronto", "CA"}, "toronto": {"Toronto", "CA"},
+	"yul": {"Montreal", "CA"}, "yvr": {"Vancouver", "CA"},
+	"mex": {"Mexico City", "MX"},
+	// South America
+	"gru": {"Sao Paulo", "BR"}, "sao": {"Sao Paulo", "BR"}, "saopaulo": {"Sao Paulo", "BR"},
+	"eze": {"Buenos Aires", "AR"}, "scl": {"Santiago", "CL"}, "bog": {"Bogota", "CO"}, "lim": {"Lima", "PE"},
+	// Asia and Oceania
+	"nrt": {"Tokyo", "JP"}, "hnd": {"Tokyo", "JP"}, "tyo": {"Tokyo", "JP"}, "tokyo": {"Tokyo", "JP"},
+	"kix": {"Osaka", "JP"}, "osa": {"Osaka", "JP"},
+	"icn": {"Seoul", "KR"}, "sel": {"Seoul", "KR"},
+	"hkg": {"Hong Kong", "HK"}, "hongkong": {"Hong Kong", "HK"},
+	"sin": {"Singapore", "SG"}, "singapore": {"Singapore", "SG"},
+	"tpe": {"Taipei", "TW"}, "bkk": {"Bangkok", "TH"}, "kul": {"Kuala Lumpur", "MY"},
+	"cgk": {"Jakarta", "ID"}, "jkt": {"Jakarta", "ID"}, "mnl": {"Manila", "PH"},
+	"bom": {"Mumbai", "IN"}, "mumbai": {"Mumbai", "IN"}, "del": {"Delhi", "IN"}, "maa": {"Chennai", "IN"},
+	"syd": {"Sydney", "AU"}, "sydney": {"Sydney", "AU"}, "mel": {"Melbourne", "AU"}, "bne": {"Brisbane", "AU"},
+	"akl": {"Auckland", "NZ"},
+	// Middle East and Africa
+	"dxb": {"Dubai", "AE"}, "tlv": {"Tel Aviv", "IL"}, "ruh": {"Riyadh", "SA"}, "jed": {"Jeddah", "SA"},
+	"doh": {"Doha", "QA"}, "bah": {"Manama", "BH"},
+	"jnb": {"Johannesburg", "ZA"}, "johannesburg": {"Johannesburg", "ZA"}, "cpt": {"Cape Town", "ZA"},
+	"cai": {"Cairo", "EG"}, "nbo": {"Nairobi", "KE"},
+}
+
+// ParseRouterName reads the interface, device, role and location out of a
+// router's reverse DNS name. It returns nil for an empty name.
+func ParseRouterName(hostname string) *model.RouterHint {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	if hostname == "" {
+		return nil
+	}
+
+	labels := strings.Split(hostname, ".")
+	labels = labels[:len(labels)-domainLabels(labels)]
+	hint := &model.RouterHint{Name: strings.Join(labels, ".")}
+	if hint.Name == "" {
+		hint.Name = hostname
+		return hint
+	}
+
+	for i, label := range labels {
+		if i == 0 && len(labels) > 1 && interfacePattern.MatchString(label) {
+			hint.Interface = label
+			continue
+		}
+		if hint.Device == "" {
+			if m := devicePattern.FindStringSubmatch(label); m != nil && roles[m[1]] != "" {
+				hint.Device, hint.Role = label, roles[m[1]]
+				continue
+			}
+		}
+		if hint.Location == "" {
+			for _, token := range strings.Split(label, "-") {
+				m := locationPattern.FindStringSubmatch(token)
+				if m == nil {
+					continue
+				}
+				if loc, ok := locations[m[1]]; ok {
+					hint.Location, hint.City, hint.Country = m[1], loc.city, loc.country
+					break
+				}
+			}
+		}
+	}
+	return hint
+}
+
+// domainLabels returns how many trailing labels make up the registered
+// domain: two ("example.net"), or three under a country's second-level
+// domain ("example.co.uk").
+func domainLabels(labels []string) int {
+	n := len(labels)
+	if n < 2 {
+		return 0
+	}
+	if n >= 3 && len(labels[n-1]) == 2 && len(labels[n-2]) <= 3 {
+		switch labels[n-2] {
+		case "co", "com", "net", "org", "ne", "or", "ac", "gov", "edu":
+			return 3
+		}
+	}
+	return 2
+}
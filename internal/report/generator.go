@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/user/netpulse/internal/model"
+	"github.com/user/netpulse/internal/probes"
 	"github.com/user/netpulse/internal/storage"
 	"github.com/user/netpulse/internal/util"
 )
@@ -34,6 +35,7 @@ type ReportData struct {
 	IPRecords     []model.IPRecord
 	IPChangeCount int
 	CurrentIP     *model.IPRecord
+	CurrentIPv6   *model.IPRecord
 	
 	// Trace Section
 	Traces         []model.TraceResult
@@ -45,6 +47,26 @@ type ReportData struct {
 	OpenPorts     []model.ScanPort
 	PortCount     int
 	
+	// Certificate Section
+	Certificates  []model.Certificate
+	CertAnomalies []model.Anomaly
+	CertExpiryDays int
+	
+	// HTTP Check Section
+	HTTPChecks []HTTPCheckStat
+	
+	// Throughput Section
+	Throughput  []ThroughputStat
+	Bufferbloat []BufferbloatStat
+	
+	// DNS Section
+	DNSAvailability []model.DNSAvailability
+	DNSAnomalies    []model.Anomaly
+	
+	// Path MTU Section
+	PMTU          []PMTUStat
+	PMTUAnomalies []model.Anomaly
+	
 	// Anomalies (simplified)
 	IPChanges      []IPChange
 	TraceChanges   []TraceChange
@@ -53,6 +75,7 @@ type ReportData struct {
 
 // IPChange represents an IP address change.
 type IPChange struct {
+	Family    string
 	OldIP     string
 	NewIP     string
 	Timestamp time.Time
@@ -68,6 +91,64 @@ type TraceChange struct {
 	Timestamp time.Time
 }
 
+// HTTPCheckStat summarizes one HTTP check over the report period. Timings
+// are averaged over successful checks.
+type HTTPCheckStat struct {
+	Name         string
+	URL          string
+	Checks       int
+	Failures     int
+	Availability float64
+	AvgDNSMs     float64
+	AvgConnectMs float64
+	AvgTLSMs     float64
+	AvgTTFBMs    float64
+	AvgTotalMs   float64
+	LastError    string
+}
+
+// ThroughputStat summarizes one throughput test (server, protocol and
+// direction) over the report period. Averages cover successful tests.
+type ThroughputStat struct {
+	Server         string
+	Protocol       string
+	Direction      string
+	Tests          int
+	Failures       int
+	AvgMbps        float64
+	MinMbps        float64
+	MaxMbps        float64
+	AvgRetransmits float64
+	AvgLossPct     float64
+	AvgJitterMs    float64
+}
+
+// BufferbloatStat summarizes the latency-under-load tests of one target.
+// Averages cover successful tests.
+type BufferbloatStat struct {
+	Target        string
+	Tests         int
+	Failures      int
+	LatestGrade   string
+	WorstGrade    string
+	AvgIdleMs     float64
+	AvgDownloadMs float64
+	AvgUploadMs   float64
+	MaxIncreaseMs float64
+}
+
+// PMTUStat summarizes the path MTU results of one target.
+type PMTUStat struct {
+	Target     string
+	Checks     int
+	Failures   int
+	LatestPMTU int
+	MinPMTU    int
+	LocalMTU   int
+	ReportedBy string // Router behind the smallest reported MTU
+	BlackHoles int    // Checks where larger packets vanished silently
+}
+
 // PortChange represents a port state change.
 type PortChange struct {
 	Host      string
@@ -100,6 +181,9 @@ func (g *Generator) Generate(opts model.ReportOptions) (*ReportData, error) {
 	if err == nil {
 		data.CurrentIP = current
 	}
+	if current, err := ipStorage.GetLatestByFamily("ipv6"); err == nil && current != nil {
+		data.CurrentIPv6 = current
+	}
 	
 	// Calculate IP changes
 	data.IPChanges = g.detectIPChanges(records)
@@ -145,19 +229,238 @@ func (g *Generator) Generate(opts model.ReportOptions) (*ReportData, error) {
 	}
 	data.PortCount = len(data.OpenPorts)
 	
+	// Get certificate inventory and the alerts raised in the period
+	certs, err := storage.NewCertStorage(g.db).GetAll()
+	if err == nil {
+		data.Certificates = certs
+	}
+	certAnomalies, err := storage.NewAnomalyStorage(g.db).GetSince(opts.Since, "cert_")
+	if err == nil {
+		data.CertAnomalies = certAnomalies
+	}
+	data.CertExpiryDays = g.config.CertExpiryDays
+	
+	// Get HTTP check results
+	checks, err := storage.NewHTTPCheckStorage(g.db).GetHistory("", opts.Since)
+	if err == nil {
+		data.HTTPChecks = summarizeHTTPChecks(checks)
+	}
+	
+	// Get throughput test results
+	tests, err := storage.NewThroughputStorage(g.db).GetHistory("", opts.Since)
+	if err == nil {
+		data.Throughput = summarizeThroughput(tests)
+	}
+	bloat, err := storage.NewBufferbloatStorage(g.db).GetHistory("", opts.Since)
+	if err == nil {
+		data.Bufferbloat = summarizeBufferbloat(bloat)
+	}
+	
+	// Get DNS resolver availability
+	until := opts.Until
+	if until.IsZero() {
+		until = time.Now()
+	}
+	dnsAvailability, err := g.db.GetDNSAvailability(opts.Since, until)
+	if err == nil {
+		data.DNSAvailability = dnsAvailability
+	}
+	dnsAnomalies, err := storage.NewAnomalyStorage(g.db).GetSince(opts.Since, "dns_")
+	if err == nil {
+		data.DNSAnomalies = dnsAnomalies
+	}
+	
+	// Get path MTU results and alerts
+	pmtu, err := storage.NewPMTUStorage(g.db).GetHistory("", opts.Since)
+	if err == nil {
+		data.PMTU = summarizePMTU(pmtu)
+	}
+	pmtuAnomalies, err := storage.NewAnomalyStorage(g.db).GetSince(opts.Since, "pmtu_")
+	if err == nil {
+		data.PMTUAnomalies = pmtuAnomalies
+	}
+	
 	return data, nil
 }
 
+// summarizeThroughput aggregates test results (oldest first) per server,
+// protocol and direction.
+func summarizeThroughput(results []model.ThroughputResult) []ThroughputStat {
+	var stats []ThroughputStat
+	index := make(map[string]int)
+	
+	for _, r := range results {
+		key := r.Server + " " + r.Protocol + " " + r.Direction
+		i, ok := index[key]
+		if !ok {
+			i = len(stats)
+			index[key] = i
+			stats = append(stats, ThroughputStat{Server: r.Server, Protocol: r.Protocol, Direction: r.Direction})
+		}
+		s := &stats[i]
+		s.Tests++
+		if !r.Success {
+			s.Failures++
+			continue
+		}
+		if s.Tests-s.Failures == 1 || r.Mbps < s.MinMbps {
+			s.MinMbps = r.Mbps
+		}
+		if r.Mbps > s.MaxMbps {
+			s.MaxMbps = r.Mbps
+		}
+		s.AvgMbps += r.Mbps
+		s.AvgRetransmits += float64(r.Retransmits)
+		s.AvgLossPct += r.LossPct
+		s.AvgJitterMs += r.JitterMs
+	}
+	
+	for i := range stats {
+		s := &stats[i]
+		if ok := float64(s.Tests - s.Failures); ok > 0 {
+			s.AvgMbps /= ok
+			s.AvgRetransmits /= ok
+			s.AvgLossPct /= ok
+			s.AvgJitterMs /= ok
+		}
+	}
+	
+	return stats
+}
+
+// summarizeBufferbloat aggregates test results (oldest first) per target.
+func summarizeBufferbloat(results []model.BufferbloatResult) []BufferbloatStat {
+	var stats []BufferbloatStat
+	index := make(map[string]int)
+	
+	for _, r := range results {
+		i, ok := index[r.Target]
+		if !ok {
+			i = len(stats)
+			index[r.Target] = i
+			stats = append(stats, BufferbloatStat{Target: r.Target})
+		}
+		s := &stats[i]
+		s.Tests++
+		if !r.Success {
+			s.Failures++
+			continue
+		}
+		s.LatestGrade = r.Grade
+		if r.IncreaseMs >= s.MaxIncreaseMs {
+			s.MaxIncreaseMs = r.IncreaseMs
+			s.WorstGrade = r.Grade
+		}
+		s.AvgIdleMs += r.IdleMs
+		s.AvgDownloadMs += r.DownloadMs
+		s.AvgUploadMs += r.UploadMs
+	}
+	
+	for i := range stats {
+		s := &stats[i]
+		if ok := float64(s.Tests - s.Failures); ok > 0 {
+			s.AvgIdleMs /= ok
+			s.AvgDownloadMs /= ok
+			s.AvgUploadMs /= ok
+		}
+	}
+	
+	return stats
+}
+
+// summarizePMTU aggregates path MTU results (oldest first) per target.
+func summarizePMTU(results []model.PMTUResult) []PMTUStat {
+	var stats []PMTUStat
+	index := make(map[string]int)
+	
+	for _, r := range results {
+		i, ok := index[r.Target]
+		if !ok {
+			i = len(stats)
+			index[r.Target] = i
+			stats = append(stats, PMTUStat{Target: r.Target})
+		}
+		s := &stats[i]
+		s.Checks++
+		if !r.Success {
+			s.Failures++
+			continue
+		}
+		s.LatestPMTU = r.PMTU
+		s.LocalMTU = r.LocalMTU
+		if s.MinPMTU == 0 || r.PMTU < s.MinPMTU {
+			s.MinPMTU = r.PMTU
+			s.ReportedBy = r.ReportedBy
+		}
+		if r.BlackHole {
+			s.BlackHoles++
+		}
+	}
+	
+	return stats
+}
+
+// summarizeHTTPChecks aggregates check results (oldest first) per check.
+func summarizeHTTPChecks(results []model.HTTPCheckResult) []HTTPCheckStat {
+	var stats []HTTPCheckStat
+	index := make(map[string]int)
+	
+	for _, r := range results {
+		i, ok := index[r.Name]
+		if !ok {
+			i = len(stats)
+			index[r.Name] = i
+			stats = append(stats, HTTPCheckStat{Name: r.Name})
+		}
+		s := &stats[i]
+		s.URL = r.URL
+		s.Checks++
+		if !r.Success {
+			s.Failures++
+			s.LastError = r.Error
+			continue
+		}
+		s.AvgDNSMs += r.DNSMs
+		s.AvgConnectMs += r.ConnectMs
+		s.AvgTLSMs += r.TLSMs
+		s.AvgTTFBMs += r.TTFBMs
+		s.AvgTotalMs += r.TotalMs
+	}
+	
+	for i := range stats {
+		s := &stats[i]
+		s.Availability = float64(s.Checks-s.Failures) / float64(s.Checks) * 100
+		if ok := float64(s.Checks - s.Failures); ok > 0 {
+			s.AvgDNSMs /= ok
+			s.AvgConnectMs /= ok
+			s.AvgTLSMs /= ok
+			s.AvgTTFBMs /= ok
+			s.AvgTotalMs /= ok
+		}
+	}
+	
+	return stats
+}
+
 func (g *Generator) detectIPChanges(records []model.IPRecord) []IPChange {
 	var changes []IPChange
 	
+	// IPv4 and IPv6 are tracked separately, so compare each record with the
+	// previous one of the same family
 	for i := 0; i < len(records)-1; i++ {
-		if records[i].IP != records[i+1].IP {
-			changes = append(changes, IPChange{
-				OldIP:     records[i+1].IP,
-				NewIP:     records[i].IP,
-				Timestamp: records[i].Timestamp,
-			})
+		for j := i + 1; j < len(records); j++ {
+			if records[j].Family != records[i].Family {
+				continue
+			}
+			if records[i].IP != records[j].IP {
+				changes = append(changes, IPChange{
+					Family:    records[i].Family,
+					OldIP:     records[j].IP,
+					NewIP:     records[i].IP,
+					Timestamp: records[i].Timestamp,
+				})
+			}
+			break
 		}
 	}
 	
@@ -169,13 +472,18 @@ func (g *Generator) detectTraceChanges(target string, traces []model.TraceResult
 	
 	for i := 0; i < len(traces)-1; i++ {
 		curr := traces[i]
-		prev := traces[i+1]
+		
+		// Only compare against the previous trace taken in the same mode
+		prev, ok := previousTrace(traces[i+1:], curr.Mode)
+		if !ok {
+			continue
+		}
 		
 		currHops := getHopIPs(curr.Hops)
 		prevHops := getHopIPs(prev.Hops)
 		
-		if !equalHops(currHops, prevHops) {
-			added, removed := diffHops(prevHops, currHops)
+		if probes.RouteChanged(prev.Hops, curr.Hops) {
+			added, removed := diffHops(getHopSetIPs(prev.Hops), getHopSetIPs(curr.Hops))
 			changes = append(changes, TraceChange{
 				Target:    target,
 				OldHops:   prevHops,
@@ -190,6 +498,24 @@ func (g *Generator) detectTraceChanges(target string, traces []model.TraceResult
 	return changes
 }
 
+// previousTrace returns the most recent trace taken in the given mode.
+func previousTrace(traces []model.TraceResult, mode string) (model.TraceResult, bool) {
+	for _, t := range traces {
+		if t.Mode == mode {
+			return t, true
+		}
+	}
+	return model.TraceResult{}, false
+}
+
+func getHopSetIPs(hops []model.TraceHop) []string {
+	var ips []string
+	for _, hop := range hops {
+		ips = append(ips, probes.HopAddresses(hop)...)
+	}
+	return ips
+}
+
 func getHopIPs(hops []model.TraceHop) []string {
 	ips := make([]string, 0, len(hops))
 	for _, hop := range hops {
@@ -200,18 +526,6 @@ func getHopIPs(hops []model.TraceHop) []string {
 	return ips
 }
 
-func equalHops(a, b []string) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	for i := range a {
-		if a[i] != b[i] {
-			return false
-		}
-	}
-	return true
-}
-
 func diffHops(old, new []string) (added, removed []string) {
 	oldSet := make(map[string]bool)
 	newSet := make(map[string]bool)
@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
 )
 
 // FormatMarkdown formats the report data as Markdown.
@@ -27,6 +31,9 @@ func FormatMarkdown(data *ReportData) string {
 		sb.WriteString(fmt.Sprintf("| Current IP | `%s` |\n", data.CurrentIP.IP))
 		sb.WriteString(fmt.Sprintf("| ISP | %s |\n", data.CurrentIP.ISP))
 	}
+	if data.CurrentIPv6 != nil {
+		sb.WriteString(fmt.Sprintf("| Current IPv6 | `%s` |\n", data.CurrentIPv6.IP))
+	}
 	sb.WriteString(fmt.Sprintf("| IP Changes | %d |\n", data.IPChangeCount))
 	sb.WriteString(fmt.Sprintf("| Trace Changes | %d |\n", len(data.TraceChanges)))
 	sb.WriteString(fmt.Sprintf("| Alive Hosts | %d |\n", data.AliveCount))
@@ -37,11 +44,11 @@ func FormatMarkdown(data *ReportData) string {
 	sb.WriteString("## IP Address History\n\n")
 	if len(data.IPChanges) > 0 {
 		sb.WriteString("### IP Changes Detected\n\n")
-		sb.WriteString("| Time | Old IP | New IP |\n")
-		sb.WriteString("|------|--------|--------|\n")
+		sb.WriteString("| Time | Family | Old IP | New IP |\n")
+		sb.WriteString("|------|--------|--------|--------|\n")
 		for _, change := range data.IPChanges {
-			sb.WriteString(fmt.Sprintf("| %s | `%s` | `%s` |\n",
-				change.Timestamp.Format("01-02 15:04"),
+			sb.WriteString(fmt.Sprintf("| %s | %s | `%s` | `%s` |\n",
+				change.Timestamp.Format("01-02 15:04"), change.Family,
 				change.OldIP, change.NewIP))
 		}
 		sb.WriteString("\n")
@@ -88,15 +95,26 @@ func FormatMarkdown(data *ReportData) string {
 	
 	if len(data.AliveHosts) > 0 {
 		sb.WriteString("### Alive Hosts\n\n")
-		sb.WriteString("| IP | Hostname | Latency |\n")
-		sb.WriteString("|----|----------|--------|\n")
+		sb.WriteString("| IP | Hostname | MAC | Vendor | Services | Latency |\n")
+		sb.WriteString("|----|----------|-----|--------|----------|--------|\n")
 		for _, host := range data.AliveHosts {
 			hostname := host.Hostname
 			if hostname == "" {
 				hostname = "-"
 			}
-			sb.WriteString(fmt.Sprintf("| `%s` | %s | %.1f ms |\n",
-				host.IP, hostname, host.LatencyMs))
+			mac, vendor := host.MAC, host.Vendor
+			if mac == "" {
+				mac = "-"
+			}
+			if vendor == "" {
+				vendor = "-"
+			}
+			services := strings.Join(host.Services, ", ")
+			if services == "" {
+				services = "-"
+			}
+			sb.WriteString(fmt.Sprintf("| `%s` | %s | %s | %s | %s | %.1f ms |\n",
+				host.IP, hostname, mac, vendor, services, host.LatencyMs))
 		}
 		sb.WriteString("\n")
 	} else {
@@ -107,18 +125,159 @@ func FormatMarkdown(data *ReportData) string {
 	if len(data.OpenPorts) > 0 {
 		sb.WriteString("### Open Ports Summary\n\n")
 		
-		// Count ports by service
-		serviceCounts := make(map[string]int)
+		// Count ports by service, protocol and state
+		type serviceKey struct{ service, protocol, state string }
+		serviceCounts := make(map[serviceKey]int)
+		var keys []serviceKey
 		for _, port := range data.OpenPorts {
-			serviceCounts[port.Service]++
+			key := serviceKey{port.Service, port.Protocol, port.State}
+			if serviceCounts[key] == 0 {
+				keys = append(keys, key)
+			}
+			serviceCounts[key]++
+		}
+		
+		sb.WriteString("| Service | Protocol | State | Count |\n")
+		sb.WriteString("|---------|----------|-------|-------|\n")
+		for _, key := range keys {
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s | %d |\n", key.service, key.protocol, key.state, serviceCounts[key]))
+		}
+		sb.WriteString("\n")
+	}
+	
+	// HTTP Check Section
+	if len(data.HTTPChecks) > 0 {
+		sb.WriteString("## HTTP Checks\n\n")
+		sb.WriteString("| Check | Availability | DNS | Connect | TLS | TTFB | Total | Last Error |\n")
+		sb.WriteString("|-------|--------------|-----|---------|-----|------|-------|------------|\n")
+		for _, c := range data.HTTPChecks {
+			lastErr := c.LastError
+			if lastErr == "" {
+				lastErr = "-"
+			}
+			sb.WriteString(fmt.Sprintf("| %s | %.1f%% (%d/%d) | %.1f ms | %.1f ms | %.1f ms | %.1f ms | %.1f ms | %s |\n",
+				c.Name, c.Availability, c.Checks-c.Failures, c.Checks,
+				c.AvgDNSMs, c.AvgConnectMs, c.AvgTLSMs, c.AvgTTFBMs, c.AvgTotalMs, lastErr))
+		}
+		sb.WriteString("\n")
+	}
+	
+	// Throughput Section
+	if len(data.Throughput) > 0 {
+		sb.WriteString("## Throughput\n\n")
+		sb.WriteString("| Server | Test | Tests | Avg | Min | Max | Retransmits | Loss | Jitter |\n")
+		sb.WriteString("|--------|------|-------|-----|-----|-----|-------------|------|--------|\n")
+		for _, t := range data.Throughput {
+			retrans, loss, jitter := "-", "-", "-"
+			if t.Protocol == "udp" {
+				loss = fmt.Sprintf("%.2f%%", t.AvgLossPct)
+				jitter = fmt.Sprintf("%.2f ms", t.AvgJitterMs)
+			} else {
+				retrans = fmt.Sprintf("%.0f", t.AvgRetransmits)
+			}
+			sb.WriteString(fmt.Sprintf("| `%s` | %s %s | %d (%d failed) | %.1f Mbps | %.1f Mbps | %.1f Mbps | %s | %s | %s |\n",
+				t.Server, strings.ToUpper(t.Protocol), t.Direction, t.Tests, t.Failures,
+				t.AvgMbps, t.MinMbps, t.MaxMbps, retrans, loss, jitter))
+		}
+		sb.WriteString("\n")
+	}
+	
+	// Bufferbloat Section
+	if len(data.Bufferbloat) > 0 {
+		sb.WriteString("## Latency Under Load\n\n")
+		sb.WriteString("| Target | Tests | Latest Grade | Worst Grade | Idle | Downloading | Uploading | Max Increase |\n")
+		sb.WriteString("|--------|-------|--------------|-------------|------|-------------|-----------|--------------|\n")
+		for _, b := range data.Bufferbloat {
+			latest, worst := b.LatestGrade, b.WorstGrade
+			if latest == "" {
+				latest, worst = "-", "-"
+			}
+			sb.WriteString(fmt.Sprintf("| `%s` | %d (%d failed) | %s | %s | %.1f ms | %.1f ms | %.1f ms | +%.1f ms |\n",
+				b.Target, b.Tests, b.Failures, latest, worst,
+				b.AvgIdleMs, b.AvgDownloadMs, b.AvgUploadMs, b.MaxIncreaseMs))
+		}
+		sb.WriteString("\n")
+	}
+	
+	// DNS Section
+	if len(data.DNSAvailability) > 0 || len(data.DNSAnomalies) > 0 {
+		sb.WriteString("## DNS Resolvers\n\n")
+		if len(data.DNSAvailability) > 0 {
+			sb.WriteString("| Resolver | Transport | Availability | Avg Latency | Errors |\n")
+			sb.WriteString("|----------|-----------|--------------|-------------|--------|\n")
+			for _, a := range data.DNSAvailability {
+				sb.WriteString(fmt.Sprintf("| %s | %s | %.2f%% (%d/%d) | %.0f ms | %s |\n",
+					a.Server, strings.ToUpper(a.Protocol), a.AvailabilityPct, a.Checks-a.Failures, a.Checks,
+					a.AvgLatencyMs, formatErrorClasses(a.Errors)))
+			}
+			sb.WriteString("\n")
 		}
 		
-		sb.WriteString("| Service | Count |\n")
-		sb.WriteString("|---------|-------|\n")
-		for service, count := range serviceCounts {
-			sb.WriteString(fmt.Sprintf("| %s | %d |\n", service, count))
+		if len(data.DNSAnomalies) > 0 {
+			sb.WriteString("### DNS Integrity Alerts\n\n")
+			sb.WriteString("| Time | Severity | Alert |\n")
+			sb.WriteString("|------|----------|-------|\n")
+			for _, a := range data.DNSAnomalies {
+				sb.WriteString(fmt.Sprintf("| %s | %s | %s |\n",
+					a.Timestamp.Format("01-02 15:04"), a.Severity, a.Description))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	
+	// Path MTU Section
+	if len(data.PMTU) > 0 {
+		sb.WriteString("## Path MTU\n\n")
+		sb.WriteString("| Target | Checks | Latest | Lowest | Interface | Reported By | Black Holes |\n")
+		sb.WriteString("|--------|--------|--------|--------|-----------|-------------|-------------|\n")
+		for _, p := range data.PMTU {
+			latest, lowest, local, reportedBy := "-", "-", "-", "-"
+			if p.LatestPMTU > 0 {
+				latest, lowest = fmt.Sprintf("%d", p.LatestPMTU), fmt.Sprintf("%d", p.MinPMTU)
+				local = fmt.Sprintf("%d", p.LocalMTU)
+			}
+			if p.ReportedBy != "" {
+				reportedBy = fmt.Sprintf("`%s`", p.ReportedBy)
+			}
+			sb.WriteString(fmt.Sprintf("| `%s` | %d (%d failed) | %s | %s | %s | %s | %d |\n",
+				p.Target, p.Checks, p.Failures, latest, lowest, local, reportedBy, p.BlackHoles))
 		}
 		sb.WriteString("\n")
+		
+		if len(data.PMTUAnomalies) > 0 {
+			sb.WriteString("### Path MTU Alerts\n\n")
+			sb.WriteString("| Time | Severity | Alert |\n")
+			sb.WriteString("|------|----------|-------|\n")
+			for _, a := range data.PMTUAnomalies {
+				sb.WriteString(fmt.Sprintf("| %s | %s | %s |\n",
+					a.Timestamp.Format("01-02 15:04"), a.Severity, a.Description))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	
+	// Certificate Section
+	if len(data.Certificates) > 0 {
+		sb.WriteString("## TLS Certificates\n\n")
+		sb.WriteString("| Endpoint | Subject | Issuer | Key | Expires | Status |\n")
+		sb.WriteString("|----------|---------|--------|-----|---------|--------|\n")
+		for _, cert := range data.Certificates {
+			sb.WriteString(fmt.Sprintf("| `%s:%d` | %s | %s | %s | %s | %s |\n",
+				cert.IP, cert.Port, cert.Subject, cert.Issuer, cert.KeyType,
+				cert.NotAfter.Format("2006-01-02"), certStatus(cert, data.GeneratedAt, data.CertExpiryDays)))
+		}
+		sb.WriteString("\n")
+		
+		if len(data.CertAnomalies) > 0 {
+			sb.WriteString("### Certificate Alerts\n\n")
+			sb.WriteString("| Time | Severity | Alert |\n")
+			sb.WriteString("|------|----------|-------|\n")
+			for _, a := range data.CertAnomalies {
+				sb.WriteString(fmt.Sprintf("| %s | %s | %s |\n",
+					a.Timestamp.Format("01-02 15:04"), a.Severity, a.Description))
+			}
+			sb.WriteString("\n")
+		}
 	}
 	
 	// Footer
@@ -128,6 +287,43 @@ func FormatMarkdown(data *ReportData) string {
 	return sb.String()
 }
 
+// certStatus summarizes expiry and trust problems of a certificate.
+func certStatus(cert model.Certificate, now time.Time, expiryDays int) string {
+	var status []string
+	daysLeft := int(cert.NotAfter.Sub(now).Hours() / 24)
+	switch {
+	case now.After(cert.NotAfter):
+		status = append(status, "expired")
+	case daysLeft <= expiryDays:
+		status = append(status, fmt.Sprintf("expires in %d days", daysLeft))
+	}
+	if cert.SelfSigned {
+		status = append(status, "self-signed")
+	} else if !cert.ChainValid {
+		status = append(status, "untrusted chain")
+	}
+	if len(status) == 0 {
+		return "ok"
+	}
+	return strings.Join(status, ", ")
+}
+
+// formatErrorClasses lists failure counts by class, e.g. "timeout ×3".
+func formatErrorClasses(errors map[string]int) string {
+	if len(errors) == 0 {
+		return "-"
+	}
+	classes := make([]string, 0, len(errors))
+	for class := range errors {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for i, class := range classes {
+		classes[i] = fmt.Sprintf("%s ×%d", class, errors[class])
+	}
+	return strings.Join(classes, ", ")
+}
+
 func formatHops(hops []string) []string {
 	formatted := make([]string, len(hops))
 	for i, hop := range hops {
@@ -187,9 +187,11 @@ func shortenIP(ip string) string {
 	return ip
 }
 
+var nodeIDReplacer = strings.NewReplacer(".", "_", ":", "_", "%", "_")
+
 func ipToNodeID(ip string) string {
-	// Convert IP to valid Mermaid node ID
-	return "N" + strings.ReplaceAll(ip, ".", "_")
+	// Convert IP to valid Mermaid node ID (IPv6 colons and zones too)
+	return "N" + nodeIDReplacer.Replace(ip)
 }
 
 func containsHop(hops []model.TraceHop, ip string) bool {
@@ -0,0 +1,93 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily time range, optionally limited to some days of the
+// week, such as "09:00-17:00 mon-fri". A window whose end is not after
+// its start runs past midnight ("22:00-06:00"); the days are those it
+// starts on.
+type Window struct {
+	spec       string
+	start, end int   // Minutes since midnight
+	days       uint8 // Weekdays, bit 0 is Sunday
+}
+
+// ParseWindow parses "HH:MM-HH:MM" followed by optional days: a list or
+// range of day names ("sat,sun", "mon-fri").
+func ParseWindow(spec string) (Window, error) {
+	w := Window{spec: strings.TrimSpace(spec), days: 0x7f}
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return w, fmt.Errorf("window %q: expected \"HH:MM-HH:MM [days]\"", spec)
+	}
+
+	from, to, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return w, fmt.Errorf("window %q: expected \"HH:MM-HH:MM\"", spec)
+	}
+	var err error
+	if w.start, err = parseClock(from); err != nil {
+		return w, fmt.Errorf("window %q: %w", spec, err)
+	}
+	if w.end, err = parseClock(to); err != nil {
+		return w, fmt.Errorf("window %q: %w", spec, err)
+	}
+
+	if len(fields) == 2 {
+		days, err := parseField(fields[1], 0, 7, dayNames)
+		if err != nil {
+			return w, fmt.Errorf("window %q: days: %w", spec, err)
+		}
+		if days.has(7) {
+			days |= 1
+		}
+		w.days = uint8(days & 0x7f)
+	}
+	return w, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight. "24:00" is
+// accepted as the end of the day.
+func parseClock(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// End returns the end of the occurrence of the window that contains t, and
+// whether there is one.
+func (w Window) End(t time.Time) (time.Time, bool) {
+	// An occurrence containing t started today or, past midnight, yesterday
+	for _, offset := range []int{0, -1} {
+		day := time.Date(t.Year(), t.Month(), t.Day()+offset, 0, 0, 0, 0, t.Location())
+		if w.days&(1<<uint(day.Weekday())) == 0 {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, w.start, 0, 0, t.Location())
+		end := time.Date(day.Year(), day.Month(), day.Day(), 0, w.end, 0, 0, t.Location())
+		if w.end <= w.start {
+			end = end.AddDate(0, 0, 1)
+		}
+		if !t.Before(start) && t.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Contains reports whether t falls inside the window.
+func (w Window) Contains(t time.Time) bool {
+	_, ok := w.End(t)
+	return ok
+}
+
+// String returns the window as written.
+func (w Window) String() string {
+	return w.spec
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// AnomalyStorage handles anomaly persistence.
+type AnomalyStorage struct {
+	db *DB
+}
+
+// NewAnomalyStorage creates a new anomaly storage handler.
+func NewAnomalyStorage(db *DB) *AnomalyStorage {
+	return &AnomalyStorage{db: db}
+}
+
+// Save stores an anomaly.
+func (s *AnomalyStorage) Save(anomaly *model.Anomaly) error {
+	if anomaly.Timestamp.IsZero() {
+		anomaly.Timestamp = time.Now()
+	}
+
+	query := `INSERT INTO anomalies (type, description, severity, timestamp, data) 
+			  VALUES (?, ?, ?, ?, ?)`
+
+	result, err := s.db.Exec(query,
+		anomaly.Type, anomaly.Description, anomaly.Severity, anomaly.Timestamp, anomaly.Data)
+	if err != nil {
+		return fmt.Errorf("failed to insert anomaly: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	anomaly.ID = id
+
+	return nil
+}
+
+// Exists reports whether an anomaly with the same type and data has been
+// recorded since a given time. Jobs use it to raise a condition only once.
+// A zero time searches the whole history, so the data must then tell a new
+// occurrence from an old one, e.g. by carrying its timestamp.
+func (s *AnomalyStorage) Exists(anomalyType, data string, since time.Time) (bool, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM anomalies WHERE type = ? AND data = ? AND timestamp >= ?`,
+		anomalyType, data, since).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to query anomalies: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetSince returns anomalies recorded since the given time, newest first.
+// An empty type prefix returns all types.
+func (s *AnomalyStorage) GetSince(since time.Time, typePrefix string) ([]model.Anomaly, error) {
+	query := `SELECT id, type, COALESCE(description, ''), COALESCE(severity, 'info'), timestamp, COALESCE(data, '') 
+			  FROM anomalies WHERE timestamp >= ? AND type LIKE ? ORDER BY timestamp DESC`
+
+	rows, err := s.db.Query(query, since, typePrefix+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query anomalies: %w", err)
+	}
+	defer rows.Close()
+
+	var anomalies []model.Anomaly
+	for rows.Next() {
+		var a model.Anomaly
+		if err := rows.Scan(&a.ID, &a.Type, &a.Description, &a.Severity, &a.Timestamp, &a.Data); err != nil {
+			return nil, fmt.Errorf("failed to scan anomaly: %w", err)
+		}
+		anomalies = append(anomalies, a)
+	}
+
+	return anomalies, rows.Err()
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// BufferbloatStorage handles latency-under-load test persistence.
+type BufferbloatStorage struct {
+	db *DB
+}
+
+// NewBufferbloatStorage creates a new bufferbloat storage handler.
+func NewBufferbloatStorage(db *DB) *BufferbloatStorage {
+	return &BufferbloatStorage{db: db}
+}
+
+// Save stores a bufferbloat test result.
+func (s *BufferbloatStorage) Save(result *model.BufferbloatResult) error {
+	query := `INSERT INTO bufferbloat_tests (target, server, idle_ms, download_ms, upload_ms, 
+			  download_mbps, upload_mbps, increase_ms, grade, success, error, timestamp) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	res, err := s.db.Exec(query,
+		result.Target, result.Server, result.IdleMs, result.DownloadMs, result.UploadMs,
+		result.DownloadMbps, result.UploadMbps, result.IncreaseMs, result.Grade,
+		result.Success, result.Error, result.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to insert bufferbloat test: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	result.ID = id
+
+	return nil
+}
+
+// GetHistory returns test results since the given time, oldest first. An
+// empty target returns every target.
+func (s *BufferbloatStorage) GetHistory(target string, since time.Time) ([]model.BufferbloatResult, error) {
+	if target == "" {
+		return s.query(`WHERE timestamp >= ? ORDER BY timestamp ASC`, since)
+	}
+	return s.query(`WHERE target = ? AND timestamp >= ? ORDER BY timestamp ASC`, target, since)
+}
+
+func (s *BufferbloatStorage) query(where string, args ...interface{}) ([]model.BufferbloatResult, error) {
+	query := `SELECT id, target, server, COALESCE(idle_ms, 0), COALESCE(download_ms, 0), 
+			  COALESCE(upload_ms, 0), COALESCE(download_mbps, 0), COALESCE(upload_mbps, 0), 
+			  COALESCE(increase_ms, 0), COALESCE(grade, ''), success, COALESCE(error, ''), timestamp 
+			  FROM bufferbloat_tests ` + where
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bufferbloat tests: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.BufferbloatResult
+	for rows.Next() {
+		var r model.BufferbloatResult
+		if err := rows.Scan(&r.ID, &r.Target, &r.Server, &r.IdleMs, &r.DownloadMs,
+			&r.UploadMs, &r.DownloadMbps, &r.UploadMbps, &r.IncreaseMs, &r.Grade,
+			&r.Success, &r.Error, &r.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan bufferbloat test: %w", err)
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// CertStorage handles TLS certificate inventory persistence.
+type CertStorage struct {
+	db *DB
+}
+
+// NewCertStorage creates a new certificate storage handler.
+func NewCertStorage(db *DB) *CertStorage {
+	return &CertStorage{db: db}
+}
+
+// Save stores the certificate currently served on an endpoint. FirstSeen is
+// kept while the fingerprint stays the same and reset when it changes.
+func (s *CertStorage) Save(cert *model.Certificate) error {
+	query := `INSERT INTO certificates (host_id, ip, port, subject, sans, issuer, key_type, fingerprint, 
+			  chain_valid, chain_error, self_signed, not_before, not_after, first_seen, last_seen) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			  ON CONFLICT(ip, port) DO UPDATE SET 
+			  host_id = excluded.host_id,
+			  subject = excluded.subject,
+			  sans = excluded.sans,
+			  issuer = excluded.issuer,
+			  key_type = excluded.key_type,
+			  first_seen = CASE WHEN certificates.fingerprint = excluded.fingerprint 
+			               THEN certificates.first_seen ELSE excluded.first_seen END,
+			  fingerprint = excluded.fingerprint,
+			  chain_valid = excluded.chain_valid,
+			  chain_error = excluded.chain_error,
+			  self_signed = excluded.self_signed,
+			  not_before = excluded.not_before,
+			  not_after = excluded.not_after,
+			  last_seen = excluded.last_seen`
+
+	_, err := s.db.Exec(query,
+		cert.HostID, cert.IP, cert.Port, cert.Subject, strings.Join(cert.SANs, ","),
+		cert.Issuer, cert.KeyType, cert.Fingerprint, cert.ChainValid, cert.ChainError,
+		cert.SelfSigned, cert.NotBefore, cert.NotAfter, cert.LastSeen, cert.LastSeen)
+	if err != nil {
+		return fmt.Errorf("failed to save certificate: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the certificate last seen on an endpoint, or nil if none.
+func (s *CertStorage) Get(ip string, port int) (*model.Certificate, error) {
+	certs, err := s.query(`WHERE ip = ? AND port = ?`, ip, port)
+	if err != nil || len(certs) == 0 {
+		return nil, err
+	}
+	return &certs[0], nil
+}
+
+// GetAll returns all known certificates, soonest expiry first.
+func (s *CertStorage) GetAll() ([]model.Certificate, error) {
+	return s.query(`ORDER BY not_after ASC`)
+}
+
+func (s *CertStorage) query(where string, args ...interface{}) ([]model.Certificate, error) {
+	query := `SELECT id, COALESCE(host_id, 0), ip, port, COALESCE(subject, ''), sans, COALESCE(issuer, ''), 
+			  COALESCE(key_type, ''), fingerprint, chain_valid, COALESCE(chain_error, ''), self_signed, 
+			  not_before, not_after, first_seen, last_seen 
+			  FROM certificates ` + where
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query certificates: %w", err)
+	}
+	defer rows.Close()
+
+	var certs []model.Certificate
+	for rows.Next() {
+		var c model.Certificate
+		var sans sql.NullString
+		if err := rows.Scan(&c.ID, &c.HostID, &c.IP, &c.Port, &c.Subject, &sans, &c.Issuer,
+			&c.KeyType, &c.Fingerprint, &c.ChainValid, &c.ChainError, &c.SelfSigned,
+			&c.NotBefore, &c.NotAfter, &c.FirstSeen, &c.LastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan certificate: %w", err)
+		}
+		if sans.String != "" {
+			c.SANs = strings.Split(sans.String, ",")
+		}
+		certs = append(certs, c)
+	}
+
+	return certs, rows.Err()
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// DNSIntegrityStorage handles DNS integrity check persistence.
+type DNSIntegrityStorage struct {
+	db *DB
+}
+
+// NewDNSIntegrityStorage creates a new DNS integrity storage handler.
+func NewDNSIntegrityStorage(db *DB) *DNSIntegrityStorage {
+	return &DNSIntegrityStorage{db: db}
+}
+
+// Save stores a DNS integrity check.
+func (s *DNSIntegrityStorage) Save(check *model.DNSIntegrityCheck) error {
+	query := `INSERT INTO dns_integrity_checks (check_type, server, protocol, query_name, rcode, 
+			  answers, ok, timestamp) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	res, err := s.db.Exec(query,
+		check.Check, check.Server, check.Protocol, check.QueryName, check.Rcode,
+		strings.Join(check.Answers, ","), check.OK, check.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to insert dns integrity check: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	check.ID = id
+
+	return nil
+}
+
+// GetHistory returns checks since the given time, oldest first. An empty
+// server returns every server.
+func (s *DNSIntegrityStorage) GetHistory(server string, since time.Time) ([]model.DNSIntegrityCheck, error) {
+	if server == "" {
+		return s.query(`WHERE timestamp >= ? ORDER BY timestamp ASC, id ASC`, since)
+	}
+	return s.query(`WHERE server = ? AND timestamp >= ? ORDER BY timestamp ASC, id ASC`, server, since)
+}
+
+// GetLastRun returns the checks of the most recent run; every check of a
+// run has the same timestamp.
+func (s *DNSIntegrityStorage) GetLastRun() ([]model.DNSIntegrityCheck, error) {
+	return s.query(`WHERE timestamp = (SELECT MAX(timestamp) FROM dns_integrity_checks) ORDER BY id ASC`)
+}
+
+func (s *DNSIntegrityStorage) query(where string, args ...interface{}) ([]model.DNSIntegrityCheck, error) {
+	query := `SELECT id, check_type, server, COALESCE(protocol, ''), COALESCE(query_name, ''), 
+			  COALESCE(rcode, ''), COALESCE(answers, ''), ok, timestamp 
+			  FROM dns_integrity_checks ` + where
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dns integrity checks: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []model.DNSIntegrityCheck
+	for rows.Next() {
+		var c model.DNSIntegrityCheck
+		var answers string
+		if err := rows.Scan(&c.ID, &c.Check, &c.Server, &c.Protocol, &c.QueryName,
+			&c.Rcode, &answers, &c.OK, &c.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan dns integrity check: %w", err)
+		}
+		c.Answers = splitList(answers)
+		checks = append(checks, c)
+	}
+
+	return checks, rows.Err()
+}
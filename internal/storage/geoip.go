@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// GeoIPStorage caches online geolocation lookups.
+type GeoIPStorage struct {
+	db *DB
+}
+
+// NewGeoIPStorage creates a new geolocation cache handler.
+func NewGeoIPStorage(db *DB) *GeoIPStorage {
+	return &GeoIPStorage{db: db}
+}
+
+// Save stores or replaces the entry for an address.
+func (s *GeoIPStorage) Save(info *model.GeoInfo) error {
+	query := `INSERT OR REPLACE INTO geoip_cache (ip, asn, org, isp, country, country_code, 
+			  city, lat, lon, source, updated_at) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := s.db.Exec(query,
+		info.IP, info.ASN, info.Org, info.ISP, info.Country, info.CountryCode,
+		info.City, info.Lat, info.Lon, info.Source, info.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save geoip cache entry: %w", err)
+	}
+	return nil
+}
+
+// Get returns the entry for an address updated after since, or nil.
+func (s *GeoIPStorage) Get(ip string, since time.Time) (*model.GeoInfo, error) {
+	query := `SELECT ip, COALESCE(asn, ''), COALESCE(org, ''), COALESCE(isp, ''), 
+			  COALESCE(country, ''), COALESCE(country_code, ''), COALESCE(city, ''), 
+			  COALESCE(lat, 0), COALESCE(lon, 0), COALESCE(source, ''), updated_at 
+			  FROM geoip_cache WHERE ip = ? AND updated_at >= ?`
+
+	var info model.GeoInfo
+	err := s.db.QueryRow(query, ip, since).Scan(&info.IP, &info.ASN, &info.Org, &info.ISP,
+		&info.Country, &info.CountryCode, &info.City, &info.Lat, &info.Lon,
+		&info.Source, &info.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query geoip cache: %w", err)
+	}
+	return &info, nil
+}
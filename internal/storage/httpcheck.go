@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// HTTPCheckStorage handles synthetic HTTP check persistence.
+type HTTPCheckStorage struct {
+	db *DB
+}
+
+// NewHTTPCheckStorage creates a new HTTP check storage handler.
+func NewHTTPCheckStorage(db *DB) *HTTPCheckStorage {
+	return &HTTPCheckStorage{db: db}
+}
+
+// Save stores an HTTP check result.
+func (s *HTTPCheckStorage) Save(result *model.HTTPCheckResult) error {
+	query := `INSERT INTO http_checks (name, url, method, status_code, success, error, 
+			  dns_ms, connect_ms, tls_ms, ttfb_ms, total_ms, timestamp) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	res, err := s.db.Exec(query,
+		result.Name, result.URL, result.Method, result.StatusCode, result.Success, result.Error,
+		result.DNSMs, result.ConnectMs, result.TLSMs, result.TTFBMs, result.TotalMs, result.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to insert HTTP check: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	result.ID = id
+
+	return nil
+}
+
+// GetHistory returns check results since the given time, oldest first. An
+// empty name returns every check.
+func (s *HTTPCheckStorage) GetHistory(name string, since time.Time) ([]model.HTTPCheckResult, error) {
+	if name == "" {
+		return s.query(`WHERE timestamp >= ? ORDER BY timestamp ASC`, since)
+	}
+	return s.query(`WHERE name = ? AND timestamp >= ? ORDER BY timestamp ASC`, name, since)
+}
+
+// GetLatest returns the most recent result of each check.
+func (s *HTTPCheckStorage) GetLatest() ([]model.HTTPCheckResult, error) {
+	return s.query(`WHERE id IN (SELECT MAX(id) FROM http_checks GROUP BY name) ORDER BY name`)
+}
+
+func (s *HTTPCheckStorage) query(where string, args ...interface{}) ([]model.HTTPCheckResult, error) {
+	query := `SELECT id, name, url, COALESCE(method, 'GET'), COALESCE(status_code, 0), success, 
+			  COALESCE(error, ''), COALESCE(dns_ms, 0), COALESCE(connect_ms, 0), COALESCE(tls_ms, 0), 
+			  COALESCE(ttfb_ms, 0), COALESCE(total_ms, 0), timestamp 
+			  FROM http_checks ` + where
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query HTTP checks: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.HTTPCheckResult
+	for rows.Next() {
+		var r model.HTTPCheckResult
+		if err := rows.Scan(&r.ID, &r.Name, &r.URL, &r.Method, &r.StatusCode, &r.Success,
+			&r.Error, &r.DNSMs, &r.ConnectMs, &r.TLSMs, &r.TTFBMs, &r.TotalMs, &r.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan HTTP check: %w", err)
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
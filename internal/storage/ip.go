@@ -3,6 +3,7 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"net"
 	"time"
 
 	"github.com/user/netpulse/internal/model"
@@ -20,11 +21,15 @@ func NewIPStorage(db *DB) *IPStorage {
 
 // Save stores an IP record.
 func (s *IPStorage) Save(record *model.IPRecord) error {
-	query := `INSERT INTO ip_history (ip, asn, isp, country, city, timestamp) 
-			  VALUES (?, ?, ?, ?, ?, ?)`
+	if record.Family == "" {
+		record.Family = ipFamily(record.IP)
+	}
+	
+	query := `INSERT INTO ip_history (ip, family, asn, isp, country, city, timestamp) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?)`
 	
 	result, err := s.db.Exec(query, 
-		record.IP, record.ASN, record.ISP, 
+		record.IP, record.Family, record.ASN, record.ISP, 
 		record.Country, record.City, record.Timestamp)
 	if err != nil {
 		return fmt.Errorf("failed to insert IP record: %w", err)
@@ -39,14 +44,29 @@ func (s *IPStorage) Save(record *model.IPRecord) error {
 	return nil
 }
 
-// GetLatest returns the most recent IP record.
+// GetLatest returns the most recent IP record. On dual-stack hosts the
+// latest IPv4 record is preferred.
 func (s *IPStorage) GetLatest() (*model.IPRecord, error) {
-	query := `SELECT id, ip, asn, isp, country, city, timestamp 
-			  FROM ip_history ORDER BY timestamp DESC LIMIT 1`
+	query := `SELECT id, ip, COALESCE(family, 'ipv4'), asn, isp, country, city, timestamp 
+			  FROM ip_history ORDER BY COALESCE(family, 'ipv4') = 'ipv6', timestamp DESC LIMIT 1`
+	
+	return s.getLatest(query)
+}
+
+// GetLatestByFamily returns the most recent IP record of the given family
+// ("ipv4" or "ipv6").
+func (s *IPStorage) GetLatestByFamily(family string) (*model.IPRecord, error) {
+	query := `SELECT id, ip, COALESCE(family, 'ipv4'), asn, isp, country, city, timestamp 
+			  FROM ip_history WHERE COALESCE(family, 'ipv4') = ? 
+			  ORDER BY timestamp DESC LIMIT 1`
 	
+	return s.getLatest(query, family)
+}
+
+func (s *IPStorage) getLatest(query string, args ...interface{}) (*model.IPRecord, error) {
 	var record model.IPRecord
-	err := s.db.QueryRow(query).Scan(
-		&record.ID, &record.IP, &record.ASN, 
+	err := s.db.QueryRow(query, args...).Scan(
+		&record.ID, &record.IP, &record.Family, &record.ASN, 
 		&record.ISP, &record.Country, &record.City, &record.Timestamp)
 	
 	if err == sql.ErrNoRows {
@@ -61,7 +81,7 @@ func (s *IPStorage) GetLatest() (*model.IPRecord, error) {
 
 // GetHistory returns IP history since a given time.
 func (s *IPStorage) GetHistory(since time.Time) ([]model.IPRecord, error) {
-	query := `SELECT id, ip, asn, isp, country, city, timestamp 
+	query := `SELECT id, ip, COALESCE(family, 'ipv4'), asn, isp, country, city, timestamp 
 			  FROM ip_history WHERE timestamp >= ? ORDER BY timestamp DESC`
 	
 	rows, err := s.db.Query(query, since)
@@ -74,7 +94,7 @@ func (s *IPStorage) GetHistory(since time.Time) ([]model.IPRecord, error) {
 	for rows.Next() {
 		var record model.IPRecord
 		if err := rows.Scan(
-			&record.ID, &record.IP, &record.ASN,
+			&record.ID, &record.IP, &record.Family, &record.ASN,
 			&record.ISP, &record.Country, &record.City, &record.Timestamp); err != nil {
 			return nil, fmt.Errorf("failed to scan IP record: %w", err)
 		}
@@ -86,7 +106,7 @@ func (s *IPStorage) GetHistory(since time.Time) ([]model.IPRecord, error) {
 
 // GetChanges returns IP changes (distinct IPs) since a given time.
 func (s *IPStorage) GetChanges(since time.Time) ([]model.IPRecord, error) {
-	query := `SELECT id, ip, asn, isp, country, city, timestamp 
+	query := `SELECT id, ip, COALESCE(family, 'ipv4'), asn, isp, country, city, timestamp 
 			  FROM ip_history 
 			  WHERE timestamp >= ? 
 			  GROUP BY ip 
@@ -102,7 +122,7 @@ func (s *IPStorage) GetChanges(since time.Time) ([]model.IPRecord, error) {
 	for rows.Next() {
 		var record model.IPRecord
 		if err := rows.Scan(
-			&record.ID, &record.IP, &record.ASN,
+			&record.ID, &record.IP, &record.Family, &record.ASN,
 			&record.ISP, &record.Country, &record.City, &record.Timestamp); err != nil {
 			return nil, fmt.Errorf("failed to scan IP record: %w", err)
 		}
@@ -112,9 +132,10 @@ func (s *IPStorage) GetChanges(since time.Time) ([]model.IPRecord, error) {
 	return records, rows.Err()
 }
 
-// HasChanged checks if the IP has changed since the last record.
+// HasChanged checks if the IP has changed since the last record of the
+// same address family.
 func (s *IPStorage) HasChanged(currentIP string) (bool, error) {
-	latest, err := s.GetLatest()
+	latest, err := s.GetLatestByFamily(ipFamily(currentIP))
 	if err != nil {
 		return false, err
 	}
@@ -146,3 +167,73 @@ func (s *IPStorage) GetDistinctCount(since time.Time) (int, error) {
 		"SELECT COUNT(DISTINCT ip) FROM ip_history WHERE timestamp >= ?", since).Scan(&count)
 	return count, err
 }
+
+// SaveProviderCheck stores one provider's answer to a public IP detection.
+func (s *IPStorage) SaveProviderCheck(check *model.IPProviderCheck) error {
+	query := `INSERT INTO ip_provider_checks (provider, method, family, ip, success, agreed, 
+			  error, latency_ms, timestamp) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	
+	result, err := s.db.Exec(query,
+		check.Provider, check.Method, check.Family, check.IP, check.Success, check.Agreed,
+		check.Error, check.LatencyMs, check.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to insert IP provider check: %w", err)
+	}
+	
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	check.ID = id
+	
+	return nil
+}
+
+// GetProviderStats returns the reliability of each provider per address
+// family since a given time.
+func (s *IPStorage) GetProviderStats(since time.Time) ([]model.IPProviderStats, error) {
+	query := `SELECT provider, COALESCE(method, ''), COALESCE(family, ''), COUNT(*), 
+			  SUM(CASE WHEN success = 1 THEN 0 ELSE 1 END), 
+			  SUM(CASE WHEN success = 1 AND agreed = 0 THEN 1 ELSE 0 END), 
+			  COALESCE(AVG(CASE WHEN success = 1 THEN latency_ms END), 0) 
+			  FROM ip_provider_checks WHERE timestamp >= ? 
+			  GROUP BY provider, method, family ORDER BY family, provider`
+	
+	rows, err := s.db.Query(query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query IP provider stats: %w", err)
+	}
+	defer rows.Close()
+	
+	var stats []model.IPProviderStats
+	for rows.Next() {
+		var st model.IPProviderStats
+		if err := rows.Scan(&st.Provider, &st.Method, &st.Family, &st.Checks,
+			&st.Failures, &st.Disagreements, &st.AvgLatencyMs); err != nil {
+			return nil, fmt.Errorf("failed to scan IP provider stats: %w", err)
+		}
+		if st.Checks > 0 {
+			st.SuccessPct = float64(st.Checks-st.Failures) / float64(st.Checks) * 100
+		}
+		if answered := st.Checks - st.Failures; answered > 0 {
+			st.AgreementPct = float64(answered-st.Disagreements) / float64(answered) * 100
+		}
+		stats = append(stats, st)
+	}
+	
+	return stats, rows.Err()
+}
+
+// ipFamily returns "ipv4" or "ipv6" for an address, or "" if it is not one.
+func ipFamily(ip string) string {
+	parsed := net.ParseIP(ip)
+	switch {
+	case parsed == nil:
+		return ""
+	case parsed.To4() != nil:
+		return "ipv4"
+	default:
+		return "ipv6"
+	}
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// LossStorage handles persistence of per-minute loss and jitter statistics.
+type LossStorage struct {
+	db *DB
+}
+
+// NewLossStorage creates a new loss storage handler.
+func NewLossStorage(db *DB) *LossStorage {
+	return &LossStorage{db: db}
+}
+
+// Save stores one minute of statistics.
+func (s *LossStorage) Save(stats *model.LossStats) error {
+	query := `INSERT INTO loss_stats (target, method, minute, sent, received, loss_pct, 
+			  min_ms, avg_ms, max_ms, jitter_ms, p95_ms) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := s.db.Exec(query,
+		stats.Target, stats.Method, stats.Minute, stats.Sent, stats.Received, stats.LossPct,
+		stats.MinMs, stats.AvgMs, stats.MaxMs, stats.JitterMs, stats.P95Ms)
+	if err != nil {
+		return fmt.Errorf("failed to insert loss stats: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	stats.ID = id
+
+	return nil
+}
+
+// GetHistory returns statistics since the given time, oldest first. An
+// empty target returns every target.
+func (s *LossStorage) GetHistory(target string, since time.Time) ([]model.LossStats, error) {
+	query := `SELECT id, target, COALESCE(method, ''), minute, sent, received, loss_pct, 
+			  min_ms, avg_ms, max_ms, jitter_ms, p95_ms 
+			  FROM loss_stats WHERE minute >= ?`
+	args := []interface{}{since}
+	if target != "" {
+		query += ` AND target = ?`
+		args = append(args, target)
+	}
+	query += ` ORDER BY minute ASC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query loss stats: %w", err)
+	}
+	defer rows.Close()
+
+	var history []model.LossStats
+	for rows.Next() {
+		var st model.LossStats
+		if err := rows.Scan(&st.ID, &st.Target, &st.Method, &st.Minute, &st.Sent, &st.Received,
+			&st.LossPct, &st.MinMs, &st.AvgMs, &st.MaxMs, &st.JitterMs, &st.P95Ms); err != nil {
+			return nil, fmt.Errorf("failed to scan loss stats: %w", err)
+		}
+		history = append(history, st)
+	}
+
+	return history, rows.Err()
+}
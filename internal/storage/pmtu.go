@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// PMTUStorage handles path MTU result persistence.
+type PMTUStorage struct {
+	db *DB
+}
+
+// NewPMTUStorage creates a new path MTU storage handler.
+func NewPMTUStorage(db *DB) *PMTUStorage {
+	return &PMTUStorage{db: db}
+}
+
+// Save stores a path MTU result.
+func (s *PMTUStorage) Save(result *model.PMTUResult) error {
+	query := `INSERT INTO pmtu_results (target, pmtu, local_mtu, reported_by, reported_mtu, 
+			  black_hole, success, error, timestamp) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	res, err := s.db.Exec(query,
+		result.Target, result.PMTU, result.LocalMTU, result.ReportedBy, result.ReportedMTU,
+		result.BlackHole, result.Success, result.Error, result.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to insert pmtu result: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	result.ID = id
+
+	return nil
+}
+
+// GetHistory returns results since the given time, oldest first. An empty
+// target returns every target.
+func (s *PMTUStorage) GetHistory(target string, since time.Time) ([]model.PMTUResult, error) {
+	if target == "" {
+		return s.query(`WHERE timestamp >= ? ORDER BY timestamp ASC`, since)
+	}
+	return s.query(`WHERE target = ? AND timestamp >= ? ORDER BY timestamp ASC`, target, since)
+}
+
+// GetLatest returns the most recent successful result for a target, or nil.
+func (s *PMTUStorage) GetLatest(target string) (*model.PMTUResult, error) {
+	results, err := s.query(`WHERE target = ? AND success = 1 ORDER BY timestamp DESC LIMIT 1`, target)
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	return &results[0], nil
+}
+
+func (s *PMTUStorage) query(where string, args ...interface{}) ([]model.PMTUResult, error) {
+	query := `SELECT id, target, COALESCE(pmtu, 0), COALESCE(local_mtu, 0), COALESCE(reported_by, ''), 
+			  COALESCE(reported_mtu, 0), black_hole, success, COALESCE(error, ''), timestamp 
+			  FROM pmtu_results ` + where
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pmtu results: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.PMTUResult
+	for rows.Next() {
+		var r model.PMTUResult
+		if err := rows.Scan(&r.ID, &r.Target, &r.PMTU, &r.LocalMTU, &r.ReportedBy,
+			&r.ReportedMTU, &r.BlackHole, &r.Success, &r.Error, &r.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan pmtu result: %w", err)
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// PTRStorage caches reverse DNS names.
+type PTRStorage struct {
+	db *DB
+}
+
+// NewPTRStorage creates a new reverse DNS cache handler.
+func NewPTRStorage(db *DB) *PTRStorage {
+	return &PTRStorage{db: db}
+}
+
+// Save stores or replaces the name of an address.
+func (s *PTRStorage) Save(record *model.PTRRecord) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO ptr_cache (ip, hostname, updated_at) VALUES (?, ?, ?)`,
+		record.IP, record.Hostname, record.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save ptr cache entry: %w", err)
+	}
+	return nil
+}
+
+// Get returns the cached name of an address, or nil.
+func (s *PTRStorage) Get(ip string) (*model.PTRRecord, error) {
+	var record model.PTRRecord
+	err := s.db.QueryRow(`SELECT ip, COALESCE(hostname, ''), updated_at FROM ptr_cache WHERE ip = ?`, ip).
+		Scan(&record.IP, &record.Hostname, &record.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ptr cache: %w", err)
+	}
+	return &record, nil
+}
@@ -19,30 +19,166 @@ func NewScanStorage(db *DB) *ScanStorage {
 	return &ScanStorage{db: db}
 }
 
-// SaveHost stores or updates a discovered host.
+// SaveHost stores or updates a discovered host. Hosts with a known MAC are
+// keyed by it, so a device keeps its row (and metadata) across IP changes.
 func (s *ScanStorage) SaveHost(host *model.ScanHost) error {
-	query := `INSERT INTO scan_hosts (ip, hostname, alive, latency_ms, last_seen) 
-			  VALUES (?, ?, ?, ?, ?)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	
+	if host.MAC != "" {
+		if err := claimIP(tx, host); err != nil {
+			return err
+		}
+	}
+	
+	// Sources are merged in the same statement: the id of an updated row is
+	// only available through RETURNING, not LastInsertId
+	query := `INSERT INTO scan_hosts (ip, hostname, alive, latency_ms, last_seen, detected_by, mac, vendor, workgroup, sources) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''))
 			  ON CONFLICT(ip) DO UPDATE SET 
-			  hostname = excluded.hostname,
+			  hostname = COALESCE(NULLIF(excluded.hostname, ''), scan_hosts.hostname),
 			  alive = excluded.alive,
 			  latency_ms = excluded.latency_ms,
-			  last_seen = excluded.last_seen`
+			  last_seen = excluded.last_seen,
+			  detected_by = COALESCE(NULLIF(excluded.detected_by, ''), scan_hosts.detected_by),
+			  mac = COALESCE(NULLIF(excluded.mac, ''), scan_hosts.mac),
+			  vendor = COALESCE(NULLIF(excluded.vendor, ''), scan_hosts.vendor),
+			  workgroup = COALESCE(NULLIF(excluded.workgroup, ''), scan_hosts.workgroup),
+			  sources = CASE
+			    WHEN excluded.sources IS NULL THEN scan_hosts.sources
+			    WHEN COALESCE(scan_hosts.sources, '') = '' THEN excluded.sources
+			    WHEN instr(',' || scan_hosts.sources || ',', ',' || excluded.sources || ',') > 0 THEN scan_hosts.sources
+			    ELSE scan_hosts.sources || ',' || excluded.sources
+			  END
+			  RETURNING id`
 	
-	result, err := s.db.Exec(query, 
-		host.IP, host.Hostname, host.Alive, host.LatencyMs, host.LastSeen)
+	err = tx.QueryRow(query, 
+		host.IP, host.Hostname, host.Alive, host.LatencyMs, host.LastSeen, host.DetectedBy,
+		host.MAC, host.Vendor, host.Workgroup, host.DetectedBy).Scan(&host.ID)
 	if err != nil {
 		return fmt.Errorf("failed to save host: %w", err)
 	}
 	
-	if host.ID == 0 {
-		id, err := result.LastInsertId()
-		if err == nil && id > 0 {
-			host.ID = id
-		} else {
-			// Get existing ID
-			s.db.QueryRow("SELECT id FROM scan_hosts WHERE ip = ?", host.IP).Scan(&host.ID)
+	return tx.Commit()
+}
+
+// EnrichHost merges names and services announced by a host (mDNS, SSDP)
+// into its row, creating the row if the host was not found by the sweep.
+// Such rows are not marked alive, since the host did not answer probes.
+// An existing hostname is kept; services and sources are merged.
+func (s *ScanStorage) EnrichHost(ip, hostname string, services []string, info, source string) error {
+	var id int64
+	err := s.db.QueryRow("SELECT id FROM scan_hosts WHERE ip = ?", ip).Scan(&id)
+	if err == sql.ErrNoRows {
+		_, err := s.db.Exec(
+			`INSERT INTO scan_hosts (ip, hostname, alive, latency_ms, last_seen, detected_by, sources, services, device_info)
+			 VALUES (?, ?, 0, NULL, ?, ?, ?, ?, ?)`,
+			ip, hostname, time.Now(), source, source, strings.Join(services, ","), info)
+		if err != nil {
+			return fmt.Errorf("failed to save host: %w", err)
 		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up host: %w", err)
+	}
+	
+	query := `UPDATE scan_hosts SET
+			  hostname = COALESCE(NULLIF(hostname, ''), ?),
+			  services = ?,
+			  sources = ?,
+			  device_info = COALESCE(NULLIF(?, ''), device_info)
+			  WHERE id = ?`
+	
+	mergedServices, err := s.mergedList(id, "services", services)
+	if err != nil {
+		return err
+	}
+	mergedSources, err := s.mergedList(id, "sources", []string{source})
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(query, hostname, mergedServices, mergedSources, info, id)
+	if err != nil {
+		return fmt.Errorf("failed to enrich host: %w", err)
+	}
+	return nil
+}
+
+// mergedList returns a comma-separated list column of a host with values
+// added, keeping the existing order.
+func (s *ScanStorage) mergedList(id int64, column string, values []string) (string, error) {
+	var existing sql.NullString
+	if err := s.db.QueryRow("SELECT "+column+" FROM scan_hosts WHERE id = ?", id).Scan(&existing); err != nil {
+		return "", fmt.Errorf("failed to read host %s: %w", column, err)
+	}
+	
+	list := splitList(existing.String)
+	for _, v := range values {
+		found := false
+		for _, e := range list {
+			if e == v {
+				found = true
+				break
+			}
+		}
+		if !found && v != "" {
+			list = append(list, v)
+		}
+	}
+	return strings.Join(list, ","), nil
+}
+
+// splitList splits a comma-separated column, returning nil for "".
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// claimIP moves the row for host.MAC to host.IP before it is saved, within
+// the caller's transaction. A row without a MAC already at that IP is
+// adopted if the device has no row yet; a row for another device is kept
+// without an address until that device is seen again.
+func claimIP(tx *sql.Tx, host *model.ScanHost) error {
+	var deviceID int64
+	var deviceIP sql.NullString
+	err := tx.QueryRow("SELECT id, ip FROM scan_hosts WHERE mac = ?", host.MAC).Scan(&deviceID, &deviceIP)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to look up host by MAC: %w", err)
+	}
+	if deviceIP.String == host.IP {
+		return nil
+	}
+	
+	// Free the IP from whatever row holds it
+	var otherID int64
+	var otherMAC sql.NullString
+	err = tx.QueryRow("SELECT id, mac FROM scan_hosts WHERE ip = ?", host.IP).Scan(&otherID, &otherMAC)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return fmt.Errorf("failed to look up host by IP: %w", err)
+	case otherMAC.String != "":
+		if _, err := tx.Exec("UPDATE scan_hosts SET ip = NULL, alive = 0 WHERE id = ?", otherID); err != nil {
+			return fmt.Errorf("failed to release IP %s: %w", host.IP, err)
+		}
+	case deviceID != 0:
+		// An earlier sighting before the MAC was known
+		if _, err := tx.Exec("DELETE FROM scan_hosts WHERE id = ?", otherID); err != nil {
+			return fmt.Errorf("failed to release IP %s: %w", host.IP, err)
+		}
+	}
+	
+	if deviceID != 0 {
+		if _, err := tx.Exec("UPDATE scan_hosts SET ip = ? WHERE id = ?", host.IP, deviceID); err != nil {
+			return fmt.Errorf("failed to move host to %s: %w", host.IP, err)
+		}
+		host.ID = deviceID
 	}
 	
 	return nil
@@ -50,17 +186,22 @@ func (s *ScanStorage) SaveHost(host *model.ScanHost) error {
 
 // SavePort stores or updates a port scan result.
 func (s *ScanStorage) SavePort(port *model.ScanPort) error {
-	query := `INSERT INTO scan_ports (host_id, port, protocol, service, state, banner, last_seen) 
-			  VALUES (?, ?, ?, ?, ?, ?, ?)
+	query := `INSERT INTO scan_ports (host_id, port, protocol, service, state, banner, product, version, info, tls, last_seen) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 			  ON CONFLICT(host_id, port, protocol) DO UPDATE SET 
 			  service = excluded.service,
 			  state = excluded.state,
 			  banner = excluded.banner,
+			  product = excluded.product,
+			  version = excluded.version,
+			  info = excluded.info,
+			  tls = excluded.tls,
 			  last_seen = excluded.last_seen`
 	
 	result, err := s.db.Exec(query,
 		port.HostID, port.Port, port.Protocol, 
-		port.Service, port.State, port.Banner, port.LastSeen)
+		port.Service, port.State, port.Banner,
+		port.Product, port.Version, port.Info, port.TLS, port.LastSeen)
 	if err != nil {
 		return fmt.Errorf("failed to save port: %w", err)
 	}
@@ -73,18 +214,30 @@ func (s *ScanStorage) SavePort(port *model.ScanPort) error {
 	return nil
 }
 
+// DeletePort removes a port from a host.
+func (s *ScanStorage) DeletePort(hostID int64, port int, protocol string) error {
+	_, err := s.db.Exec("DELETE FROM scan_ports WHERE host_id = ? AND port = ? AND protocol = ?", hostID, port, protocol)
+	if err != nil {
+		return fmt.Errorf("failed to delete port: %w", err)
+	}
+	return nil
+}
+
 // GetHost returns a host by IP.
 func (s *ScanStorage) GetHost(ip string) (*model.ScanHost, error) {
-	query := `SELECT id, ip, hostname, alive, latency_ms, last_seen, display_name, tags, icon 
+	query := `SELECT id, ip, hostname, alive, COALESCE(latency_ms, 0), last_seen, display_name, tags, icon, detected_by, mac, vendor,
+			  sources, services, device_info, workgroup 
 			  FROM scan_hosts WHERE ip = ?`
 	
 	var host model.ScanHost
-	var displayName, tags, icon sql.NullString
+	var displayName, tags, icon, detectedBy, mac, vendor sql.NullString
+	var sources, services, deviceInfo, workgroup sql.NullString
 
 	err := s.db.QueryRow(query, ip).Scan(
 		&host.ID, &host.IP, &host.Hostname, 
 		&host.Alive, &host.LatencyMs, &host.LastSeen,
-		&displayName, &tags, &icon)
+		&displayName, &tags, &icon, &detectedBy, &mac, &vendor,
+		&sources, &services, &deviceInfo, &workgroup)
 	
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -102,14 +255,22 @@ func (s *ScanStorage) GetHost(ip string) (*model.ScanHost, error) {
 	if tags.Valid && tags.String != "" {
 		host.Tags = strings.Split(tags.String, ",")
 	}
+	host.DetectedBy = detectedBy.String
+	host.MAC = mac.String
+	host.Vendor = vendor.String
+	host.Sources = splitList(sources.String)
+	host.Services = splitList(services.String)
+	host.DeviceInfo = deviceInfo.String
+	host.Workgroup = workgroup.String
 	
 	return &host, nil
 }
 
 // GetAliveHosts returns all alive hosts.
 func (s *ScanStorage) GetAliveHosts() ([]model.ScanHost, error) {
-	query := `SELECT id, ip, hostname, alive, latency_ms, last_seen, display_name, tags, icon 
-			  FROM scan_hosts WHERE alive = 1 ORDER BY ip`
+	query := `SELECT id, ip, hostname, alive, COALESCE(latency_ms, 0), last_seen, display_name, tags, icon, detected_by, mac, vendor,
+			  sources, services, device_info, workgroup 
+			  FROM scan_hosts WHERE alive = 1 AND ip IS NOT NULL ORDER BY ip`
 	
 	rows, err := s.db.Query(query)
 	if err != nil {
@@ -120,9 +281,11 @@ func (s *ScanStorage) GetAliveHosts() ([]model.ScanHost, error) {
 	var hosts []model.ScanHost
 	for rows.Next() {
 		var h model.ScanHost
-		var displayName, tags, icon sql.NullString
+		var displayName, tags, icon, detectedBy, mac, vendor sql.NullString
+		var sources, services, deviceInfo, workgroup sql.NullString
 		
-		if err := rows.Scan(&h.ID, &h.IP, &h.Hostname, &h.Alive, &h.LatencyMs, &h.LastSeen, &displayName, &tags, &icon); err != nil {
+		if err := rows.Scan(&h.ID, &h.IP, &h.Hostname, &h.Alive, &h.LatencyMs, &h.LastSeen, &displayName, &tags, &icon, &detectedBy, &mac, &vendor,
+			&sources, &services, &deviceInfo, &workgroup); err != nil {
 			continue
 		}
 		
@@ -135,6 +298,13 @@ func (s *ScanStorage) GetAliveHosts() ([]model.ScanHost, error) {
 		if tags.Valid && tags.String != "" {
 			h.Tags = strings.Split(tags.String, ",")
 		}
+		h.DetectedBy = detectedBy.String
+		h.MAC = mac.String
+		h.Vendor = vendor.String
+		h.Sources = splitList(sources.String)
+		h.Services = splitList(services.String)
+		h.DeviceInfo = deviceInfo.String
+		h.Workgroup = workgroup.String
 
 		hosts = append(hosts, h)
 	}
@@ -142,10 +312,12 @@ func (s *ScanStorage) GetAliveHosts() ([]model.ScanHost, error) {
 	return hosts, rows.Err()
 }
 
-// GetHostPorts returns open ports for a host.
+// GetHostPorts returns open ports for a host, including UDP ports that may be
+// open but never answered (open|filtered).
 func (s *ScanStorage) GetHostPorts(hostID int64) ([]model.ScanPort, error) {
-	query := `SELECT id, host_id, port, protocol, service, state, banner, last_seen 
-			  FROM scan_ports WHERE host_id = ? AND state = 'open' ORDER BY port`
+	query := `SELECT id, host_id, port, protocol, service, state, banner,
+			  COALESCE(product, ''), COALESCE(version, ''), COALESCE(info, ''), COALESCE(tls, 0), last_seen 
+			  FROM scan_ports WHERE host_id = ? AND state IN ('open', 'open|filtered') ORDER BY port, protocol`
 	
 	rows, err := s.db.Query(query, hostID)
 	if err != nil {
@@ -158,7 +330,8 @@ func (s *ScanStorage) GetHostPorts(hostID int64) ([]model.ScanPort, error) {
 		var port model.ScanPort
 		if err := rows.Scan(
 			&port.ID, &port.HostID, &port.Port, &port.Protocol,
-			&port.Service, &port.State, &port.Banner, &port.LastSeen); err != nil {
+			&port.Service, &port.State, &port.Banner,
+			&port.Product, &port.Version, &port.Info, &port.TLS, &port.LastSeen); err != nil {
 			return nil, fmt.Errorf("failed to scan port: %w", err)
 		}
 		ports = append(ports, port)
@@ -169,8 +342,10 @@ func (s *ScanStorage) GetHostPorts(hostID int64) ([]model.ScanPort, error) {
 
 // GetRecentlyDiscovered returns hosts discovered since a given time.
 func (s *ScanStorage) GetRecentlyDiscovered(since time.Time) ([]model.ScanHost, error) {
-	query := `SELECT id, ip, hostname, alive, latency_ms, last_seen 
-			  FROM scan_hosts WHERE last_seen >= ? ORDER BY last_seen DESC`
+	query := `SELECT id, ip, hostname, alive, COALESCE(latency_ms, 0), last_seen, COALESCE(detected_by, ''), 
+			  COALESCE(mac, ''), COALESCE(vendor, ''), COALESCE(sources, ''), COALESCE(services, ''),
+			  COALESCE(device_info, ''), COALESCE(workgroup, '') 
+			  FROM scan_hosts WHERE last_seen >= ? AND ip IS NOT NULL ORDER BY last_seen DESC`
 	
 	rows, err := s.db.Query(query, since)
 	if err != nil {
@@ -181,11 +356,15 @@ func (s *ScanStorage) GetRecentlyDiscovered(since time.Time) ([]model.ScanHost,
 	var hosts []model.ScanHost
 	for rows.Next() {
 		var host model.ScanHost
+		var sources, services string
 		if err := rows.Scan(
 			&host.ID, &host.IP, &host.Hostname,
-			&host.Alive, &host.LatencyMs, &host.LastSeen); err != nil {
+			&host.Alive, &host.LatencyMs, &host.LastSeen, &host.DetectedBy,
+			&host.MAC, &host.Vendor, &sources, &services, &host.DeviceInfo, &host.Workgroup); err != nil {
 			return nil, fmt.Errorf("failed to scan host: %w", err)
 		}
+		host.Sources = splitList(sources)
+		host.Services = splitList(services)
 		hosts = append(hosts, host)
 	}
 	
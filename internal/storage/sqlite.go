@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -64,15 +65,32 @@ func (db *DB) createTables() error {
 			isp TEXT,
 			country TEXT,
 			city TEXT,
-			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+			family TEXT DEFAULT 'ipv4'
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_ip_history_timestamp ON ip_history(timestamp)`,
 		`CREATE INDEX IF NOT EXISTS idx_ip_history_ip ON ip_history(ip)`,
 
+		`CREATE TABLE IF NOT EXISTS ip_provider_checks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			provider TEXT NOT NULL,
+			method TEXT,
+			family TEXT,
+			ip TEXT,
+			success INTEGER DEFAULT 0,
+			agreed INTEGER DEFAULT 0,
+			error TEXT,
+			latency_ms REAL,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_ip_provider_checks_timestamp ON ip_provider_checks(timestamp)`,
+
 		`CREATE TABLE IF NOT EXISTS traces (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			target TEXT NOT NULL,
-			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+			mode TEXT DEFAULT 'classic',
+			as_path TEXT
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_traces_timestamp ON traces(timestamp)`,
 		`CREATE INDEX IF NOT EXISTS idx_traces_target ON traces(target)`,
@@ -84,6 +102,11 @@ func (db *DB) createTables() error {
 			ip TEXT,
 			hostname TEXT,
 			latency_ms REAL,
+			rtts TEXT,
+			icmp_type INTEGER,
+			icmp_code INTEGER,
+			hop_set TEXT,
+			asn TEXT,
 			lost INTEGER DEFAULT 0,
 			FOREIGN KEY (trace_id) REFERENCES traces(id) ON DELETE CASCADE
 		)`,
@@ -91,14 +114,21 @@ func (db *DB) createTables() error {
 
 		`CREATE TABLE IF NOT EXISTS scan_hosts (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			ip TEXT NOT NULL UNIQUE,
+			ip TEXT UNIQUE,
 			hostname TEXT,
 			alive INTEGER DEFAULT 0,
 			latency_ms REAL,
 			last_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
 			display_name TEXT,
 			tags TEXT,
-			icon TEXT
+			icon TEXT,
+			detected_by TEXT,
+			mac TEXT,
+			vendor TEXT,
+			sources TEXT,
+			services TEXT,
+			device_info TEXT,
+			workgroup TEXT
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_scan_hosts_ip ON scan_hosts(ip)`,
 
@@ -106,8 +136,15 @@ func (db *DB) createTables() error {
             id INTEGER PRIMARY KEY AUTOINCREMENT,
             server TEXT NOT NULL,
             protocol TEXT NOT NULL,
+            query_name TEXT,
+            query_type TEXT,
             resolved_ip TEXT,
             latency_ms INTEGER,
+            rcode TEXT,
+            answer_count INTEGER,
+            success INTEGER DEFAULT 1,
+            error_class TEXT,
+            error TEXT,
             timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
         )`,
 		`CREATE INDEX IF NOT EXISTS idx_dns_metrics_timestamp ON dns_metrics(timestamp)`,
@@ -116,7 +153,11 @@ func (db *DB) createTables() error {
             id INTEGER PRIMARY KEY AUTOINCREMENT,
             name TEXT NOT NULL,
             ip TEXT,
-            doh_url TEXT
+            doh_url TEXT,
+            doh_format TEXT,
+            dot_server TEXT,
+            query_names TEXT,
+            query_types TEXT
         )`,
 		`CREATE INDEX IF NOT EXISTS idx_scan_hosts_alive ON scan_hosts(alive)`,
 
@@ -128,6 +169,10 @@ func (db *DB) createTables() error {
 			service TEXT,
 			state TEXT DEFAULT 'open',
 			banner TEXT,
+			product TEXT,
+			version TEXT,
+			info TEXT,
+			tls INTEGER DEFAULT 0,
 			last_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (host_id) REFERENCES scan_hosts(id) ON DELETE CASCADE,
 			UNIQUE(host_id, port, protocol)
@@ -145,6 +190,141 @@ func (db *DB) createTables() error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_anomalies_timestamp ON anomalies(timestamp)`,
 		`CREATE INDEX IF NOT EXISTS idx_anomalies_type ON anomalies(type)`,
+
+		`CREATE TABLE IF NOT EXISTS certificates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			host_id INTEGER,
+			ip TEXT NOT NULL,
+			port INTEGER NOT NULL,
+			subject TEXT,
+			sans TEXT,
+			issuer TEXT,
+			key_type TEXT,
+			fingerprint TEXT NOT NULL,
+			chain_valid INTEGER DEFAULT 0,
+			chain_error TEXT,
+			self_signed INTEGER DEFAULT 0,
+			not_before DATETIME,
+			not_after DATETIME,
+			first_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(ip, port)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_certificates_not_after ON certificates(not_after)`,
+
+		`CREATE TABLE IF NOT EXISTS http_checks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			url TEXT NOT NULL,
+			method TEXT DEFAULT 'GET',
+			status_code INTEGER,
+			success INTEGER DEFAULT 0,
+			error TEXT,
+			dns_ms REAL,
+			connect_ms REAL,
+			tls_ms REAL,
+			ttfb_ms REAL,
+			total_ms REAL,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_http_checks_name_timestamp ON http_checks(name, timestamp)`,
+
+		`CREATE TABLE IF NOT EXISTS loss_stats (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			target TEXT NOT NULL,
+			method TEXT,
+			minute DATETIME NOT NULL,
+			sent INTEGER,
+			received INTEGER,
+			loss_pct REAL,
+			min_ms REAL,
+			avg_ms REAL,
+			max_ms REAL,
+			jitter_ms REAL,
+			p95_ms REAL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_loss_stats_target_minute ON loss_stats(target, minute)`,
+
+		`CREATE TABLE IF NOT EXISTS throughput_tests (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			server TEXT NOT NULL,
+			protocol TEXT NOT NULL,
+			direction TEXT NOT NULL,
+			mbps REAL,
+			bytes INTEGER,
+			duration_ms REAL,
+			retransmits INTEGER,
+			loss_pct REAL,
+			jitter_ms REAL,
+			success INTEGER DEFAULT 0,
+			error TEXT,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_throughput_tests_server_timestamp ON throughput_tests(server, timestamp)`,
+
+		`CREATE TABLE IF NOT EXISTS bufferbloat_tests (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			target TEXT NOT NULL,
+			server TEXT NOT NULL,
+			idle_ms REAL,
+			download_ms REAL,
+			upload_ms REAL,
+			download_mbps REAL,
+			upload_mbps REAL,
+			increase_ms REAL,
+			grade TEXT,
+			success INTEGER DEFAULT 0,
+			error TEXT,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_bufferbloat_tests_target_timestamp ON bufferbloat_tests(target, timestamp)`,
+
+		`CREATE TABLE IF NOT EXISTS pmtu_results (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			target TEXT NOT NULL,
+			pmtu INTEGER,
+			local_mtu INTEGER,
+			reported_by TEXT,
+			reported_mtu INTEGER,
+			black_hole INTEGER DEFAULT 0,
+			success INTEGER DEFAULT 0,
+			error TEXT,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_pmtu_results_target_timestamp ON pmtu_results(target, timestamp)`,
+
+		`CREATE TABLE IF NOT EXISTS dns_integrity_checks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			check_type TEXT NOT NULL,
+			server TEXT NOT NULL,
+			protocol TEXT,
+			query_name TEXT,
+			rcode TEXT,
+			answers TEXT,
+			ok INTEGER DEFAULT 1,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_dns_integrity_checks_timestamp ON dns_integrity_checks(timestamp)`,
+
+		`CREATE TABLE IF NOT EXISTS geoip_cache (
+			ip TEXT PRIMARY KEY,
+			asn TEXT,
+			org TEXT,
+			isp TEXT,
+			country TEXT,
+			country_code TEXT,
+			city TEXT,
+			lat REAL,
+			lon REAL,
+			source TEXT,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS ptr_cache (
+			ip TEXT PRIMARY KEY,
+			hostname TEXT,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
 	}
 
 	for _, table := range tables {
@@ -160,12 +340,104 @@ func (db *DB) createTables() error {
 		"ALTER TABLE scan_hosts ADD COLUMN tags TEXT",
 		"ALTER TABLE scan_hosts ADD COLUMN icon TEXT",
 		"ALTER TABLE dns_metrics ADD COLUMN resolved_ip TEXT",
+		"ALTER TABLE trace_hops ADD COLUMN rtts TEXT",
+		"ALTER TABLE trace_hops ADD COLUMN icmp_type INTEGER",
+		"ALTER TABLE trace_hops ADD COLUMN icmp_code INTEGER",
+		"ALTER TABLE traces ADD COLUMN mode TEXT DEFAULT 'classic'",
+		"ALTER TABLE trace_hops ADD COLUMN hop_set TEXT",
+		"ALTER TABLE ip_history ADD COLUMN family TEXT DEFAULT 'ipv4'",
+		"UPDATE ip_history SET family = 'ipv6' WHERE ip LIKE '%:%' AND family != 'ipv6'",
+		"ALTER TABLE scan_hosts ADD COLUMN detected_by TEXT",
+		"ALTER TABLE scan_hosts ADD COLUMN mac TEXT",
+		"ALTER TABLE scan_hosts ADD COLUMN vendor TEXT",
+		"CREATE INDEX IF NOT EXISTS idx_scan_hosts_mac ON scan_hosts(mac)",
+		"ALTER TABLE scan_ports ADD COLUMN product TEXT",
+		"ALTER TABLE scan_ports ADD COLUMN version TEXT",
+		"ALTER TABLE scan_ports ADD COLUMN info TEXT",
+		"ALTER TABLE scan_ports ADD COLUMN tls INTEGER DEFAULT 0",
+		"ALTER TABLE scan_hosts ADD COLUMN sources TEXT",
+		"ALTER TABLE scan_hosts ADD COLUMN services TEXT",
+		"ALTER TABLE scan_hosts ADD COLUMN device_info TEXT",
+		"UPDATE scan_hosts SET sources = detected_by WHERE sources IS NULL AND detected_by IS NOT NULL",
+		"ALTER TABLE scan_hosts ADD COLUMN workgroup TEXT",
+		"ALTER TABLE dns_metrics ADD COLUMN query_name TEXT",
+		"ALTER TABLE dns_metrics ADD COLUMN query_type TEXT",
+		"ALTER TABLE dns_metrics ADD COLUMN rcode TEXT",
+		"ALTER TABLE dns_metrics ADD COLUMN answer_count INTEGER",
+		"ALTER TABLE dns_metrics ADD COLUMN success INTEGER DEFAULT 1",
+		"ALTER TABLE dns_metrics ADD COLUMN error_class TEXT",
+		"ALTER TABLE dns_metrics ADD COLUMN error TEXT",
+		"ALTER TABLE dns_targets ADD COLUMN doh_format TEXT",
+		"ALTER TABLE dns_targets ADD COLUMN dot_server TEXT",
+		"ALTER TABLE dns_targets ADD COLUMN query_names TEXT",
+		"ALTER TABLE dns_targets ADD COLUMN query_types TEXT",
+		"ALTER TABLE traces ADD COLUMN as_path TEXT",
+		"ALTER TABLE trace_hops ADD COLUMN asn TEXT",
 	}
 	for _, m := range migrations {
 		db.Exec(m)
 	}
 
-	return nil
+	return db.migrateScanHostsIP()
+}
+
+// scanHostsColumns lists the columns of scan_hosts, for rebuilding it.
+const scanHostsColumns = `id, ip, hostname, alive, latency_ms, last_seen, display_name, tags, icon,
+	detected_by, mac, vendor, sources, services, device_info, workgroup`
+
+// migrateScanHostsIP makes scan_hosts.ip nullable in databases created when
+// it was NOT NULL, and clears the MAC addresses stored as the IP of devices
+// parked off their address. SQLite cannot drop the constraint in place, so
+// the table is rebuilt.
+func (db *DB) migrateScanHostsIP() error {
+	var notNull int
+	err := db.QueryRow(`SELECT "notnull" FROM pragma_table_info('scan_hosts') WHERE name = 'ip'`).Scan(&notNull)
+	if err != nil {
+		return fmt.Errorf("failed to inspect scan_hosts: %w", err)
+	}
+	if notNull == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	steps := []string{
+		`CREATE TABLE scan_hosts_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ip TEXT UNIQUE,
+			hostname TEXT,
+			alive INTEGER DEFAULT 0,
+			latency_ms REAL,
+			last_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
+			display_name TEXT,
+			tags TEXT,
+			icon TEXT,
+			detected_by TEXT,
+			mac TEXT,
+			vendor TEXT,
+			sources TEXT,
+			services TEXT,
+			device_info TEXT,
+			workgroup TEXT
+		)`,
+		"INSERT INTO scan_hosts_new (" + scanHostsColumns + ") SELECT " + scanHostsColumns + " FROM scan_hosts",
+		"UPDATE scan_hosts_new SET ip = NULL WHERE ip = mac",
+		"DROP TABLE scan_hosts",
+		"ALTER TABLE scan_hosts_new RENAME TO scan_hosts",
+		"CREATE INDEX IF NOT EXISTS idx_scan_hosts_ip ON scan_hosts(ip)",
+		"CREATE INDEX IF NOT EXISTS idx_scan_hosts_alive ON scan_hosts(alive)",
+		"CREATE INDEX IF NOT EXISTS idx_scan_hosts_mac ON scan_hosts(mac)",
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step); err != nil {
+			return fmt.Errorf("failed to migrate scan_hosts: %w", err)
+		}
+	}
+	return tx.Commit()
 }
 
 // Close closes the database connection.
@@ -191,39 +463,113 @@ func (db *DB) WithRLock(fn func() error) error {
 func (db *DB) SaveDNSMetric(m model.DNSMetric) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
-	_, err := db.Exec(`INSERT INTO dns_metrics (server, protocol, resolved_ip, latency_ms, timestamp) VALUES (?, ?, ?, ?, ?)`,
-		m.Server, m.Protocol, m.ResolvedIP, m.LatencyMs, m.Timestamp)
+	_, err := db.Exec(`INSERT INTO dns_metrics (server, protocol, query_name, query_type, resolved_ip, latency_ms, 
+                       rcode, answer_count, success, error_class, error, timestamp) 
+                       VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.Server, m.Protocol, m.QueryName, m.QueryType, m.ResolvedIP, m.LatencyMs, m.Rcode, m.AnswerCount,
+		m.Success, m.ErrorClass, m.Error, m.Timestamp)
 	return err
 }
 
+// dnsMetricColumns are the columns read by scanDNSMetrics.
+const dnsMetricColumns = `id, server, protocol, COALESCE(query_name, ''), COALESCE(query_type, ''), resolved_ip, 
+                          latency_ms, COALESCE(rcode, ''), COALESCE(answer_count, 0), COALESCE(success, 1), 
+                          COALESCE(error_class, ''), COALESCE(error, ''), timestamp`
+
 // GetDNSHistory retrieves the latest DNS metrics
 func (db *DB) GetDNSHistory(limit int) ([]model.DNSMetric, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
-	rows, err := db.Query(`SELECT id, server, protocol, resolved_ip, latency_ms, timestamp FROM dns_metrics ORDER BY timestamp DESC LIMIT ?`, limit)
+	rows, err := db.Query(`SELECT `+dnsMetricColumns+` FROM dns_metrics ORDER BY timestamp DESC LIMIT ?`, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanDNSMetrics(rows)
+}
+
+// scanDNSMetrics reads rows selected with dnsMetricColumns.
+func scanDNSMetrics(rows *sql.Rows) ([]model.DNSMetric, error) {
 	var metrics []model.DNSMetric
 	for rows.Next() {
 		var m model.DNSMetric
 		var resolvedIP sql.NullString
-		if err := rows.Scan(&m.ID, &m.Server, &m.Protocol, &resolvedIP, &m.LatencyMs, &m.Timestamp); err != nil {
+		if err := rows.Scan(&m.ID, &m.Server, &m.Protocol, &m.QueryName, &m.QueryType, &resolvedIP,
+			&m.LatencyMs, &m.Rcode, &m.AnswerCount, &m.Success, &m.ErrorClass, &m.Error, &m.Timestamp); err != nil {
 			return nil, err
 		}
 		m.ResolvedIP = resolvedIP.String
 		metrics = append(metrics, m)
 	}
-	return metrics, nil
+	return metrics, rows.Err()
+}
+
+// GetDNSAvailability summarizes the measurements between start and end per
+// resolver and transport.
+func (db *DB) GetDNSAvailability(start, end time.Time) ([]model.DNSAvailability, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	rows, err := db.Query(`SELECT server, protocol, COUNT(*), 
+                           SUM(CASE WHEN COALESCE(success, 1) = 1 THEN 0 ELSE 1 END), 
+                           COALESCE(AVG(CASE WHEN COALESCE(success, 1) = 1 THEN latency_ms END), 0) 
+                           FROM dns_metrics WHERE timestamp BETWEEN ? AND ? 
+                           GROUP BY server, protocol ORDER BY server, protocol`, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dns availability: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []model.DNSAvailability
+	index := make(map[string]int)
+	for rows.Next() {
+		var a model.DNSAvailability
+		if err := rows.Scan(&a.Server, &a.Protocol, &a.Checks, &a.Failures, &a.AvgLatencyMs); err != nil {
+			return nil, fmt.Errorf("failed to scan dns availability: %w", err)
+		}
+		if a.Checks > 0 {
+			a.AvailabilityPct = float64(a.Checks-a.Failures) / float64(a.Checks) * 100
+		}
+		index[a.Server+" "+a.Protocol] = len(stats)
+		stats = append(stats, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`SELECT server, protocol, error_class, COUNT(*) FROM dns_metrics 
+                          WHERE timestamp BETWEEN ? AND ? AND success = 0 
+                          GROUP BY server, protocol, error_class`, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dns errors: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var server, protocol string
+		var class sql.NullString
+		var count int
+		if err := rows.Scan(&server, &protocol, &class, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan dns errors: %w", err)
+		}
+		if i, ok := index[server+" "+protocol]; ok {
+			if stats[i].Errors == nil {
+				stats[i].Errors = make(map[string]int)
+			}
+			stats[i].Errors[class.String] += count
+		}
+	}
+
+	return stats, rows.Err()
 }
 
 // AddDNSTarget adds a new DNS target
 func (db *DB) AddDNSTarget(t model.DNSTarget) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
-	_, err := db.Exec(`INSERT INTO dns_targets (name, ip, doh_url) VALUES (?, ?, ?)`, t.Name, t.IP, t.DoHURL)
+	_, err := db.Exec(`INSERT INTO dns_targets (name, ip, doh_url, doh_format, dot_server, query_names, query_types) 
+                       VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		t.Name, t.IP, t.DoHURL, t.DoHFormat, t.DoTServer, strings.Join(t.QueryNames, ","), strings.Join(t.QueryTypes, ","))
 	return err
 }
 
@@ -239,7 +585,8 @@ func (db *DB) DeleteDNSTarget(id int64) error {
 func (db *DB) GetDNSTargets() ([]model.DNSTarget, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
-	rows, err := db.Query(`SELECT id, name, ip, doh_url FROM dns_targets`)
+	rows, err := db.Query(`SELECT id, name, ip, doh_url, COALESCE(doh_format, ''), COALESCE(dot_server, ''), 
+                           COALESCE(query_names, ''), COALESCE(query_types, '') FROM dns_targets`)
 	if err != nil {
 		return nil, err
 	}
@@ -249,11 +596,14 @@ func (db *DB) GetDNSTargets() ([]model.DNSTarget, error) {
 	for rows.Next() {
 		var t model.DNSTarget
 		var ip, url sql.NullString
-		if err := rows.Scan(&t.ID, &t.Name, &ip, &url); err != nil {
+		var names, types string
+		if err := rows.Scan(&t.ID, &t.Name, &ip, &url, &t.DoHFormat, &t.DoTServer, &names, &types); err != nil {
 			return nil, err
 		}
 		t.IP = ip.String
 		t.DoHURL = url.String
+		t.QueryNames = splitList(names)
+		t.QueryTypes = splitList(types)
 		targets = append(targets, t)
 	}
 	return targets, nil
@@ -267,7 +617,7 @@ func (db *DB) GetDNSHistoryTimeRange(start, end time.Time) ([]model.DNSMetric, e
 	// But Go driver handles time.Time by converting.
 	// Ensuring we search correctly.
 
-	rows, err := db.Query(`SELECT id, server, protocol, resolved_ip, latency_ms, timestamp 
+	rows, err := db.Query(`SELECT `+dnsMetricColumns+` 
                            FROM dns_metrics 
                            WHERE timestamp BETWEEN ? AND ? 
                            ORDER BY timestamp ASC`, start, end)
@@ -276,15 +626,5 @@ func (db *DB) GetDNSHistoryTimeRange(start, end time.Time) ([]model.DNSMetric, e
 	}
 	defer rows.Close()
 
-	var metrics []model.DNSMetric
-	for rows.Next() {
-		var m model.DNSMetric
-		var resolvedIP sql.NullString
-		if err := rows.Scan(&m.ID, &m.Server, &m.Protocol, &resolvedIP, &m.LatencyMs, &m.Timestamp); err != nil {
-			return nil, err
-		}
-		m.ResolvedIP = resolvedIP.String
-		metrics = append(metrics, m)
-	}
-	return metrics, nil
+	return scanDNSMetrics(rows)
 }
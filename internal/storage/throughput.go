@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/netpulse/internal/model"
+)
+
+// ThroughputStorage handles throughput test persistence.
+type ThroughputStorage struct {
+	db *DB
+}
+
+// NewThroughputStorage creates a new throughput storage handler.
+func NewThroughputStorage(db *DB) *ThroughputStorage {
+	return &ThroughputStorage{db: db}
+}
+
+// Save stores a throughput test result.
+func (s *ThroughputStorage) Save(result *model.ThroughputResult) error {
+	query := `INSERT INTO throughput_tests (server, protocol, direction, mbps, bytes, duration_ms, 
+			  retransmits, loss_pct, jitter_ms, success, error, timestamp) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	res, err := s.db.Exec(query,
+		result.Server, result.Protocol, result.Direction, result.Mbps, result.Bytes, result.DurationMs,
+		result.Retransmits, result.LossPct, result.JitterMs, result.Success, result.Error, result.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to insert throughput test: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	result.ID = id
+
+	return nil
+}
+
+// GetHistory returns test results since the given time, oldest first. An
+// empty server returns every server.
+func (s *ThroughputStorage) GetHistory(server string, since time.Time) ([]model.ThroughputResult, error) {
+	if server == "" {
+		return s.query(`WHERE timestamp >= ? ORDER BY timestamp ASC`, since)
+	}
+	return s.query(`WHERE server = ? AND timestamp >= ? ORDER BY timestamp ASC`, server, since)
+}
+
+// GetLatest returns the most recent result of each server, protocol and
+// direction.
+func (s *ThroughputStorage) GetLatest() ([]model.ThroughputResult, error) {
+	return s.query(`WHERE id IN (SELECT MAX(id) FROM throughput_tests GROUP BY server, protocol, direction) 
+			  ORDER BY server, protocol, direction`)
+}
+
+func (s *ThroughputStorage) query(where string, args ...interface{}) ([]model.ThroughputResult, error) {
+	query := `SELECT id, server, protocol, direction, COALESCE(mbps, 0), COALESCE(bytes, 0), 
+			  COALESCE(duration_ms, 0), COALESCE(retransmits, 0), COALESCE(loss_pct, 0), 
+			  COALESCE(jitter_ms, 0), success, COALESCE(error, ''), timestamp 
+			  FROM throughput_tests ` + where
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query throughput tests: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.ThroughputResult
+	for rows.Next() {
+		var r model.ThroughputResult
+		if err := rows.Scan(&r.ID, &r.Server, &r.Protocol, &r.Direction, &r.Mbps, &r.Bytes,
+			&r.DurationMs, &r.Retransmits, &r.LossPct, &r.JitterMs, &r.Success, &r.Error, &r.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan throughput test: %w", err)
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
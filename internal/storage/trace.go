@@ -3,6 +3,8 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/user/netpulse/internal/model"
@@ -28,8 +30,8 @@ func (s *TraceStorage) Save(trace *model.TraceResult) error {
 
 	// Insert trace header
 	result, err := tx.Exec(
-		"INSERT INTO traces (target, timestamp) VALUES (?, ?)",
-		trace.Target, trace.Timestamp)
+		"INSERT INTO traces (target, timestamp, mode, as_path) VALUES (?, ?, ?, ?)",
+		trace.Target, trace.Timestamp, trace.Mode, strings.Join(trace.ASPath, ","))
 	if err != nil {
 		return fmt.Errorf("failed to insert trace: %w", err)
 	}
@@ -42,8 +44,8 @@ func (s *TraceStorage) Save(trace *model.TraceResult) error {
 
 	// Insert hops
 	stmt, err := tx.Prepare(
-		`INSERT INTO trace_hops (trace_id, hop_num, ip, hostname, latency_ms, lost) 
-		 VALUES (?, ?, ?, ?, ?, ?)`)
+		`INSERT INTO trace_hops (trace_id, hop_num, ip, hostname, latency_ms, rtts, icmp_type, icmp_code, hop_set, asn, lost) 
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare hop statement: %w", err)
 	}
@@ -55,7 +57,8 @@ func (s *TraceStorage) Save(trace *model.TraceResult) error {
 		if hop.Lost {
 			lost = 1
 		}
-		result, err := stmt.Exec(traceID, hop.HopNum, hop.IP, hop.Hostname, hop.LatencyMs, lost)
+		result, err := stmt.Exec(traceID, hop.HopNum, hop.IP, hop.Hostname, hop.LatencyMs,
+			formatRTTs(hop.RTTs), hop.ICMPType, hop.ICMPCode, strings.Join(hop.HopSet, ","), hop.ASN, lost)
 		if err != nil {
 			return fmt.Errorf("failed to insert hop %d: %w", hop.HopNum, err)
 		}
@@ -67,13 +70,35 @@ func (s *TraceStorage) Save(trace *model.TraceResult) error {
 	return tx.Commit()
 }
 
+// SetHostnames fills in the hostnames of a trace's hops, keyed by IP.
+func (s *TraceStorage) SetHostnames(traceID int64, names map[string]string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE trace_hops SET hostname = ? WHERE trace_id = ? AND ip = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare hostname statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for ip, name := range names {
+		if _, err := stmt.Exec(name, traceID, ip); err != nil {
+			return fmt.Errorf("failed to set hostname of %s: %w", ip, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
 // GetLatest returns the most recent trace for a target.
 func (s *TraceStorage) GetLatest(target string) (*model.TraceResult, error) {
-	query := `SELECT id, target, timestamp FROM traces 
+	query := `SELECT id, target, timestamp, COALESCE(mode, 'classic'), COALESCE(as_path, '') FROM traces 
 			  WHERE target = ? ORDER BY timestamp DESC LIMIT 1`
 
-	var trace model.TraceResult
-	err := s.db.QueryRow(query, target).Scan(&trace.ID, &trace.Target, &trace.Timestamp)
+	trace, err := scanTrace(s.db.QueryRow(query, target))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -90,12 +115,29 @@ func (s *TraceStorage) GetLatest(target string) (*model.TraceResult, error) {
 	return &trace, nil
 }
 
+// GetLatestASPath returns the header of the most recent trace to a target
+// taken in a given mode that has an AS path, without hops.
+func (s *TraceStorage) GetLatestASPath(target, mode string) (*model.TraceResult, error) {
+	query := `SELECT id, target, timestamp, COALESCE(mode, 'classic'), COALESCE(as_path, '') FROM traces 
+			  WHERE target = ? AND COALESCE(mode, 'classic') = ? AND COALESCE(as_path, '') != ''
+			  ORDER BY timestamp DESC LIMIT 1`
+
+	trace, err := scanTrace(s.db.QueryRow(query, target, mode))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest AS path: %w", err)
+	}
+
+	return &trace, nil
+}
+
 // GetByID returns a trace by its ID.
 func (s *TraceStorage) GetByID(id int64) (*model.TraceResult, error) {
-	query := `SELECT id, target, timestamp FROM traces WHERE id = ?`
+	query := `SELECT id, target, timestamp, COALESCE(mode, 'classic'), COALESCE(as_path, '') FROM traces WHERE id = ?`
 
-	var trace model.TraceResult
-	err := s.db.QueryRow(query, id).Scan(&trace.ID, &trace.Target, &trace.Timestamp)
+	trace, err := scanTrace(s.db.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("trace not found")
 	}
@@ -113,7 +155,8 @@ func (s *TraceStorage) GetByID(id int64) (*model.TraceResult, error) {
 }
 
 func (s *TraceStorage) getHops(traceID int64) ([]model.TraceHop, error) {
-	query := `SELECT id, trace_id, hop_num, ip, hostname, latency_ms, lost 
+	query := `SELECT id, trace_id, hop_num, ip, hostname, latency_ms, rtts, icmp_type, icmp_code, hop_set, 
+			  COALESCE(asn, ''), lost 
 			  FROM trace_hops WHERE trace_id = ? ORDER BY hop_num`
 
 	rows, err := s.db.Query(query, traceID)
@@ -126,12 +169,21 @@ func (s *TraceStorage) getHops(traceID int64) ([]model.TraceHop, error) {
 	for rows.Next() {
 		var hop model.TraceHop
 		var lost int
+		var rtts, hopSet sql.NullString
+		var icmpType, icmpCode sql.NullInt64
 		if err := rows.Scan(
 			&hop.ID, &hop.TraceID, &hop.HopNum,
-			&hop.IP, &hop.Hostname, &hop.LatencyMs, &lost); err != nil {
+			&hop.IP, &hop.Hostname, &hop.LatencyMs,
+			&rtts, &icmpType, &icmpCode, &hopSet, &hop.ASN, &lost); err != nil {
 			return nil, fmt.Errorf("failed to scan hop: %w", err)
 		}
 		hop.Lost = lost == 1
+		hop.RTTs = parseRTTs(rtts.String)
+		hop.ICMPType = int(icmpType.Int64)
+		hop.ICMPCode = int(icmpCode.Int64)
+		if hopSet.String != "" {
+			hop.HopSet = strings.Split(hopSet.String, ",")
+		}
 		hops = append(hops, hop)
 	}
 
@@ -140,7 +192,7 @@ func (s *TraceStorage) getHops(traceID int64) ([]model.TraceHop, error) {
 
 // GetHistory returns traces for a target since a given time.
 func (s *TraceStorage) GetHistory(target string, since time.Time) ([]model.TraceResult, error) {
-	query := `SELECT id, target, timestamp FROM traces 
+	query := `SELECT id, target, timestamp, COALESCE(mode, 'classic'), COALESCE(as_path, '') FROM traces 
 			  WHERE target = ? AND timestamp >= ? ORDER BY timestamp DESC LIMIT 20`
 
 	rows, err := s.db.Query(query, target, since)
@@ -151,8 +203,8 @@ func (s *TraceStorage) GetHistory(target string, since time.Time) ([]model.Trace
 	// Collect traces first without fetching hops
 	var traces []model.TraceResult
 	for rows.Next() {
-		var trace model.TraceResult
-		if err := rows.Scan(&trace.ID, &trace.Target, &trace.Timestamp); err != nil {
+		trace, err := scanTrace(rows)
+		if err != nil {
 			rows.Close()
 			return nil, fmt.Errorf("failed to scan trace: %w", err)
 		}
@@ -178,7 +230,7 @@ func (s *TraceStorage) GetHistory(target string, since time.Time) ([]model.Trace
 
 // GetAllHistory returns all traces since a given time.
 func (s *TraceStorage) GetAllHistory(since time.Time) ([]model.TraceResult, error) {
-	query := `SELECT id, target, timestamp FROM traces 
+	query := `SELECT id, target, timestamp, COALESCE(mode, 'classic'), COALESCE(as_path, '') FROM traces 
 			  WHERE timestamp >= ? ORDER BY timestamp DESC LIMIT 20`
 
 	rows, err := s.db.Query(query, since)
@@ -189,8 +241,8 @@ func (s *TraceStorage) GetAllHistory(since time.Time) ([]model.TraceResult, erro
 	// Collect traces first without fetching hops
 	var traces []model.TraceResult
 	for rows.Next() {
-		var trace model.TraceResult
-		if err := rows.Scan(&trace.ID, &trace.Target, &trace.Timestamp); err != nil {
+		trace, err := scanTrace(rows)
+		if err != nil {
 			rows.Close()
 			return nil, fmt.Errorf("failed to scan trace: %w", err)
 		}
@@ -239,7 +291,7 @@ func (s *TraceStorage) GetTracesForTarget(target string, limit int) ([]model.Tra
 	if limit <= 0 {
 		limit = 100
 	}
-	query := `SELECT id, target, timestamp FROM traces 
+	query := `SELECT id, target, timestamp, COALESCE(mode, 'classic'), COALESCE(as_path, '') FROM traces 
 			  WHERE target = ? ORDER BY timestamp DESC LIMIT ?`
 
 	rows, err := s.db.Query(query, target, limit)
@@ -250,8 +302,8 @@ func (s *TraceStorage) GetTracesForTarget(target string, limit int) ([]model.Tra
 
 	var traces []model.TraceResult
 	for rows.Next() {
-		var trace model.TraceResult
-		if err := rows.Scan(&trace.ID, &trace.Target, &trace.Timestamp); err != nil {
+		trace, err := scanTrace(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan trace: %w", err)
 		}
 		traces = append(traces, trace)
@@ -259,3 +311,84 @@ func (s *TraceStorage) GetTracesForTarget(target string, limit int) ([]model.Tra
 
 	return traces, rows.Err()
 }
+
+// GetASPaths returns the headers of the traces since a given time, oldest
+// first and without hops. An empty target returns every target.
+func (s *TraceStorage) GetASPaths(target string, since time.Time) ([]model.TraceResult, error) {
+	query := `SELECT id, target, timestamp, COALESCE(mode, 'classic'), COALESCE(as_path, '') FROM traces
+			  WHERE (? = '' OR target = ?) AND timestamp >= ? ORDER BY timestamp ASC`
+
+	rows, err := s.db.Query(query, target, target, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query AS paths: %w", err)
+	}
+	defer rows.Close()
+
+	var traces []model.TraceResult
+	for rows.Next() {
+		trace, err := scanTrace(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trace: %w", err)
+		}
+		traces = append(traces, trace)
+	}
+
+	return traces, rows.Err()
+}
+
+// GetASNAddresses returns one hop address for each ASN seen since a given
+// time, to look up the name of the network.
+func (s *TraceStorage) GetASNAddresses(since time.Time) (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT h.asn, MIN(h.ip) FROM trace_hops h
+		JOIN traces t ON t.id = h.trace_id
+		WHERE t.timestamp >= ? AND COALESCE(h.asn, '') != ''
+		GROUP BY h.asn`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query hop ASNs: %w", err)
+	}
+	defer rows.Close()
+
+	addrs := make(map[string]string)
+	for rows.Next() {
+		var asn, ip string
+		if err := rows.Scan(&asn, &ip); err != nil {
+			return nil, fmt.Errorf("failed to scan hop ASN: %w", err)
+		}
+		addrs[asn] = ip
+	}
+
+	return addrs, rows.Err()
+}
+
+// scanTrace reads a trace header selected as id, target, timestamp, mode
+// and AS path.
+func scanTrace(row interface{ Scan(...interface{}) error }) (model.TraceResult, error) {
+	var trace model.TraceResult
+	var asPath string
+	err := row.Scan(&trace.ID, &trace.Target, &trace.Timestamp, &trace.Mode, &asPath)
+	trace.ASPath = splitList(asPath)
+	return trace, err
+}
+
+// formatRTTs encodes per-probe RTTs as a comma-separated list.
+func formatRTTs(rtts []float64) string {
+	parts := make([]string, len(rtts))
+	for i, rtt := range rtts {
+		parts[i] = strconv.FormatFloat(rtt, 'f', 3, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseRTTs decodes a list produced by formatRTTs.
+func parseRTTs(s string) []float64 {
+	if s == "" {
+		return nil
+	}
+	var rtts []float64
+	for _, part := range strings.Split(s, ",") {
+		if rtt, err := strconv.ParseFloat(part, 64); err == nil {
+			rtts = append(rtts, rtt)
+		}
+	}
+	return rtts
+}
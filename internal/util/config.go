@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/user/netpulse/internal/schedule"
 )
 
 // Config holds all application configuration.
@@ -21,20 +24,71 @@ type Config struct {
 	TraceInterval      time.Duration `mapstructure:"trace_interval"`
 	PingSweepInterval  time.Duration `mapstructure:"ping_sweep_interval"`
 	PortScanInterval   time.Duration `mapstructure:"port_scan_interval"`
+	CertCheckInterval  time.Duration `mapstructure:"cert_check_interval"`
+	HTTPCheckInterval  time.Duration `mapstructure:"http_check_interval"`
+	ThroughputInterval time.Duration `mapstructure:"throughput_interval"`
+	BufferbloatInterval time.Duration `mapstructure:"bufferbloat_interval"` // 0 disables
+	PMTUInterval       time.Duration `mapstructure:"pmtu_interval"`       // 0 disables
+	DNSIntegrityInterval time.Duration `mapstructure:"dns_integrity_interval"` // 0 disables
+	
+	// Public IP detection
+	IPProviders []IPProvider `mapstructure:"ip_providers"` // empty uses the built-in providers
+	IPQuorum    int          `mapstructure:"ip_quorum"`    // providers that must report the same address
+	
+	// Geolocation: *.mmdb and ip2asn*.tsv files in the data dir are used
+	// first; ip-api.com is only asked when enabled
+	GeoIPOnline bool `mapstructure:"geoip_online"`
 	
 	// Traceroute targets
 	TraceTargets []string `mapstructure:"trace_targets"`
 	
+	// Traceroute engine settings
+	TraceMethod   string `mapstructure:"trace_method"`   // native or system
+	TraceProtocol string `mapstructure:"trace_protocol"` // udp or icmp (native only)
+	TraceProbes   int    `mapstructure:"trace_probes"`   // probes per hop
+	TraceMode     string `mapstructure:"trace_mode"`     // classic, paris or mda
+	TraceRDNS     bool   `mapstructure:"trace_rdns"`     // resolve hop hostnames after each trace
+	TraceRDNSRate int    `mapstructure:"trace_rdns_rate"` // PTR lookups per second
+	
+	// Continuous loss/jitter monitoring of the trace targets
+	LossMonitor       bool          `mapstructure:"loss_monitor"`
+	LossProbeInterval time.Duration `mapstructure:"loss_probe_interval"`
+	LossProbeMethod   string        `mapstructure:"loss_probe_method"` // icmp or tcp
+	LossProbePort     int           `mapstructure:"loss_probe_port"`   // tcp only
+	
 	// Ping sweep settings
 	SweepSubnet     string `mapstructure:"sweep_subnet"`
 	SweepConcurrency int   `mapstructure:"sweep_concurrency"`
 	SweepTimeout    time.Duration `mapstructure:"sweep_timeout"`
+	SweepMethod     string `mapstructure:"sweep_method"` // icmp, tcp or both
+	SweepActiveARP  bool   `mapstructure:"sweep_active_arp"` // broadcast ARP requests (Linux, needs root)
+	SweepMDNS       bool   `mapstructure:"sweep_mdns"`       // mDNS/DNS-SD service discovery
+	SweepSSDP       bool   `mapstructure:"sweep_ssdp"`       // SSDP/UPnP discovery
+	SweepDiscoveryWindow time.Duration `mapstructure:"sweep_discovery_window"` // how long to listen for replies
+	SweepNameResolvers []string `mapstructure:"sweep_name_resolvers"` // dns, netbios, llmnr; highest priority first
 	
 	// Port scan settings
 	ScanPorts       []int  `mapstructure:"scan_ports"`
+	ScanUDPPorts    []int  `mapstructure:"scan_udp_ports"` // empty disables UDP scanning
 	ScanConcurrency int    `mapstructure:"scan_concurrency"`
 	ScanTimeout     time.Duration `mapstructure:"scan_timeout"`
 	
+	// Certificate monitoring
+	CertExpiryDays int `mapstructure:"cert_expiry_days"` // warn this many days before expiry
+	
+	// Synthetic HTTP checks
+	HTTPChecks []HTTPCheck `mapstructure:"http_checks"`
+	
+	// Throughput tests against netpulse throughput servers
+	ThroughputServers  []string      `mapstructure:"throughput_servers"` // host or host:port
+	ThroughputDuration time.Duration `mapstructure:"throughput_duration"`
+	ThroughputUDPMbps  int           `mapstructure:"throughput_udp_mbps"` // UDP send rate
+	ThroughputPort     int           `mapstructure:"throughput_port"`     // throughput-server listen port
+	BufferbloatTarget  string        `mapstructure:"bufferbloat_target"`  // default: first trace target
+	
+	// DNS hijack and interception detection
+	DNSIntegrityNames []string `mapstructure:"dns_integrity_names"` // names compared across resolvers
+	
 	// Report settings
 	ReportOutputDir string `mapstructure:"report_output_dir"`
 	
@@ -43,6 +97,39 @@ type Config struct {
 	
 	// Adaptive intervals
 	StableIntervalMultiplier float64 `mapstructure:"stable_interval_multiplier"`
+	StableIntervalMax        float64 `mapstructure:"stable_interval_max"` // cap, in multiples of the base interval
+	
+	// Per-job timing, keyed by job name (ip_check, port_scan...)
+	Jobs map[string]JobSchedule `mapstructure:"jobs"`
+}
+
+// JobSchedule overrides when a scheduled job runs.
+type JobSchedule struct {
+	Cron       string        `mapstructure:"cron"`         // five-field cron expression; replaces the interval
+	Blackouts  []string      `mapstructure:"blackouts"`    // "HH:MM-HH:MM [days]" windows in which no run starts
+	Jitter     time.Duration `mapstructure:"jitter"`       // random delay of up to this much before each run
+	RunOnStart *bool         `mapstructure:"run_on_start"` // run shortly after boot (default true)
+}
+
+// IPProvider configures a service that reports the public address.
+type IPProvider struct {
+	Name   string `mapstructure:"name"`
+	Method string `mapstructure:"method"` // http (default), stun or dns
+	URL    string `mapstructure:"url"`    // http
+	Server string `mapstructure:"server"` // stun and dns: host[:port]
+	Query  string `mapstructure:"query"`  // dns: name answered with the client address
+	Record string `mapstructure:"record"` // dns: A (A or AAAA by family) or TXT
+}
+
+// HTTPCheck configures a synthetic HTTP(S) endpoint check.
+type HTTPCheck struct {
+	Name         string            `mapstructure:"name"`
+	URL          string            `mapstructure:"url"`
+	Method       string            `mapstructure:"method"`        // default GET
+	Headers      map[string]string `mapstructure:"headers"`
+	ExpectStatus int               `mapstructure:"expect_status"` // 0 accepts any non-error status
+	ExpectBody   string            `mapstructure:"expect_body"`   // required body substring
+	Timeout      time.Duration     `mapstructure:"timeout"`
 }
 
 // DefaultConfig returns configuration with sensible defaults.
@@ -59,6 +146,14 @@ func DefaultConfig() *Config {
 		TraceInterval:     15 * time.Minute,
 		PingSweepInterval: 30 * time.Minute,
 		PortScanInterval:  1 * time.Hour,
+		CertCheckInterval: 6 * time.Hour,
+		HTTPCheckInterval: 5 * time.Minute,
+		ThroughputInterval: 6 * time.Hour,
+		BufferbloatInterval: 12 * time.Hour,
+		PMTUInterval:       1 * time.Hour,
+		DNSIntegrityInterval: 1 * time.Hour,
+		
+		IPQuorum: 2,
 		
 		TraceTargets: []string{
 			"8.8.8.8",      // Google DNS
@@ -66,18 +161,45 @@ func DefaultConfig() *Config {
 			"185.97.0.1",   // Middle East target
 		},
 		
+		TraceMethod:   "native",
+		TraceProtocol: "udp",
+		TraceProbes:   3,
+		TraceMode:     "paris",
+		TraceRDNS:     true,
+		TraceRDNSRate: 10,
+		
+		LossMonitor:       true,
+		LossProbeInterval: time.Second,
+		LossProbeMethod:   "icmp",
+		LossProbePort:     443,
+		
 		SweepSubnet:      "192.168.1.0/24",
 		SweepConcurrency: 50,
 		SweepTimeout:     2 * time.Second,
+		SweepMethod:      "both",
+		SweepMDNS:        true,
+		SweepSSDP:        true,
+		SweepDiscoveryWindow: 3 * time.Second,
+		SweepNameResolvers: []string{"dns", "netbios", "llmnr"},
 		
 		ScanPorts:        GetTopPorts(50),
+		ScanUDPPorts:     []int{53, 123, 161, 1900, 5353},
 		ScanConcurrency:  20,
 		ScanTimeout:      3 * time.Second,
 		
+		CertExpiryDays: 30,
+		
+		ThroughputDuration: 10 * time.Second,
+		ThroughputUDPMbps:  10,
+		ThroughputPort:     5301,
+		
+		DNSIntegrityNames: []string{"example.com"},
+		
 		ReportOutputDir: filepath.Join(dataDir, "reports"),
 		WebPort:         8080,
 		
 		StableIntervalMultiplier: 2.0,
+		StableIntervalMax:        8.0,
 	}
 }
 
@@ -100,10 +222,38 @@ func LoadConfig() (*Config, error) {
 	viper.SetDefault("log_level", cfg.LogLevel)
 	viper.SetDefault("ip_check_interval", cfg.IPCheckInterval)
 	viper.SetDefault("trace_interval", cfg.TraceInterval)
+	viper.SetDefault("cert_check_interval", cfg.CertCheckInterval)
+	viper.SetDefault("cert_expiry_days", cfg.CertExpiryDays)
+	viper.SetDefault("http_check_interval", cfg.HTTPCheckInterval)
+	viper.SetDefault("throughput_interval", cfg.ThroughputInterval)
+	viper.SetDefault("throughput_duration", cfg.ThroughputDuration)
+	viper.SetDefault("throughput_udp_mbps", cfg.ThroughputUDPMbps)
+	viper.SetDefault("throughput_port", cfg.ThroughputPort)
+	viper.SetDefault("bufferbloat_interval", cfg.BufferbloatInterval)
+	viper.SetDefault("pmtu_interval", cfg.PMTUInterval)
+	viper.SetDefault("dns_integrity_interval", cfg.DNSIntegrityInterval)
+	viper.SetDefault("dns_integrity_names", cfg.DNSIntegrityNames)
+	viper.SetDefault("ip_quorum", cfg.IPQuorum)
+	viper.SetDefault("geoip_online", cfg.GeoIPOnline)
 	viper.SetDefault("trace_targets", cfg.TraceTargets)
+	viper.SetDefault("trace_method", cfg.TraceMethod)
+	viper.SetDefault("trace_probes", cfg.TraceProbes)
+	viper.SetDefault("trace_mode", cfg.TraceMode)
+	viper.SetDefault("trace_rdns", cfg.TraceRDNS)
+	viper.SetDefault("trace_rdns_rate", cfg.TraceRDNSRate)
+	viper.SetDefault("loss_monitor", cfg.LossMonitor)
+	viper.SetDefault("loss_probe_interval", cfg.LossProbeInterval)
+	viper.SetDefault("loss_probe_method", cfg.LossProbeMethod)
+	viper.SetDefault("loss_probe_port", cfg.LossProbePort)
 	viper.SetDefault("sweep_subnet", cfg.SweepSubnet)
 	viper.SetDefault("sweep_concurrency", cfg.SweepConcurrency)
+	viper.SetDefault("sweep_method", cfg.SweepMethod)
+	viper.SetDefault("sweep_mdns", cfg.SweepMDNS)
+	viper.SetDefault("sweep_ssdp", cfg.SweepSSDP)
+	viper.SetDefault("sweep_discovery_window", cfg.SweepDiscoveryWindow)
+	viper.SetDefault("sweep_name_resolvers", cfg.SweepNameResolvers)
 	viper.SetDefault("scan_ports", cfg.ScanPorts)
+	viper.SetDefault("scan_udp_ports", cfg.ScanUDPPorts)
 	viper.SetDefault("scan_concurrency", cfg.ScanConcurrency)
 	viper.SetDefault("web_port", cfg.WebPort)
 	
@@ -119,9 +269,50 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 	
+	if err := cfg.validateJobs(); err != nil {
+		return nil, err
+	}
+	
 	return cfg, nil
 }
 
+// JobNames are the scheduled jobs that can be configured under jobs.
+var JobNames = []string{
+	"ip_check", "traceroute", "pmtu", "dns_integrity", "ping_sweep",
+	"port_scan", "cert_check", "http_check", "throughput", "bufferbloat",
+}
+
+// validateJobs checks the names, cron expressions and windows of the
+// per-job schedules.
+func (c *Config) validateJobs() error {
+	for name, job := range c.Jobs {
+		known := false
+		for _, n := range JobNames {
+			if n == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("jobs.%s: unknown job (expected one of %s)", name, strings.Join(JobNames, ", "))
+		}
+		if job.Cron != "" {
+			if _, err := schedule.ParseCron(job.Cron); err != nil {
+				return fmt.Errorf("jobs.%s: %w", name, err)
+			}
+		}
+		for _, blackout := range job.Blackouts {
+			if _, err := schedule.ParseWindow(blackout); err != nil {
+				return fmt.Errorf("jobs.%s: %w", name, err)
+			}
+		}
+		if job.Jitter < 0 {
+			return fmt.Errorf("jobs.%s: negative jitter", name)
+		}
+	}
+	return nil
+}
+
 // GetTopPorts returns the top N most common ports.
 func GetTopPorts(n int) []int {
 	topPorts := []int{
@@ -4,8 +4,13 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/user/netpulse/internal/geoip"
+	"github.com/user/netpulse/internal/model"
+	"github.com/user/netpulse/internal/probes"
+	"github.com/user/netpulse/internal/rdns"
 	"github.com/user/netpulse/internal/storage"
 	"github.com/user/netpulse/internal/util"
 )
@@ -14,11 +19,12 @@ import (
 type AnalyticsHandlers struct {
 	db     *storage.DB
 	config *util.Config
+	geo    *geoip.Resolver
 }
 
 // NewAnalyticsHandlers creates analytics handlers.
-func NewAnalyticsHandlers(db *storage.DB, cfg *util.Config) *AnalyticsHandlers {
-	return &AnalyticsHandlers{db: db, config: cfg}
+func NewAnalyticsHandlers(db *storage.DB, cfg *util.Config, geo *geoip.Resolver) *AnalyticsHandlers {
+	return &AnalyticsHandlers{db: db, config: cfg, geo: geo}
 }
 
 // TopologyData represents network topology for visualization.
@@ -34,6 +40,10 @@ type TopologyNode struct {
 	Type     string  `json:"type"` // "source", "hop", "target"
 	AvgLatency float64 `json:"avg_latency"`
 	HitCount int     `json:"hit_count"`
+	
+	// From the reverse DNS name of the hop, when it has one
+	Hostname string            `json:"hostname,omitempty"`
+	Router   *model.RouterHint `json:"router,omitempty"`
 }
 
 // TopologyEdge represents a connection between nodes.
@@ -51,6 +61,14 @@ type LatencyPoint struct {
 	HopNum    int       `json:"hop_num"`
 	IP        string    `json:"ip"`
 	LatencyMs float64   `json:"latency_ms"`
+	
+	// Set for points from the continuous loss monitor
+	Source   string  `json:"source"` // stream or trace
+	LossPct  float64 `json:"loss_pct,omitempty"`
+	MinMs    float64 `json:"min_ms,omitempty"`
+	MaxMs    float64 `json:"max_ms,omitempty"`
+	JitterMs float64 `json:"jitter_ms,omitempty"`
+	P95Ms    float64 `json:"p95_ms,omitempty"`
 }
 
 // GetTopology returns network topology data for visualization.
@@ -65,68 +83,30 @@ func (h *AnalyticsHandlers) GetTopology(w http.ResponseWriter, r *http.Request)
 	}
 	
 	traceStorage := storage.NewTraceStorage(h.db)
-	var traces []struct {
-		Target string
-		Hops   []struct {
-			IP string
-			LatencyMs float64
-			HopNum int
-		}
-	}
+	var traces []topologyTrace
 	
 	// Get traces based on filter
+	var rawTraces []model.TraceResult
+	var err error
 	if target != "" {
-		rawTraces, err := traceStorage.GetHistory(target, since)
-		if err != nil {
-			writeJSON(w, TopologyData{})
-			return
-		}
-		for _, t := range rawTraces {
-			trace := struct {
-				Target string
-				Hops   []struct {
-					IP string
-					LatencyMs float64
-					HopNum int
-				}
-			}{Target: t.Target}
-			for _, hop := range t.Hops {
-				if !hop.Lost {
-					trace.Hops = append(trace.Hops, struct {
-						IP string
-						LatencyMs float64
-						HopNum int
-					}{IP: hop.IP, LatencyMs: hop.LatencyMs, HopNum: hop.HopNum})
-				}
-			}
-			traces = append(traces, trace)
-		}
+		rawTraces, err = traceStorage.GetHistory(target, since)
 	} else {
-		rawTraces, err := traceStorage.GetAllHistory(since)
-		if err != nil {
-			writeJSON(w, TopologyData{})
-			return
-		}
-		for _, t := range rawTraces {
-			trace := struct {
-				Target string
-				Hops   []struct {
-					IP string
-					LatencyMs float64
-					HopNum int
-				}
-			}{Target: t.Target}
-			for _, hop := range t.Hops {
-				if !hop.Lost {
-					trace.Hops = append(trace.Hops, struct {
-						IP string
-						LatencyMs float64
-						HopNum int
-					}{IP: hop.IP, LatencyMs: hop.LatencyMs, HopNum: hop.HopNum})
-				}
+		rawTraces, err = traceStorage.GetAllHistory(since)
+	}
+	if err != nil {
+		writeJSON(w, TopologyData{})
+		return
+	}
+	for _, t := range rawTraces {
+		trace := topologyTrace{Target: t.Target}
+		for _, hop := range t.Hops {
+			if !hop.Lost {
+				trace.Hops = append(trace.Hops, topologyHop{
+					IP: hop.IP, Hostname: hop.Hostname, LatencyMs: hop.LatencyMs, HopNum: hop.HopNum,
+				})
 			}
-			traces = append(traces, trace)
 		}
+		traces = append(traces, trace)
 	}
 	
 	// Build topology
@@ -134,14 +114,20 @@ func (h *AnalyticsHandlers) GetTopology(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, topology)
 }
 
-func (h *AnalyticsHandlers) buildTopology(traces []struct {
+// topologyTrace is a trace reduced to its answered hops.
+type topologyTrace struct {
 	Target string
-	Hops   []struct {
-		IP string
-		LatencyMs float64
-		HopNum int
-	}
-}) TopologyData {
+	Hops   []topologyHop
+}
+
+type topologyHop struct {
+	IP        string
+	Hostname  string
+	LatencyMs float64
+	HopNum    int
+}
+
+func (h *AnalyticsHandlers) buildTopology(traces []topologyTrace) TopologyData {
 	nodeMap := make(map[string]*TopologyNode)
 	edgeMap := make(map[string]*TopologyEdge)
 	
@@ -175,6 +161,11 @@ func (h *AnalyticsHandlers) buildTopology(traces []struct {
 					HitCount: 1,
 				}
 			}
+			if node := nodeMap[nodeID]; node.Hostname == "" && hop.Hostname != "" {
+				node.Hostname = hop.Hostname
+				node.Router = rdns.ParseRouterName(hop.Hostname)
+				node.Label = node.Router.Name
+			}
 			
 			// Update or create edge
 			edgeID := prevNode + "->" + nodeID
@@ -214,7 +205,9 @@ func (h *AnalyticsHandlers) buildTopology(traces []struct {
 	return TopologyData{Nodes: nodes, Edges: edges}
 }
 
-// GetLatencyTrends returns latency over time for charting.
+// GetLatencyTrends returns latency over time for charting. Targets covered by
+// the continuous loss monitor get one point per minute; others fall back to
+// traceroute RTTs.
 func (h *AnalyticsHandlers) GetLatencyTrends(w http.ResponseWriter, r *http.Request) {
 	target := r.URL.Query().Get("target")
 	since := time.Now().Add(-24 * time.Hour)
@@ -225,8 +218,13 @@ func (h *AnalyticsHandlers) GetLatencyTrends(w http.ResponseWriter, r *http.Requ
 		}
 	}
 	
+	points, streamed := h.streamLatency(target, since)
+	if target != "" && streamed[target] {
+		writeJSON(w, points)
+		return
+	}
+	
 	traceStorage := storage.NewTraceStorage(h.db)
-	var points []LatencyPoint
 	
 	if target != "" {
 		traces, err := traceStorage.GetHistory(target, since)
@@ -243,6 +241,7 @@ func (h *AnalyticsHandlers) GetLatencyTrends(w http.ResponseWriter, r *http.Requ
 						HopNum:    hop.HopNum,
 						IP:        hop.IP,
 						LatencyMs: hop.LatencyMs,
+						Source:    "trace",
 					})
 				}
 			}
@@ -254,6 +253,9 @@ func (h *AnalyticsHandlers) GetLatencyTrends(w http.ResponseWriter, r *http.Requ
 			return
 		}
 		for _, trace := range traces {
+			if streamed[trace.Target] {
+				continue
+			}
 			// Only include final hop (target) for overview
 			for _, hop := range trace.Hops {
 				if hop.IP == trace.Target && !hop.Lost {
@@ -263,6 +265,7 @@ func (h *AnalyticsHandlers) GetLatencyTrends(w http.ResponseWriter, r *http.Requ
 						HopNum:    hop.HopNum,
 						IP:        hop.IP,
 						LatencyMs: hop.LatencyMs,
+						Source:    "trace",
 					})
 				}
 			}
@@ -272,9 +275,38 @@ func (h *AnalyticsHandlers) GetLatencyTrends(w http.ResponseWriter, r *http.Requ
 	writeJSON(w, points)
 }
 
+// streamLatency returns the loss monitor's per-minute points and the set of
+// targets they cover.
+func (h *AnalyticsHandlers) streamLatency(target string, since time.Time) ([]LatencyPoint, map[string]bool) {
+	streamed := make(map[string]bool)
+	history, err := storage.NewLossStorage(h.db).GetHistory(target, since)
+	if err != nil {
+		return nil, streamed
+	}
+	
+	points := make([]LatencyPoint, 0, len(history))
+	for _, st := range history {
+		streamed[st.Target] = true
+		points = append(points, LatencyPoint{
+			Timestamp: st.Minute,
+			Target:    st.Target,
+			IP:        st.Target,
+			LatencyMs: st.AvgMs,
+			Source:    "stream",
+			LossPct:   st.LossPct,
+			MinMs:     st.MinMs,
+			MaxMs:     st.MaxMs,
+			JitterMs:  st.JitterMs,
+			P95Ms:     st.P95Ms,
+		})
+	}
+	return points, streamed
+}
+
 // RouteChange represents a detected route change.
 type RouteChange struct {
 	Target      string    `json:"target"`
+	Mode        string    `json:"mode"`
 	DetectedAt  time.Time `json:"detected_at"`
 	OldPath     []string  `json:"old_path"`
 	NewPath     []string  `json:"new_path"`
@@ -292,30 +324,41 @@ func (h *AnalyticsHandlers) GetAnomalies(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	
-	// Group traces by target
-	targetTraces := make(map[string][]struct {
+	// Group traces by target and mode, so that only traces taken the same
+	// way are compared with each other
+	type traceKey struct {
+		Target string
+		Mode   string
+	}
+	type tracePath struct {
 		Timestamp time.Time
 		Hops      []string
-	})
+		Sets      [][]string
+	}
+	targetTraces := make(map[traceKey][]tracePath)
 	
 	for _, trace := range traces {
 		var hops []string
+		var sets [][]string
 		for _, hop := range trace.Hops {
 			if !hop.Lost {
 				hops = append(hops, hop.IP)
 			} else {
 				hops = append(hops, "*")
 			}
+			sets = append(sets, probes.HopAddresses(hop))
 		}
-		targetTraces[trace.Target] = append(targetTraces[trace.Target], struct {
-			Timestamp time.Time
-			Hops      []string
-		}{Timestamp: trace.Timestamp, Hops: hops})
+		key := traceKey{Target: trace.Target, Mode: trace.Mode}
+		targetTraces[key] = append(targetTraces[key], tracePath{
+			Timestamp: trace.Timestamp,
+			Hops:      hops,
+			Sets:      sets,
+		})
 	}
 	
 	// Detect route changes
 	var changes []RouteChange
-	for target, traceList := range targetTraces {
+	for key, traceList := range targetTraces {
 		if len(traceList) < 2 {
 			continue
 		}
@@ -330,10 +373,11 @@ func (h *AnalyticsHandlers) GetAnomalies(w http.ResponseWriter, r *http.Request)
 			current := traceList[i]
 			previous := traceList[i+1]
 			
-			changedHops := findChangedHops(previous.Hops, current.Hops)
+			changedHops := findChangedHops(previous.Sets, current.Sets)
 			if len(changedHops) > 0 {
 				changes = append(changes, RouteChange{
-					Target:      target,
+					Target:      key.Target,
+					Mode:        key.Mode,
 					DetectedAt:  current.Timestamp,
 					OldPath:     previous.Hops,
 					NewPath:     current.Hops,
@@ -346,7 +390,10 @@ func (h *AnalyticsHandlers) GetAnomalies(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, changes)
 }
 
-func findChangedHops(old, new []string) []int {
+// findChangedHops returns the hop numbers whose address sets do not overlap.
+// Load-balanced hops answering from a different member of the same set are
+// not a change; timeouts are ignored.
+func findChangedHops(old, new [][]string) []int {
 	var changed []int
 	maxLen := len(old)
 	if len(new) > maxLen {
@@ -354,7 +401,7 @@ func findChangedHops(old, new []string) []int {
 	}
 	
 	for i := 0; i < maxLen; i++ {
-		var oldHop, newHop string
+		var oldHop, newHop []string
 		if i < len(old) {
 			oldHop = old[i]
 		}
@@ -362,8 +409,20 @@ func findChangedHops(old, new []string) []int {
 			newHop = new[i]
 		}
 		
-		// Ignore * (timeout) changes
-		if oldHop != newHop && oldHop != "*" && newHop != "*" {
+		// The path got longer or shorter
+		if i >= len(old) || i >= len(new) {
+			if oldHop != nil || newHop != nil {
+				changed = append(changed, i+1)
+			}
+			continue
+		}
+		
+		// Ignore timeout changes
+		if oldHop == nil || newHop == nil {
+			continue
+		}
+		
+		if !probes.SharesAddress(oldHop, newHop) {
 			changed = append(changed, i+1)
 		}
 	}
@@ -371,9 +430,11 @@ func findChangedHops(old, new []string) []int {
 	return changed
 }
 
-// MermaidDiagram returns a Mermaid diagram string for topology.
+// MermaidDiagram returns a Mermaid diagram string for topology. With
+// group=as, hops are placed in one subgraph per ASN.
 func (h *AnalyticsHandlers) MermaidDiagram(w http.ResponseWriter, r *http.Request) {
 	target := r.URL.Query().Get("target")
+	groupByAS := r.URL.Query().Get("group") == "as"
 	since := time.Now().Add(-24 * time.Hour)
 	
 	traceStorage := storage.NewTraceStorage(h.db)
@@ -385,7 +446,7 @@ func (h *AnalyticsHandlers) MermaidDiagram(w http.ResponseWriter, r *http.Reques
 			trace := traceWithMeta{Timestamp: t.Timestamp}
 			for _, hop := range t.Hops {
 				if !hop.Lost {
-					trace.Hops = append(trace.Hops, hopWithLatency{IP: hop.IP, LatencyMs: hop.LatencyMs})
+					trace.Hops = append(trace.Hops, hopWithLatency{IP: hop.IP, Hostname: hop.Hostname, ASN: hop.ASN, LatencyMs: hop.LatencyMs})
 				}
 			}
 			traces = append(traces, trace)
@@ -397,7 +458,7 @@ func (h *AnalyticsHandlers) MermaidDiagram(w http.ResponseWriter, r *http.Reques
 			trace := traceWithMeta{Timestamp: t.Timestamp}
 			for _, hop := range t.Hops {
 				if !hop.Lost {
-					trace.Hops = append(trace.Hops, hopWithLatency{IP: hop.IP, LatencyMs: hop.LatencyMs})
+					trace.Hops = append(trace.Hops, hopWithLatency{IP: hop.IP, Hostname: hop.Hostname, ASN: hop.ASN, LatencyMs: hop.LatencyMs})
 				}
 			}
 			traces = append(traces, trace)
@@ -408,11 +469,14 @@ func (h *AnalyticsHandlers) MermaidDiagram(w http.ResponseWriter, r *http.Reques
 	ipStorage := storage.NewIPStorage(h.db)
 	ipHistory, _ := ipStorage.GetHistory(since)
 	
-	// Find public IP for a given timestamp
-	findPublicIP := func(ts time.Time) string {
+	// Find public IP of the trace's address family for a given timestamp
+	findPublicIP := func(ts time.Time, family string) string {
 		var closest string
 		var minDiff time.Duration = 24 * time.Hour
 		for _, ip := range ipHistory {
+			if family != "" && ip.Family != family {
+				continue
+			}
 			diff := ts.Sub(ip.Timestamp)
 			if diff < 0 {
 				diff = -diff
@@ -428,7 +492,11 @@ func (h *AnalyticsHandlers) MermaidDiagram(w http.ResponseWriter, r *http.Reques
 	// Group traces by public IP
 	tracesByIP := make(map[string][]traceWithMeta)
 	for _, trace := range traces {
-		pip := findPublicIP(trace.Timestamp)
+		family := ""
+		if len(trace.Hops) > 0 {
+			family = probes.IPFamily(trace.Hops[0].IP)
+		}
+		pip := findPublicIP(trace.Timestamp, family)
 		if pip == "" {
 			pip = "unknown"
 		}
@@ -454,6 +522,9 @@ func (h *AnalyticsHandlers) MermaidDiagram(w http.ResponseWriter, r *http.Reques
 	
 	seen := make(map[string]bool)
 	edges := make(map[string]*edgeInfo)
+	// Hop node declarations per ASN, in order of first appearance
+	var asns []string
+	asNodes := make(map[string][]string)
 	
 	for pip, pipTraces := range tracesByIP {
 		sourceNode := sourceNodes[pip]
@@ -463,7 +534,19 @@ func (h *AnalyticsHandlers) MermaidDiagram(w http.ResponseWriter, r *http.Reques
 			for _, hop := range trace.Hops {
 				nodeID := "N" + sanitizeForMermaid(hop.IP)
 				if !seen[nodeID] {
-					diagram += "    " + nodeID + "[\"" + hop.IP + "\"]\n"
+					label := hop.IP
+					if hop.Hostname != "" {
+						label = rdns.ParseRouterName(hop.Hostname).Name + "<br/>" + hop.IP
+					}
+					node := nodeID + "[\"" + label + "\"]"
+					if groupByAS && hop.ASN != "" {
+						if _, ok := asNodes[hop.ASN]; !ok {
+							asns = append(asns, hop.ASN)
+						}
+						asNodes[hop.ASN] = append(asNodes[hop.ASN], node)
+					} else {
+						diagram += "    " + node + "\n"
+					}
 					seen[nodeID] = true
 				}
 				edgeKey := prev + "->" + nodeID
@@ -480,6 +563,17 @@ func (h *AnalyticsHandlers) MermaidDiagram(w http.ResponseWriter, r *http.Reques
 		}
 	}
 	
+	if len(asns) > 0 {
+		names := h.asNames(r.Context(), since)
+		for _, asn := range asns {
+			diagram += "    subgraph " + asn + "[\"" + asLabel(asn, names) + "\"]\n"
+			for _, node := range asNodes[asn] {
+				diagram += "        " + node + "\n"
+			}
+			diagram += "    end\n"
+		}
+	}
+	
 	// Add edges with average cumulative latency
 	for _, edge := range edges {
 		avgLatency := edge.totalLatency / float64(edge.count)
@@ -505,6 +599,8 @@ type traceWithMeta struct {
 
 type hopWithLatency struct {
 	IP        string
+	Hostname  string
+	ASN       string
 	LatencyMs float64
 }
 
@@ -515,13 +611,11 @@ type edgeInfo struct {
 	count        int
 }
 
+// mermaidIDReplacer keeps the separators of an address apart in a node ID,
+// so 1:23:: and 12:3:: stay different nodes.
+var mermaidIDReplacer = strings.NewReplacer(".", "_", ":", "_", "%", "_")
+
 func sanitizeForMermaid(s string) string {
-	result := ""
-	for _, c := range s {
-		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
-			result += string(c)
-		}
-	}
-	return result
+	return mermaidIDReplacer.Replace(s)
 }
 
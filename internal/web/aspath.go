@@ -0,0 +1,108 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/user/netpulse/internal/geoip"
+	"github.com/user/netpulse/internal/storage"
+)
+
+// ASPathEntry is the AS path of one trace.
+type ASPathEntry struct {
+	TraceID   int64     `json:"trace_id"`
+	Target    string    `json:"target"`
+	Mode      string    `json:"mode"`
+	Timestamp time.Time `json:"timestamp"`
+	ASPath    []string  `json:"as_path"`
+}
+
+// ASPathChange is a move of the traffic to a target to other networks
+// between two consecutive traces.
+type ASPathChange struct {
+	Target     string    `json:"target"`
+	Mode       string    `json:"mode"`
+	DetectedAt time.Time `json:"detected_at"`
+	OldPath    []string  `json:"old_path"`
+	NewPath    []string  `json:"new_path"`
+}
+
+// ASPathsData is the AS path history of the traced targets.
+type ASPathsData struct {
+	Paths   []ASPathEntry     `json:"paths"`
+	Changes []ASPathChange    `json:"changes"`
+	Names   map[string]string `json:"names"` // ASN to network name
+}
+
+// GetASPaths returns the AS path of every trace, oldest first, and the
+// AS-level route changes between them.
+func (h *AnalyticsHandlers) GetASPaths(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	since := parseSince(r, 24*time.Hour)
+
+	traces, err := storage.NewTraceStorage(h.db).GetASPaths(target, since)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	data := ASPathsData{
+		Paths:   []ASPathEntry{},
+		Changes: []ASPathChange{},
+		Names:   h.asNames(r.Context(), since),
+	}
+	// Last path per target and mode; empty paths do not replace it so a
+	// trace that reached no public hop is not reported as a change
+	last := make(map[string][]string)
+	for _, t := range traces {
+		path := t.ASPath
+		if path == nil {
+			path = []string{}
+		}
+		data.Paths = append(data.Paths, ASPathEntry{
+			TraceID: t.ID, Target: t.Target, Mode: t.Mode, Timestamp: t.Timestamp, ASPath: path,
+		})
+		if len(path) == 0 {
+			continue
+		}
+		key := t.Target + "|" + t.Mode
+		if prev, ok := last[key]; ok && geoip.ASPathChanged(prev, path) {
+			data.Changes = append(data.Changes, ASPathChange{
+				Target: t.Target, Mode: t.Mode, DetectedAt: t.Timestamp, OldPath: prev, NewPath: path,
+			})
+		}
+		last[key] = path
+	}
+
+	writeJSON(w, data)
+}
+
+// asNames returns the network name of each ASN seen in traces since a
+// given time.
+func (h *AnalyticsHandlers) asNames(ctx context.Context, since time.Time) map[string]string {
+	names := make(map[string]string)
+	if h.geo == nil {
+		return names
+	}
+	addrs, err := storage.NewTraceStorage(h.db).GetASNAddresses(since)
+	if err != nil {
+		return names
+	}
+	for asn, ip := range addrs {
+		if info, err := h.geo.Lookup(ctx, ip); err == nil && info.Org != "" {
+			names[asn] = info.Org
+		}
+	}
+	return names
+}
+
+// asLabel returns the subgraph title of an ASN in Mermaid diagrams.
+func asLabel(asn string, names map[string]string) string {
+	label := asn
+	if name := names[asn]; name != "" {
+		label += " " + strings.ReplaceAll(name, `"`, "'")
+	}
+	return label
+}
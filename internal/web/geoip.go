@@ -1,97 +1,18 @@
 package web
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
-	"sync"
 	"time"
 
+	"github.com/user/netpulse/internal/geoip"
+	"github.com/user/netpulse/internal/model"
+	"github.com/user/netpulse/internal/probes"
+	"github.com/user/netpulse/internal/rdns"
 	"github.com/user/netpulse/internal/storage"
 )
 
-// GeoIP represents geographical IP information.
-type GeoIP struct {
-	IP      string  `json:"ip"`
-	Lat     float64 `json:"lat"`
-	Lon     float64 `json:"lon"`
-	City    string  `json:"city"`
-	Country string  `json:"country"`
-	ISP     string  `json:"isp"`
-	AS      string  `json:"as"`
-	Org     string  `json:"org"`
-}
-
-// GeoIPCache caches GeoIP lookups.
-type GeoIPCache struct {
-	mu    sync.RWMutex
-	cache map[string]*GeoIP
-	ttl   time.Duration
-}
-
-// NewGeoIPCache creates a new cache.
-func NewGeoIPCache() *GeoIPCache {
-	return &GeoIPCache{
-		cache: make(map[string]*GeoIP),
-		ttl:   24 * time.Hour,
-	}
-}
-
-var geoCache = NewGeoIPCache()
-
-// LookupIP looks up geographical information for an IP.
-func LookupIP(ip string) (*GeoIP, error) {
-	// Check cache
-	geoCache.mu.RLock()
-	if cached, ok := geoCache.cache[ip]; ok {
-		geoCache.mu.RUnlock()
-		return cached, nil
-	}
-	geoCache.mu.RUnlock()
-	
-	// Use ip-api.com (free, no API key, 45 req/min limit)
-	// fields: status,country,city,lat,lon,isp,as,org,query
-	resp, err := http.Get("http://ip-api.com/json/" + ip + "?fields=status,country,city,lat,lon,isp,as,org,query")
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	
-	var result struct {
-		Status  string  `json:"status"`
-		Country string  `json:"country"`
-		City    string  `json:"city"`
-		Lat     float64 `json:"lat"`
-		Lon     float64 `json:"lon"`
-		ISP     string  `json:"isp"`
-		AS      string  `json:"as"`
-		Org     string  `json:"org"`
-		Query   string  `json:"query"`
-	}
-	
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-	
-	geo := &GeoIP{
-		IP:      ip,
-		Lat:     result.Lat,
-		Lon:     result.Lon,
-		City:    result.City,
-		Country: result.Country,
-		ISP:     result.ISP,
-		AS:      result.AS,
-		Org:     result.Org,
-	}
-	
-	// Cache result
-	geoCache.mu.Lock()
-	geoCache.cache[ip] = geo
-	geoCache.mu.Unlock()
-	
-	return geo, nil
-}
-
 // GeoIPHandler handles single IP lookup.
 func (h *Handlers) GeoIPHandler(w http.ResponseWriter, r *http.Request) {
 	ip := r.URL.Query().Get("ip")
@@ -100,9 +21,13 @@ func (h *Handlers) GeoIPHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	geo, err := LookupIP(ip)
+	geo, err := h.geo.Lookup(r.Context(), ip)
+	if errors.Is(err, geoip.ErrNotFound) {
+		writeError(w, err, http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		writeError(w, err, http.StatusInternalServerError)
+		writeError(w, err, http.StatusBadRequest)
 		return
 	}
 	
@@ -129,6 +54,24 @@ type HopGeoData struct {
 	Country   string  `json:"country,omitempty"`
 	AS        string  `json:"as,omitempty"`
 	Org       string  `json:"org,omitempty"`
+	Hostname  string  `json:"hostname,omitempty"`
+	Router    *model.RouterHint `json:"router,omitempty"` // Parsed from the hostname
+}
+
+// setGeo copies the location and network owner of the hop's address.
+func (h *HopGeoData) setGeo(geo *model.GeoInfo) {
+	h.Lat = geo.Lat
+	h.Lon = geo.Lon
+	h.City = geo.City
+	h.Country = geo.Country
+	if h.Country == "" {
+		h.Country = geo.CountryCode
+	}
+	h.AS = geo.ASN
+	h.Org = geo.Org
+	if h.Org == "" {
+		h.Org = geo.ISP
+	}
 }
 
 // TraceGeoHandler returns a trace with geo coordinates for all hops.
@@ -164,10 +107,22 @@ func (h *Handlers) TraceGeoHandler(w http.ResponseWriter, r *http.Request) {
 	ipStorage := storage.NewIPStorage(h.db)
 	ipHistory, _ := ipStorage.GetHistory(time.Now().Add(-24 * 365 * time.Hour)) // Get all history
 	
+	// Only consider addresses of the same family as the traced path
+	var family string
+	for _, hop := range trace.Hops {
+		if !hop.Lost && hop.IP != "" {
+			family = probes.IPFamily(hop.IP)
+			break
+		}
+	}
+	
 	var publicIP string
 	var minDiff time.Duration = 24 * time.Hour
 	
 	for _, ip := range ipHistory {
+		if family != "" && ip.Family != family {
+			continue
+		}
 		diff := trace.Timestamp.Sub(ip.Timestamp)
 		if diff < 0 {
 			diff = -diff
@@ -186,13 +141,8 @@ func (h *Handlers) TraceGeoHandler(w http.ResponseWriter, r *http.Request) {
 			LatencyMs: 0,
 			Lost:      false,
 		}
-		if geo, err := LookupIP(publicIP); err == nil && geo.Lat != 0 {
-			hopGeo.Lat = geo.Lat
-			hopGeo.Lon = geo.Lon
-			hopGeo.City = geo.City
-			hopGeo.Country = geo.Country
-			hopGeo.AS = geo.AS
-			hopGeo.Org = geo.Org
+		if geo, err := h.geo.Lookup(r.Context(), publicIP); err == nil {
+			hopGeo.setGeo(geo)
 		}
 		result.Hops = append(result.Hops, hopGeo)
 	}
@@ -203,20 +153,19 @@ func (h *Handlers) TraceGeoHandler(w http.ResponseWriter, r *http.Request) {
 			IP:        hop.IP,
 			LatencyMs: hop.LatencyMs,
 			Lost:      hop.Lost,
+			Hostname:  hop.Hostname,
+			Router:    rdns.ParseRouterName(hop.Hostname),
 		}
 		
 		if !hop.Lost && hop.IP != "" {
-			// Lookup geo (with rate limiting consideration)
-			if geo, err := LookupIP(hop.IP); err == nil && geo.Lat != 0 {
-				hopGeo.Lat = geo.Lat
-				hopGeo.Lon = geo.Lon
-				hopGeo.City = geo.City
-				hopGeo.Country = geo.Country
-				hopGeo.AS = geo.AS
-				hopGeo.Org = geo.Org
+			// Private hops and hops over the online rate limit have no geo
+			if geo, err := h.geo.Lookup(r.Context(), hop.IP); err == nil {
+				hopGeo.setGeo(geo)
+			}
+			// Router names often say where the router is when databases don't
+			if hopGeo.City == "" && hopGeo.Router != nil && hopGeo.Router.City != "" {
+				hopGeo.City, hopGeo.Country = hopGeo.Router.City, hopGeo.Router.Country
 			}
-			// Small delay to avoid rate limiting
-			time.Sleep(50 * time.Millisecond)
 		}
 		
 		result.Hops = append(result.Hops, hopGeo)
@@ -9,7 +9,9 @@ import (
 	"time"
 
 	"github.com/user/netpulse/internal/daemon"
+	"github.com/user/netpulse/internal/geoip"
 	"github.com/user/netpulse/internal/model"
+	"github.com/user/netpulse/internal/monitor"
 	"github.com/user/netpulse/internal/report"
 	"github.com/user/netpulse/internal/storage"
 	"github.com/user/netpulse/internal/util"
@@ -19,13 +21,24 @@ import (
 type Handlers struct {
 	db     *storage.DB
 	config *util.Config
+	geo    *geoip.Resolver
 }
 
-// NewHandlers creates new handlers.
-func NewHandlers(db *storage.DB, cfg *util.Config) *Handlers {
+// NewHandlers creates new handlers. A nil geo opens a resolver.
+func NewHandlers(db *storage.DB, cfg *util.Config, geo *geoip.Resolver) *Handlers {
+	if geo == nil {
+		var err error
+		geo, err = geoip.Open(cfg.DataDir, storage.NewGeoIPStorage(db))
+		if err != nil {
+			util.Warn("Failed to load geolocation databases: %v", err)
+		}
+		geo.SetOnline(cfg.GeoIPOnline)
+	}
+
 	return &Handlers{
 		db:     db,
 		config: cfg,
+		geo:    geo,
 	}
 }
 
@@ -45,10 +58,17 @@ func (h *Handlers) Dashboard(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// APIGetIP returns the current IP.
+// APIGetIP returns the current IP. An optional family parameter ("ipv4" or
+// "ipv6") selects the address family.
 func (h *Handlers) APIGetIP(w http.ResponseWriter, r *http.Request) {
 	ipStorage := storage.NewIPStorage(h.db)
-	latest, err := ipStorage.GetLatest()
+	var latest *model.IPRecord
+	var err error
+	if family := r.URL.Query().Get("family"); family != "" {
+		latest, err = ipStorage.GetLatestByFamily(family)
+	} else {
+		latest, err = ipStorage.GetLatest()
+	}
 	if err != nil {
 		writeError(w, err, http.StatusInternalServerError)
 		return
@@ -57,6 +77,23 @@ func (h *Handlers) APIGetIP(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, latest)
 }
 
+// APIGetIPProviders returns the reliability of each public IP provider per
+// address family.
+func (h *Handlers) APIGetIPProviders(w http.ResponseWriter, r *http.Request) {
+	since := parseSince(r, 7*24*time.Hour)
+
+	stats, err := storage.NewIPStorage(h.db).GetProviderStats(since)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if stats == nil {
+		stats = []model.IPProviderStats{}
+	}
+
+	writeJSON(w, stats)
+}
+
 // APIGetIPHistory returns IP history.
 func (h *Handlers) APIGetIPHistory(w http.ResponseWriter, r *http.Request) {
 	since := time.Now().Add(-24 * time.Hour)
@@ -74,6 +111,16 @@ func (h *Handlers) APIGetIPHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if family := r.URL.Query().Get("family"); family != "" {
+		filtered := make([]model.IPRecord, 0, len(records))
+		for _, record := range records {
+			if record.Family == family {
+				filtered = append(filtered, record)
+			}
+		}
+		records = filtered
+	}
+
 	writeJSON(w, records)
 }
 
@@ -171,6 +218,163 @@ func (h *Handlers) APIGetHosts(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, hosts)
 }
 
+// HTTPCheckSummary is the latest result of a check with its availability
+// over the requested period.
+type HTTPCheckSummary struct {
+	model.HTTPCheckResult
+	Checks       int     `json:"checks"`
+	Availability float64 `json:"availability"` // Percentage of successful checks
+	AvgTotalMs   float64 `json:"avg_total_ms"`
+}
+
+// APIGetHTTPChecks returns the latest result of every HTTP check.
+func (h *Handlers) APIGetHTTPChecks(w http.ResponseWriter, r *http.Request) {
+	since := parseSince(r, 24*time.Hour)
+
+	checkStorage := storage.NewHTTPCheckStorage(h.db)
+	latest, err := checkStorage.GetLatest()
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	history, err := checkStorage.GetHistory("", since)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	summaries := make([]HTTPCheckSummary, 0, len(latest))
+	for _, result := range latest {
+		summary := HTTPCheckSummary{HTTPCheckResult: result}
+		ok := 0
+		var total float64
+		for _, past := range history {
+			if past.Name != result.Name {
+				continue
+			}
+			summary.Checks++
+			if past.Success {
+				ok++
+				total += past.TotalMs
+			}
+		}
+		if summary.Checks > 0 {
+			summary.Availability = float64(ok) / float64(summary.Checks) * 100
+		}
+		if ok > 0 {
+			summary.AvgTotalMs = total / float64(ok)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	writeJSON(w, summaries)
+}
+
+// APIGetHTTPCheckHistory returns HTTP check results over time, optionally
+// for a single check (name parameter).
+func (h *Handlers) APIGetHTTPCheckHistory(w http.ResponseWriter, r *http.Request) {
+	since := parseSince(r, 24*time.Hour)
+
+	results, err := storage.NewHTTPCheckStorage(h.db).GetHistory(r.URL.Query().Get("name"), since)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if results == nil {
+		results = []model.HTTPCheckResult{}
+	}
+
+	writeJSON(w, results)
+}
+
+// APIGetThroughput returns the latest result of every throughput test.
+func (h *Handlers) APIGetThroughput(w http.ResponseWriter, r *http.Request) {
+	results, err := storage.NewThroughputStorage(h.db).GetLatest()
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if results == nil {
+		results = []model.ThroughputResult{}
+	}
+
+	writeJSON(w, results)
+}
+
+// APIGetThroughputHistory returns throughput results over time, optionally
+// for a single server (server parameter).
+func (h *Handlers) APIGetThroughputHistory(w http.ResponseWriter, r *http.Request) {
+	since := parseSince(r, 7*24*time.Hour)
+
+	results, err := storage.NewThroughputStorage(h.db).GetHistory(r.URL.Query().Get("server"), since)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if results == nil {
+		results = []model.ThroughputResult{}
+	}
+
+	writeJSON(w, results)
+}
+
+// APIGetBufferbloat returns latency-under-load results over time,
+// optionally for a single target (target parameter).
+func (h *Handlers) APIGetBufferbloat(w http.ResponseWriter, r *http.Request) {
+	since := parseSince(r, 30*24*time.Hour)
+
+	results, err := storage.NewBufferbloatStorage(h.db).GetHistory(r.URL.Query().Get("target"), since)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if results == nil {
+		results = []model.BufferbloatResult{}
+	}
+
+	writeJSON(w, results)
+}
+
+// APIGetPMTU returns path MTU results over time, optionally for a single
+// target (target parameter).
+func (h *Handlers) APIGetPMTU(w http.ResponseWriter, r *http.Request) {
+	since := parseSince(r, 7*24*time.Hour)
+
+	results, err := storage.NewPMTUStorage(h.db).GetHistory(r.URL.Query().Get("target"), since)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if results == nil {
+		results = []model.PMTUResult{}
+	}
+
+	writeJSON(w, results)
+}
+
+// parseSince reads the since parameter (a duration such as "24h") and
+// returns the corresponding start time.
+func parseSince(r *http.Request, def time.Duration) time.Time {
+	if d, err := time.ParseDuration(r.URL.Query().Get("since")); err == nil {
+		return time.Now().Add(-d)
+	}
+	return time.Now().Add(-def)
+}
+
+// APIGetCerts returns the TLS certificate inventory, soonest expiry first.
+func (h *Handlers) APIGetCerts(w http.ResponseWriter, r *http.Request) {
+	certs, err := storage.NewCertStorage(h.db).GetAll()
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if certs == nil {
+		certs = []model.Certificate{}
+	}
+
+	writeJSON(w, certs)
+}
+
 // APIGetStatus returns daemon status.
 func (h *Handlers) APIGetStatus(w http.ResponseWriter, r *http.Request) {
 	running, pid := daemon.CheckRunning(h.config.DataDir)
@@ -191,6 +395,9 @@ func (h *Handlers) APIGetStatus(w http.ResponseWriter, r *http.Request) {
 		status["asn"] = latest.ASN
 		status["last_check"] = latest.Timestamp.Format("2006-01-02 15:04:05")
 	}
+	if latest, err := ipStorage.GetLatestByFamily("ipv6"); err == nil && latest != nil {
+		status["current_ipv6"] = latest.IP
+	}
 
 	scanStorage := storage.NewScanStorage(h.db)
 	if count, err := scanStorage.CountAliveHosts(); err == nil {
@@ -246,6 +453,9 @@ func (h *Handlers) getDashboardData() map[string]interface{} {
 		data["asn"] = latest.ASN
 		data["last_check"] = latest.Timestamp.Format("2006-01-02 15:04:05")
 	}
+	if latest, err := ipStorage.GetLatestByFamily("ipv6"); err == nil && latest != nil {
+		data["current_ipv6"] = latest.IP
+	}
 
 	if count, err := ipStorage.Count(); err == nil {
 		data["ip_count"] = count
@@ -341,41 +551,83 @@ func (h *Handlers) APIUpdateHostMetadata(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, map[string]string{"status": "ok"})
 }
 
-// APIGetDNSHistory returns DNS latency history
+// APIGetDNSHistory returns DNS measurements, failures included, with the
+// availability of each resolver over the same period (the last 24 hours
+// when a limit is used instead of a time range).
 func (h *Handlers) APIGetDNSHistory(w http.ResponseWriter, r *http.Request) {
+	var metrics []model.DNSMetric
+	var err error
+	end := time.Now()
+	start := end.Add(-24 * time.Hour)
+
 	// Time Range Filter
 	startStr := r.URL.Query().Get("start")
 	endStr := r.URL.Query().Get("end")
 
-	if startStr != "" && endStr != "" {
-		start, err1 := time.Parse(time.RFC3339, startStr)
-		end, err2 := time.Parse(time.RFC3339, endStr)
-		if err1 == nil && err2 == nil {
-			metrics, err := h.db.GetDNSHistoryTimeRange(start, end)
-			if err != nil {
-				writeError(w, err, http.StatusInternalServerError)
-				return
+	rangeStart, err1 := time.Parse(time.RFC3339, startStr)
+	rangeEnd, err2 := time.Parse(time.RFC3339, endStr)
+	if startStr != "" && endStr != "" && err1 == nil && err2 == nil {
+		start, end = rangeStart, rangeEnd
+		metrics, err = h.db.GetDNSHistoryTimeRange(start, end)
+	} else {
+		// Default Limit
+		limit := 100
+		if l := r.URL.Query().Get("limit"); l != "" {
+			if val, err := strconv.Atoi(l); err == nil && val > 0 {
+				limit = val
 			}
-			writeJSON(w, metrics)
-			return
 		}
+		metrics, err = h.db.GetDNSHistory(limit)
+	}
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
 	}
 
-	// Default Limit
-	limit := 100
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if val, err := strconv.Atoi(l); err == nil && val > 0 {
-			limit = val
-		}
+	availability, err := h.db.GetDNSAvailability(start, end)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if metrics == nil {
+		metrics = []model.DNSMetric{}
 	}
+	if availability == nil {
+		availability = []model.DNSAvailability{}
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"metrics":      metrics,
+		"availability": availability,
+	})
+}
 
-	metrics, err := h.db.GetDNSHistory(limit)
+// APIGetDNSIntegrity returns the answers collected by the DNS integrity job
+// and the hijacking and interception anomalies it raised.
+func (h *Handlers) APIGetDNSIntegrity(w http.ResponseWriter, r *http.Request) {
+	since := parseSince(r, 24*time.Hour)
+
+	checks, err := storage.NewDNSIntegrityStorage(h.db).GetHistory(r.URL.Query().Get("server"), since)
 	if err != nil {
 		writeError(w, err, http.StatusInternalServerError)
 		return
 	}
+	anomalies, err := storage.NewAnomalyStorage(h.db).GetSince(since, "dns_")
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if checks == nil {
+		checks = []model.DNSIntegrityCheck{}
+	}
+	if anomalies == nil {
+		anomalies = []model.Anomaly{}
+	}
 
-	writeJSON(w, metrics)
+	writeJSON(w, map[string]interface{}{
+		"checks":    checks,
+		"anomalies": anomalies,
+	})
 }
 
 // APIGetDNSTargets returns all monitored targets
@@ -400,6 +652,14 @@ func (h *Handlers) APIAddDNSTarget(w http.ResponseWriter, r *http.Request) {
 		writeError(w, err, http.StatusBadRequest)
 		return
 	}
+	if _, err := monitor.ParseQueryTypes(req.QueryTypes); err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	if req.DoHFormat != "" && req.DoHFormat != monitor.DoHWire && req.DoHFormat != monitor.DoHJSON {
+		writeError(w, fmt.Errorf("doh_format must be %q or %q", monitor.DoHWire, monitor.DoHJSON), http.StatusBadRequest)
+		return
+	}
 
 	if err := h.db.AddDNSTarget(req); err != nil {
 		writeError(w, err, http.StatusInternalServerError)
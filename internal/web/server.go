@@ -10,6 +10,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/user/netpulse/internal/geoip"
 	"github.com/user/netpulse/internal/model"
 	"github.com/user/netpulse/internal/monitor"
 	"github.com/user/netpulse/internal/storage"
@@ -20,15 +21,19 @@ import (
 type Server struct {
 	db     *storage.DB
 	config *util.Config
+	geo    *geoip.Resolver
 	port   int
 	srv    *http.Server
 }
 
-// NewServer creates a new web server.
-func NewServer(db *storage.DB, cfg *util.Config, port int) *Server {
+// NewServer creates a new web server. A server running next to the daemon
+// is given the daemon's geolocation resolver, so both stay within one
+// online lookup limit; with nil it opens its own.
+func NewServer(db *storage.DB, cfg *util.Config, geo *geoip.Resolver, port int) *Server {
 	return &Server{
 		db:     db,
 		config: cfg,
+		geo:    geo,
 		port:   port,
 	}
 }
@@ -38,12 +43,13 @@ func (s *Server) Start() error {
 	mux := http.NewServeMux()
 
 	// Register routes
-	h := NewHandlers(s.db, s.config)
-	a := NewAnalyticsHandlers(s.db, s.config)
+	h := NewHandlers(s.db, s.config, s.geo)
+	a := NewAnalyticsHandlers(s.db, s.config, h.geo)
 
 	mux.HandleFunc("/", h.Dashboard)
 	mux.HandleFunc("/api/ip", h.APIGetIP)
 	mux.HandleFunc("/api/ip/history", h.APIGetIPHistory)
+	mux.HandleFunc("/api/ip/providers", h.APIGetIPProviders)
 	mux.HandleFunc("/api/traces", h.APIGetTraces)
 	mux.HandleFunc("/api/traces/", h.TraceGeoHandler) // Handles /api/traces/{id}/geo
 	mux.HandleFunc("/api/traces/by-target", h.APIGetTracesByTarget)
@@ -51,7 +57,15 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/api/hosts", h.APIGetHosts)
 	mux.HandleFunc("/api/hosts/", h.APIUpdateHostMetadata) // Handles /api/hosts/{id}/metadata
 	mux.HandleFunc("/api/status", h.APIGetStatus)
+	mux.HandleFunc("/api/certs", h.APIGetCerts)
+	mux.HandleFunc("/api/http-checks", h.APIGetHTTPChecks)
+	mux.HandleFunc("/api/http-checks/history", h.APIGetHTTPCheckHistory)
+	mux.HandleFunc("/api/throughput", h.APIGetThroughput)
+	mux.HandleFunc("/api/throughput/history", h.APIGetThroughputHistory)
+	mux.HandleFunc("/api/bufferbloat", h.APIGetBufferbloat)
+	mux.HandleFunc("/api/pmtu", h.APIGetPMTU)
 	mux.HandleFunc("/api/dns/history", h.APIGetDNSHistory)
+	mux.HandleFunc("/api/dns/integrity", h.APIGetDNSIntegrity)
 	mux.HandleFunc("/api/dns/targets", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
 			h.APIAddDNSTarget(w, r)
@@ -69,6 +83,7 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/api/analytics/latency", a.GetLatencyTrends)
 	mux.HandleFunc("/api/analytics/anomalies", a.GetAnomalies)
 	mux.HandleFunc("/api/analytics/mermaid", a.MermaidDiagram)
+	mux.HandleFunc("/api/analytics/aspaths", a.GetASPaths)
 
 	// Start DNS Monitor (1 minute interval)
 	go monitor.Run(1*time.Minute, func() ([]model.DNSTarget, error) {
@@ -2,6 +2,10 @@
 
 // ===== Global State =====
 let latencyChart = null;
+let httpCheckChart = null;
+let throughputChart = null;
+let bufferbloatChart = null;
+let pmtuChart = null;
 let currentTracePage = 1;
 let map = null;
 let pathLayer = null;
@@ -141,8 +145,9 @@ function renderPagination(container, data, fn) {
 // ===== Topology =====
 async function loadTopology() {
     const target = document.getElementById('topologyTarget')?.value || '';
+    const group = document.getElementById('topologyGroup')?.value || '';
     try {
-        const res = await fetch(`/api/analytics/mermaid?target=${encodeURIComponent(target)}`);
+        const res = await fetch(`/api/analytics/mermaid?target=${encodeURIComponent(target)}&group=${group}`);
         const diagram = await res.text();
         const el = document.getElementById('topologyDiagram');
         el.innerHTML = diagram;
@@ -161,9 +166,16 @@ async function loadLatencyChart() {
         if (latencyChart) latencyChart.destroy();
 
         const grouped = {};
+        const loss = {};
         (data || []).forEach(p => {
             if (!grouped[p.target]) grouped[p.target] = [];
-            grouped[p.target].push({ x: new Date(p.timestamp), y: p.latency_ms });
+            // Minutes where every ping was lost have no latency
+            const y = p.source === 'stream' && p.loss_pct >= 100 ? null : p.latency_ms;
+            grouped[p.target].push({ x: new Date(p.timestamp), y, jitter: p.jitter_ms, p95: p.p95_ms });
+            if (p.source === 'stream') {
+                if (!loss[p.target]) loss[p.target] = [];
+                loss[p.target].push({ x: new Date(p.timestamp), y: p.loss_pct || 0 });
+            }
         });
 
         const colors = ['#00ff41', '#ff00ff', '#ff4444', '#ffaa00', '#00aaff'];
@@ -172,8 +184,21 @@ async function loadLatencyChart() {
             data: grouped[t],
             borderColor: colors[i % colors.length],
             tension: 0.3,
-            fill: false
+            fill: false,
+            yAxisID: 'y'
         }));
+        Object.keys(loss).forEach(t => {
+            const i = Object.keys(grouped).indexOf(t);
+            datasets.push({
+                label: `${t} loss %`,
+                data: loss[t],
+                borderColor: colors[i % colors.length],
+                borderDash: [4, 4],
+                pointRadius: 0,
+                fill: false,
+                yAxisID: 'loss'
+            });
+        });
 
         const ctx = document.getElementById('latencyChart').getContext('2d');
         latencyChart = new Chart(ctx, {
@@ -183,14 +208,266 @@ async function loadLatencyChart() {
                 responsive: true,
                 scales: {
                     x: { type: 'time', time: { unit: 'minute' }, ticks: { color: '#666' }, grid: { color: '#222' } },
-                    y: { title: { display: true, text: 'Latency (ms)', color: '#666' }, ticks: { color: '#666' }, grid: { color: '#222' } }
+                    y: { title: { display: true, text: 'Latency (ms)', color: '#666' }, ticks: { color: '#666' }, grid: { color: '#222' } },
+                    loss: {
+                        display: Object.keys(loss).length > 0, position: 'right', min: 0, max: 100,
+                        title: { display: true, text: 'Loss (%)', color: '#666' }, ticks: { color: '#666' }, grid: { drawOnChartArea: false }
+                    }
                 },
-                plugins: { legend: { labels: { color: '#888' } } }
+                plugins: {
+                    legend: { labels: { color: '#888' } },
+                    tooltip: {
+                        callbacks: {
+                            afterLabel: ctx => ctx.raw.jitter !== undefined && ctx.raw.jitter !== null
+                                ? `jitter ${ctx.raw.jitter.toFixed(1)} ms, p95 ${(ctx.raw.p95 || 0).toFixed(1)} ms` : ''
+                        }
+                    }
+                }
             }
         });
     } catch (e) { console.error('Latency chart error:', e); }
 }
 
+// ===== Latency Under Load =====
+function gradeColor(grade) {
+    if (grade === 'A+' || grade === 'A') return 'var(--success)';
+    if (grade === 'B' || grade === 'C') return '#ffaa00';
+    return 'var(--danger)';
+}
+
+async function loadBufferbloat() {
+    try {
+        const target = document.getElementById('latencyTarget').value;
+        const res = await fetch('/api/bufferbloat' + (target ? `?target=${encodeURIComponent(target)}` : ''));
+        const history = await res.json();
+
+        const el = document.getElementById('bufferbloatList');
+        if (bufferbloatChart) { bufferbloatChart.destroy(); bufferbloatChart = null; }
+        if (!history || history.length === 0) {
+            el.innerHTML = '<p class="empty-state">> No bufferbloat tests yet (needs a throughput server)</p>';
+            return;
+        }
+
+        const recent = history.slice(-5).reverse();
+        const ms = v => v ? v.toFixed(1) + ' ms' : '-';
+        el.innerHTML = `<table>
+            <thead><tr><th>Tested</th><th>Target</th><th>Grade</th><th>Idle</th><th>Download</th><th>Upload</th><th>Increase</th></tr></thead>
+            <tbody>${recent.map(b => `<tr title="${escapeAttr(b.server)}">
+                <td>${new Date(b.timestamp).toLocaleString()}</td>
+                <td>${escapeAttr(b.target)}</td>
+                <td style="color: ${b.success ? gradeColor(b.grade) : 'var(--danger)'}; font-weight: bold">${b.success ? b.grade : escapeAttr(b.error || 'failed')}</td>
+                <td>${ms(b.idle_ms)}</td>
+                <td>${ms(b.download_ms)}${b.download_mbps ? ` @ ${b.download_mbps.toFixed(0)} Mbps` : ''}</td>
+                <td>${ms(b.upload_ms)}${b.upload_mbps ? ` @ ${b.upload_mbps.toFixed(0)} Mbps` : ''}</td>
+                <td>${b.success ? '+' + b.increase_ms.toFixed(1) + ' ms' : '-'}</td>
+            </tr>`).join('')}</tbody>
+        </table>`;
+
+        const ok = history.filter(b => b.success);
+        const series = (key) => ok.map(b => ({ x: new Date(b.timestamp), y: b[key] || null }));
+        const ctx = document.getElementById('bufferbloatChart').getContext('2d');
+        bufferbloatChart = new Chart(ctx, {
+            type: 'line',
+            data: {
+                datasets: [
+                    { label: 'Idle', data: series('idle_ms'), borderColor: '#00ff41', tension: 0.3, fill: false },
+                    { label: 'Downloading', data: series('download_ms'), borderColor: '#00aaff', tension: 0.3, fill: false },
+                    { label: 'Uploading', data: series('upload_ms'), borderColor: '#ff00ff', tension: 0.3, fill: false }
+                ]
+            },
+            options: {
+                responsive: true,
+                scales: {
+                    x: { type: 'time', time: { unit: 'day' }, ticks: { color: '#666' }, grid: { color: '#222' } },
+                    y: { title: { display: true, text: 'RTT (ms)', color: '#666' }, ticks: { color: '#666' }, grid: { color: '#222' } }
+                },
+                plugins: { legend: { labels: { color: '#888' } } }
+            }
+        });
+    } catch (e) { console.error('Bufferbloat error:', e); }
+}
+
+// ===== Path MTU =====
+async function loadPMTU() {
+    try {
+        const target = document.getElementById('latencyTarget').value;
+        const res = await fetch('/api/pmtu' + (target ? `?target=${encodeURIComponent(target)}` : ''));
+        const history = await res.json();
+
+        const el = document.getElementById('pmtuList');
+        if (pmtuChart) { pmtuChart.destroy(); pmtuChart = null; }
+        if (!history || history.length === 0) {
+            el.innerHTML = '<p class="empty-state">> No path MTU results yet</p>';
+            return;
+        }
+
+        const latest = {};
+        history.forEach(p => { latest[p.target] = p; });
+        el.innerHTML = `<table>
+            <thead><tr><th>Target</th><th>Path MTU</th><th>Interface</th><th>Reported By</th><th>Checked</th></tr></thead>
+            <tbody>${Object.values(latest).map(p => `<tr>
+                <td>${escapeAttr(p.target)}</td>
+                <td style="color: ${!p.success || p.black_hole ? 'var(--danger)' : p.pmtu < p.local_mtu ? '#ffaa00' : 'var(--success)'}; font-weight: bold">
+                    ${p.success ? p.pmtu + (p.black_hole ? ' (black hole)' : '') : escapeAttr(p.error || 'failed')}</td>
+                <td>${p.local_mtu || '-'}</td>
+                <td>${p.reported_by ? `${escapeAttr(p.reported_by)} (${p.reported_mtu})` : '-'}</td>
+                <td>${new Date(p.timestamp).toLocaleString()}</td>
+            </tr>`).join('')}</tbody>
+        </table>`;
+
+        const targets = Object.keys(latest);
+        const colors = ['#00aaff', '#ffaa00', '#ff00ff', '#00ff41', '#ff4444'];
+        const ctx = document.getElementById('pmtuChart').getContext('2d');
+        pmtuChart = new Chart(ctx, {
+            type: 'line',
+            data: {
+                datasets: targets.map((t, i) => ({
+                    label: t,
+                    data: history.filter(p => p.target === t && p.success).map(p => ({ x: new Date(p.timestamp), y: p.pmtu })),
+                    borderColor: colors[i % colors.length],
+                    stepped: true,
+                    fill: false
+                }))
+            },
+            options: {
+                responsive: true,
+                scales: {
+                    x: { type: 'time', time: { unit: 'day' }, ticks: { color: '#666' }, grid: { color: '#222' } },
+                    y: { title: { display: true, text: 'Bytes', color: '#666' }, ticks: { color: '#666' }, grid: { color: '#222' } }
+                },
+                plugins: { legend: { labels: { color: '#888' } } }
+            }
+        });
+    } catch (e) { console.error('Path MTU error:', e); }
+}
+
+// ===== HTTP Checks =====
+async function loadHTTPChecks() {
+    try {
+        const [latestRes, historyRes] = await Promise.all([
+            fetch('/api/http-checks'),
+            fetch('/api/http-checks/history')
+        ]);
+        const latest = await latestRes.json();
+        const history = await historyRes.json();
+
+        const el = document.getElementById('httpCheckList');
+        if (!latest || latest.length === 0) {
+            el.innerHTML = '<p class="empty-state">> No HTTP checks configured</p>';
+            return;
+        }
+
+        const ms = v => v ? v.toFixed(0) + ' ms' : '-';
+        el.innerHTML = `<table>
+            <thead><tr><th>Check</th><th>Status</th><th>DNS</th><th>Connect</th><th>TLS</th><th>TTFB</th><th>Total</th><th>Availability</th></tr></thead>
+            <tbody>${latest.map(c => `<tr title="${escapeAttr(c.url)}">
+                <td>${escapeAttr(c.name)}</td>
+                <td style="color: ${c.success ? 'var(--success)' : 'var(--danger)'}">${c.success ? c.status_code : escapeAttr(c.error || 'failed')}</td>
+                <td>${ms(c.dns_ms)}</td><td>${ms(c.connect_ms)}</td><td>${ms(c.tls_ms)}</td>
+                <td>${ms(c.ttfb_ms)}</td><td>${ms(c.total_ms)}</td>
+                <td>${c.availability.toFixed(1)}%</td>
+            </tr>`).join('')}</tbody>
+        </table>`;
+
+        if (httpCheckChart) httpCheckChart.destroy();
+
+        const grouped = {};
+        (history || []).forEach(r => {
+            if (!grouped[r.name]) grouped[r.name] = [];
+            grouped[r.name].push({ x: new Date(r.timestamp), y: r.success ? r.total_ms : null });
+        });
+
+        const colors = ['#00aaff', '#ffaa00', '#ff00ff', '#00ff41', '#ff4444'];
+        const datasets = Object.keys(grouped).map((name, i) => ({
+            label: name,
+            data: grouped[name],
+            borderColor: colors[i % colors.length],
+            spanGaps: false,
+            tension: 0.3,
+            fill: false
+        }));
+
+        const ctx = document.getElementById('httpCheckChart').getContext('2d');
+        httpCheckChart = new Chart(ctx, {
+            type: 'line',
+            data: { datasets },
+            options: {
+                responsive: true,
+                scales: {
+                    x: { type: 'time', time: { unit: 'minute' }, ticks: { color: '#666' }, grid: { color: '#222' } },
+                    y: { title: { display: true, text: 'Response time (ms)', color: '#666' }, ticks: { color: '#666' }, grid: { color: '#222' } }
+                },
+                plugins: { legend: { labels: { color: '#888' } } }
+            }
+        });
+    } catch (e) { console.error('HTTP checks error:', e); }
+}
+
+// ===== Throughput =====
+async function loadThroughput() {
+    try {
+        const [latestRes, historyRes] = await Promise.all([
+            fetch('/api/throughput'),
+            fetch('/api/throughput/history')
+        ]);
+        const latest = await latestRes.json();
+        const history = await historyRes.json();
+
+        const el = document.getElementById('throughputList');
+        if (!latest || latest.length === 0) {
+            el.innerHTML = '<p class="empty-state">> No throughput servers configured</p>';
+            return;
+        }
+
+        el.innerHTML = `<table>
+            <thead><tr><th>Server</th><th>Test</th><th>Mbps</th><th>Retransmits</th><th>Loss</th><th>Jitter</th><th>Tested</th></tr></thead>
+            <tbody>${latest.map(t => `<tr>
+                <td>${escapeAttr(t.server)}</td>
+                <td>${t.protocol.toUpperCase()} ${t.direction}</td>
+                <td style="color: ${t.success ? 'inherit' : 'var(--danger)'}">${t.success ? t.mbps.toFixed(1) : escapeAttr(t.error || 'failed')}</td>
+                <td>${t.protocol === 'tcp' && t.success ? t.retransmits : '-'}</td>
+                <td>${t.protocol === 'udp' && t.success ? t.loss_pct.toFixed(2) + '%' : '-'}</td>
+                <td>${t.protocol === 'udp' && t.success ? t.jitter_ms.toFixed(2) + ' ms' : '-'}</td>
+                <td>${new Date(t.timestamp).toLocaleString()}</td>
+            </tr>`).join('')}</tbody>
+        </table>`;
+
+        if (throughputChart) throughputChart.destroy();
+
+        // One line per server and TCP direction; UDP runs at a fixed rate
+        const grouped = {};
+        (history || []).filter(t => t.protocol === 'tcp').forEach(t => {
+            const key = `${t.server} ${t.direction}`;
+            if (!grouped[key]) grouped[key] = [];
+            grouped[key].push({ x: new Date(t.timestamp), y: t.success ? t.mbps : null });
+        });
+
+        const colors = ['#00aaff', '#ffaa00', '#ff00ff', '#00ff41', '#ff4444'];
+        const datasets = Object.keys(grouped).map((key, i) => ({
+            label: key,
+            data: grouped[key],
+            borderColor: colors[i % colors.length],
+            spanGaps: false,
+            tension: 0.3,
+            fill: false
+        }));
+
+        const ctx = document.getElementById('throughputChart').getContext('2d');
+        throughputChart = new Chart(ctx, {
+            type: 'line',
+            data: { datasets },
+            options: {
+                responsive: true,
+                scales: {
+                    x: { type: 'time', time: { unit: 'hour' }, ticks: { color: '#666' }, grid: { color: '#222' } },
+                    y: { title: { display: true, text: 'Mbps', color: '#666' }, ticks: { color: '#666' }, grid: { color: '#222' } }
+                },
+                plugins: { legend: { labels: { color: '#888' } } }
+            }
+        });
+    } catch (e) { console.error('Throughput error:', e); }
+}
+
 // ===== Anomalies =====
 async function loadAnomalies() {
     try {
@@ -345,6 +622,18 @@ async function showTraceOnMap(traceId) {
                             <span class="popup-label">IP Address</span>
                             <span class="popup-val">${h.ip}</span>
                         </div>
+                        ${h.hostname ? `
+                        <div class="popup-row">
+                            <span class="popup-label">Hostname</span>
+                            <span class="popup-val">${h.hostname}</span>
+                        </div>
+                        ` : ''}
+                        ${h.router && h.router.role ? `
+                        <div class="popup-row">
+                            <span class="popup-label">Router</span>
+                            <span class="popup-val">${h.router.device} (${h.router.role})</span>
+                        </div>
+                        ` : ''}
                         <div class="popup-row">
                             <span class="popup-label">Latency</span>
                             <span class="popup-val">${h.latency_ms.toFixed(1)} ms</span>
@@ -381,9 +670,9 @@ function startLiveUpdates() {
 function updateActiveTab() {
     if (document.hidden) return;
 
-    if (currentTab === 'overview') updateOverview();
+    if (currentTab === 'overview') { updateOverview(); loadIPProviders(); }
     else if (currentTab === 'hosts') updateHosts();
-    else if (currentTab === 'latency') loadLatencyChart();
+    else if (currentTab === 'latency') { loadLatencyChart(); loadBufferbloat(); loadPMTU(); loadHTTPChecks(); loadThroughput(); }
     else if (currentTab === 'traces') loadTraces(currentTracePage);
     else if (currentTab === 'anomalies') loadAnomalies();
 }
@@ -401,6 +690,9 @@ async function updateOverview() {
             set('stat-asn', data.asn);
             set('stat-last-check', data.last_check);
         }
+        if (data.current_ipv6) {
+            set('stat-ipv6', data.current_ipv6);
+        }
 
         const daemonEl = document.getElementById('stat-daemon');
         if (daemonEl) {
@@ -416,6 +708,38 @@ async function updateOverview() {
     } catch (e) { console.error('Overview update failed', e); }
 }
 
+// ===== Public IP Providers =====
+async function loadIPProviders() {
+    try {
+        const res = await fetch('/api/ip/providers');
+        const stats = await res.json();
+        const el = document.getElementById('ipProviderList');
+        if (!el) return;
+
+        if (!stats || stats.length === 0) {
+            el.innerHTML = '<p class="empty-state">> No IP checks yet</p>';
+            return;
+        }
+
+        const pct = v => {
+            const color = v >= 99 ? 'var(--success)' : v >= 90 ? '#ffaa00' : 'var(--danger)';
+            return `<span style="color: ${color}">${v.toFixed(1)}%</span>`;
+        };
+        el.innerHTML = `<table>
+            <thead><tr><th>Provider</th><th>Method</th><th>Family</th><th>Checks</th><th>Answered</th><th>Agreed</th><th>Avg Latency</th></tr></thead>
+            <tbody>${stats.map(s => `<tr>
+                <td>${escapeAttr(s.provider)}</td>
+                <td>${escapeAttr(s.method).toUpperCase()}</td>
+                <td>${s.family === 'ipv6' ? 'IPv6' : 'IPv4'}</td>
+                <td>${s.checks}</td>
+                <td>${pct(s.success_pct)}</td>
+                <td>${s.checks > s.failures ? pct(s.agreement_pct) : '-'}${s.disagreements ? ` <span class="latency-sm">(${s.disagreements} differed)</span>` : ''}</td>
+                <td>${s.avg_latency_ms ? s.avg_latency_ms.toFixed(0) + ' ms' : '-'}</td>
+            </tr>`).join('')}</tbody>
+        </table>`;
+    } catch (e) { console.error('IP providers error:', e); }
+}
+
 async function updateHosts() {
     try {
         const res = await fetch('/api/hosts');
@@ -432,7 +756,7 @@ async function updateHosts() {
         container.innerHTML = hosts.map(h => {
             const portsHtml = h.ports && h.ports.length > 0
                 ? `<div class="port-grid">${h.ports.map(p =>
-                    `<div class="port-badge" title="${p.service}"><span class="port-num">${p.port}</span><span class="port-proto">${p.protocol}</span></div>`
+                    `<div class="port-badge" title="${escapeAttr(portTitle(p))}"><span class="port-num">${p.port}</span><span class="port-proto">${p.protocol}</span></div>`
                 ).join('')}</div>`
                 : `<div class="no-ports">No open ports found</div>`;
 
@@ -440,10 +764,14 @@ async function updateHosts() {
             const iconChar = getIconChar(h.icon);
             const safeJson = JSON.stringify(h).replace(/'/g, "&apos;").replace(/"/g, "&quot;");
 
-            const match = h.ip.toLowerCase().includes(currentFilter) || displayName.toLowerCase().includes(currentFilter);
+            const match = h.ip.toLowerCase().includes(currentFilter) || displayName.toLowerCase().includes(currentFilter) ||
+                (h.mac || '').toLowerCase().includes(currentFilter) || (h.vendor || '').toLowerCase().includes(currentFilter) ||
+                (h.device_info || '').toLowerCase().includes(currentFilter) || (h.services || []).some(s => s.toLowerCase().includes(currentFilter));
             const display = match ? '' : 'display:none';
 
             const tagsHtml = h.tags ? `<div class="host-tags">${h.tags.map(t => `<span class="host-tag">${t}</span>`).join('')}</div>` : '';
+            const servicesHtml = h.services ? `<div class="host-tags">${h.services.map(s => `<span class="host-tag">${escapeAttr(s)}</span>`).join('')}</div>` : '';
+            const sources = h.sources && h.sources.length ? h.sources : (h.detected_by ? [h.detected_by] : []);
 
             return `
              <div class="host-card" data-ip="${h.ip}" style="${display}" onclick='openAssetModal(${safeJson})'>
@@ -452,7 +780,10 @@ async function updateHosts() {
                          <span class="host-icon">${iconChar}</span>
                          <div>
                              <span class="host-ip">${h.ip}</span>
-                             <span class="host-name">${displayName}</span>
+                             <span class="host-name">${escapeAttr(displayName)}</span>
+                             ${h.mac ? `<span class="host-name">${h.mac}${h.vendor ? ' · ' + h.vendor : ''}</span>` : ''}
+                             ${h.device_info ? `<span class="host-name">${escapeAttr(h.device_info)}</span>` : ''}
+                             ${h.workgroup ? `<span class="host-name">Workgroup: ${escapeAttr(h.workgroup)}</span>` : ''}
                          </div>
                      </div>
                      <div class="host-status">
@@ -461,12 +792,14 @@ async function updateHosts() {
                      </div>
                  </div>
                  ${tagsHtml}
+                 ${servicesHtml}
                  <div class="port-section">
                      <div class="port-label">OPEN PORTS detected</div>
                      ${portsHtml}
                  </div>
                  <div class="host-footer">
                      <span class="last-seen">Seen: ${new Date(h.last_seen).toLocaleTimeString()}</span>
+                     ${sources.length ? `<span class="last-seen">via ${sources.join(', ').toUpperCase()}</span>` : ''}
                  </div>
              </div>
              `;
@@ -533,6 +866,19 @@ function getIconChar(name) {
     return map[name] || '💻';
 }
 
+// Port badge tooltip: service, fingerprint and state
+function portTitle(p) {
+    let title = [p.service, p.product, p.version].filter(Boolean).join(' ');
+    if (p.info) title += ' - ' + p.info;
+    if (p.state !== 'open') title += ` (${p.state})`;
+    return title;
+}
+
+// Fingerprint details come from remote hosts, so escape them
+function escapeAttr(s) {
+    return String(s).replace(/&/g, '&amp;').replace(/"/g, '&quot;').replace(/</g, '&lt;').replace(/>/g, '&gt;');
+}
+
 // Close modal on outside click
 window.onclick = function (event) {
     const modal = document.getElementById('assetModal');
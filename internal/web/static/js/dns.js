@@ -30,6 +30,7 @@ function initDNS() {
                 tooltip: {
                     callbacks: {
                         label: function (context) {
+                            if (context.parsed.y === null) return context.dataset.label + ': failed';
                             return context.dataset.label + ': ' + context.parsed.y + ' ms';
                         }
                     }
@@ -69,29 +70,34 @@ async function updateDNS() {
         }
 
         const res = await fetch('/api/dns/history?' + params);
-        const data = await res.json();
+        const history = await res.json();
 
-        if (!data) return;
+        if (!history || !history.metrics) return;
+        const data = history.metrics;
 
         // Group by Server+Protocol and Server for Integrity
         const grouped = {};
-        const integrity = {}; // { "Google": { udp: {ip, lat}, doh: {ip, lat} } }
+        const integrity = {}; // { "Google google.com": { udp: {ip, lat}, doh: {ip, lat}, dot: {ip, lat} } }
 
         data.forEach(d => {
             // Chart Data
             const key = `${d.server} [${d.protocol.toUpperCase()}]`;
             if (!grouped[key]) grouped[key] = [];
+            // Failures leave a gap in the line
             grouped[key].push({
                 x: d.timestamp,
-                y: d.latency_ms
+                y: d.success ? d.latency_ms : null
             });
 
-            // Integrity Data (Only latest)
-            if (!integrity[d.server]) integrity[d.server] = {};
+            // Integrity Data (Only latest A answers, per queried name)
+            if (!d.success || (d.query_type && d.query_type !== 'A')) return;
+            const server = d.query_name ? `${d.server} (${d.query_name.replace(/\.$/, '')})` : d.server;
+            const protocol = d.protocol === 'tcp' ? 'udp' : d.protocol;
+            if (!integrity[server]) integrity[server] = {};
             const ts = new Date(d.timestamp).getTime();
             // Store if newer
-            if (!integrity[d.server][d.protocol] || ts > integrity[d.server][d.protocol].ts) {
-                integrity[d.server][d.protocol] = {
+            if (!integrity[server][protocol] || ts > integrity[server][protocol].ts) {
+                integrity[server][protocol] = {
                     ip: d.resolved_ip,
                     lat: d.latency_ms,
                     ts: ts
@@ -101,6 +107,8 @@ async function updateDNS() {
 
         updateChart(grouped);
         renderIntegrityTable(integrity);
+        renderAvailability(history.availability || []);
+        loadDNSIntegrity();
 
     } catch (e) {
         console.error('DNS update failed', e);
@@ -135,11 +143,12 @@ function renderIntegrityTable(data) {
     tbody.innerHTML = Object.keys(data).map(server => {
         const udp = data[server].udp || { ip: 'Pending...', lat: '-' };
         const doh = data[server].doh || { ip: 'Pending...', lat: '-' };
+        const dot = data[server].dot || { ip: 'N/A', lat: '-' };
 
         let status = '<span class="status-badge status-running">Secure</span>';
         let rowClass = '';
 
-        if (udp.ip && doh.ip && udp.ip !== doh.ip) {
+        if ((udp.ip && doh.ip && udp.ip !== doh.ip) || (data[server].dot && dot.ip && udp.ip && dot.ip !== udp.ip)) {
             status = '<span class="status-badge status-stopped">MISMATCH</span>';
             rowClass = 'style="background: rgba(255,0,0,0.1)"';
         } else if (!udp.ip || !doh.ip) {
@@ -151,12 +160,83 @@ function renderIntegrityTable(data) {
             <td>${server}</td>
             <td>${udp.ip} <span class="latency-sm">(${udp.lat}ms)</span></td>
             <td>${doh.ip} <span class="latency-sm">(${doh.lat}ms)</span></td>
+            <td>${dot.ip} <span class="latency-sm">(${dot.lat}ms)</span></td>
             <td>${status}</td>
         </tr>
         `;
     }).join('');
 }
 
+function renderAvailability(stats) {
+    const tbody = document.getElementById('dnsAvailabilityBody');
+    if (!tbody) return;
+
+    if (stats.length === 0) {
+        tbody.innerHTML = '<tr><td colspan="5" style="text-align:center">No measurements in range</td></tr>';
+        return;
+    }
+
+    tbody.innerHTML = stats.map(a => {
+        const color = a.availability_pct >= 99.9 ? 'var(--success)' : a.availability_pct >= 95 ? '#ffaa00' : 'var(--danger)';
+        const errors = Object.entries(a.errors || {})
+            .sort((x, y) => y[1] - x[1])
+            .map(([cls, n]) => `${escapeAttr(cls)} ×${n}`).join(', ');
+        return `
+        <tr>
+            <td>${escapeAttr(a.server)}</td>
+            <td>${a.protocol.toUpperCase()}</td>
+            <td style="color: ${color}; font-weight: bold">${a.availability_pct.toFixed(2)}% <span class="latency-sm">(${a.checks - a.failures}/${a.checks})</span></td>
+            <td>${a.avg_latency_ms ? a.avg_latency_ms.toFixed(0) + ' ms' : '-'}</td>
+            <td>${errors || '-'}</td>
+        </tr>
+        `;
+    }).join('');
+}
+
+// Shows the hijacking and interception alerts and the answers of the last
+// integrity run.
+async function loadDNSIntegrity() {
+    const tbody = document.getElementById('dnsHijackBody');
+    const alerts = document.getElementById('dnsHijackAlerts');
+    if (!tbody || !alerts) return;
+
+    try {
+        const res = await fetch('/api/dns/integrity?since=168h');
+        const data = await res.json();
+
+        alerts.innerHTML = (data.anomalies || []).slice(0, 5).map(a => `
+            <div class="anomaly-card">
+                <div class="anomaly-title">⚠ ${escapeAttr(a.description)}</div>
+                <div>Detected: ${new Date(a.timestamp).toLocaleString()}</div>
+            </div>
+        `).join('');
+
+        const checks = data.checks || [];
+        if (checks.length === 0) {
+            tbody.innerHTML = '<tr><td colspan="5" style="text-align:center">No integrity checks yet</td></tr>';
+            return;
+        }
+        const latest = checks[checks.length - 1].timestamp;
+        tbody.innerHTML = checks.filter(c => c.timestamp === latest).map(c => {
+            const answer = (c.answers && c.answers.length) ? c.answers.join(', ') : c.rcode;
+            const status = c.ok
+                ? '<span class="status-badge status-running">OK</span>'
+                : '<span class="status-badge status-stopped">SUSPECT</span>';
+            return `
+            <tr ${c.ok ? '' : 'style="background: rgba(255,0,0,0.1)"'}>
+                <td>${escapeAttr(c.server)}</td>
+                <td>${c.protocol.toUpperCase()}</td>
+                <td>${escapeAttr(c.check)} <span class="latency-sm">${escapeAttr(c.query_name)}</span></td>
+                <td>${escapeAttr(answer)}</td>
+                <td>${status}</td>
+            </tr>
+            `;
+        }).join('');
+    } catch (e) {
+        console.error('DNS integrity failed', e);
+    }
+}
+
 function getDNSColor(key) {
     if (key.includes('Google')) return '#4285F4';
     if (key.includes('Cloudflare')) return '#F48120';
@@ -188,7 +268,8 @@ async function loadDNSTargets() {
         <div class="target-item" id="target-${t.id}">
             <div class="target-info">
                 <strong>${t.name}</strong><br>
-                <small>UDP: ${t.ip} | DoH: ${t.doh_url || 'N/A'}</small>
+                <small>UDP: ${t.ip || 'N/A'} | DoH: ${t.doh_url || 'N/A'} | DoT: ${t.dot_server || 'N/A'}</small><br>
+                <small>Queries: ${(t.query_names || ['google.com']).join(', ')} [${(t.query_types || ['A']).join(', ')}]</small>
             </div>
             <button onclick="deleteDNSTarget(${t.id}, this)" class="btn-icon" title="Delete Monitor" style="background:none; border:none; cursor:pointer; padding:5px; opacity:0.7; transition:opacity 0.2s">
                 <svg width="18" height="18" viewBox="0 0 24 24" fill="none" stroke="#ff4444" stroke-width="2" stroke-linecap="round" stroke-linejoin="round">
@@ -204,8 +285,13 @@ async function addDNSTarget() {
     const name = document.getElementById('newTargetName').value;
     const ip = document.getElementById('newTargetIP').value;
     const doh = document.getElementById('newTargetDoH').value;
+    const dot = document.getElementById('newTargetDoT').value;
+    const dohFormat = document.getElementById('newTargetDoHFormat').value;
+    const list = id => document.getElementById(id).value.split(',').map(s => s.trim()).filter(s => s);
+    const names = list('newTargetNames');
+    const types = list('newTargetTypes').map(s => s.toUpperCase());
 
-    if (!name || (!ip && !doh)) {
+    if (!name || (!ip && !doh && !dot)) {
         alert("Name and at least one address required");
         return;
     }
@@ -214,14 +300,19 @@ async function addDNSTarget() {
     if (btn) { btn.innerText = 'Adding...'; btn.disabled = true; }
 
     try {
-        await fetch('/api/dns/targets', {
+        const res = await fetch('/api/dns/targets', {
             method: 'POST',
-            body: JSON.stringify({ name, ip, doh_url: doh })
+            body: JSON.stringify({ name, ip, doh_url: doh, doh_format: dohFormat, dot_server: dot, query_names: names, query_types: types })
         });
+        if (!res.ok) {
+            const err = await res.json().catch(() => ({}));
+            alert(err.error || 'Failed to add target');
+            return;
+        }
 
-        document.getElementById('newTargetName').value = '';
-        document.getElementById('newTargetIP').value = '';
-        document.getElementById('newTargetDoH').value = '';
+        ['newTargetName', 'newTargetIP', 'newTargetDoH', 'newTargetDoT', 'newTargetNames', 'newTargetTypes']
+            .forEach(id => { document.getElementById(id).value = ''; });
+        document.getElementById('newTargetDoHFormat').value = '';
         await loadDNSTargets();
     } finally {
         if (btn) { btn.innerText = 'Add Target'; btn.disabled = false; }